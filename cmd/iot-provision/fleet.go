@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/dynreg"
+	"github.com/iot-go-sdk/pkg/logging"
+)
+
+// Failure is a device whose registration failed.
+type Failure struct {
+	Device Device
+	// Code classifies the failure: already_registered, not_pre_registered,
+	// signature, no_credentials, unknown_outcome, the platform result
+	// code, or error
+	Code string
+	Err  error
+}
+
+// Summary counts the outcome of a run. Devices not attempted because the
+// run was interrupted are Remaining; they are registered on resume.
+type Summary struct {
+	Registered int
+	Skipped    int
+	Remaining  int
+	Failures   []Failure
+}
+
+// fleet registers devices concurrently, starting at most rate
+// registrations per second.
+type fleet struct {
+	config      *config.Config
+	out         *outputStore
+	options     dynreg.ProvisionOptions
+	concurrency int
+	rate        float64
+	logger      logging.Logger
+}
+
+func (f *fleet) run(ctx context.Context, devices []Device) Summary {
+	var summary Summary
+	var mutex sync.Mutex
+
+	var pending []Device
+	for _, d := range devices {
+		if _, err := f.out.Get(d.ProductKey, d.DeviceName); err == nil {
+			summary.Skipped++
+			continue
+		}
+		pending = append(pending, d)
+	}
+	if summary.Skipped > 0 {
+		f.logger.Info("resuming from output manifest", "skipped", summary.Skipped, "pending", len(pending))
+	}
+
+	var tokens <-chan time.Time
+	if f.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / f.rate))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	jobs := make(chan Device)
+	var wg sync.WaitGroup
+	for i := 0; i < f.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				err := f.register(ctx, d)
+
+				mutex.Lock()
+				if err != nil {
+					summary.Failures = append(summary.Failures, Failure{Device: d, Code: failureCode(err), Err: err})
+				} else {
+					summary.Registered++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	sent := 0
+feed:
+	for _, d := range pending {
+		if tokens != nil && sent > 0 {
+			select {
+			case <-ctx.Done():
+				break feed
+			case <-tokens:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- d:
+			sent++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary.Remaining = len(pending) - sent
+	return summary
+}
+
+func (f *fleet) register(ctx context.Context, d Device) error {
+	cfg := *f.config
+	cfg.Device.ProductKey = d.ProductKey
+	cfg.Device.DeviceName = d.DeviceName
+	// Identity settings from the environment would make Provision skip
+	// the registration
+	cfg.Device.DeviceSecret, cfg.Device.DeviceSecretKey, cfg.Device.DeviceSecretCommand = "", nil, ""
+	cfg.MQTT.ClientID, cfg.MQTT.Username, cfg.MQTT.Password = "", "", ""
+	cfg.TLS.ClientCert, cfg.TLS.ClientKey, cfg.TLS.ClientPKCS12 = "", "", ""
+	if d.ProductSecret != "" {
+		cfg.Device.ProductSecret = d.ProductSecret
+		cfg.Device.ProductSecretKey = nil
+		cfg.Device.ProductSecretCommand = ""
+	}
+
+	options := f.options
+	options.Store = f.out
+	options.Logger = f.logger.With("productKey", d.ProductKey, "deviceName", d.DeviceName)
+
+	provisioned, err := dynreg.Provision(ctx, &cfg, options)
+	if err == nil && (provisioned == nil || (provisioned.Device.DeviceSecret == "" && provisioned.MQTT.Password == "")) {
+		// Never count a device without credentials as registered
+		err = fmt.Errorf("registration returned no credentials: %w", dynreg.ErrNoCredentials)
+		provisioned = nil
+	}
+	if err != nil {
+		if provisioned != nil {
+			// Registered, but the result could not be written
+			f.logger.Error("device registered but not saved to the output manifest", "productKey", d.ProductKey, "deviceName", d.DeviceName, "error", err)
+		} else {
+			f.logger.Warn("registration failed", "productKey", d.ProductKey, "deviceName", d.DeviceName, "error", err)
+		}
+		return err
+	}
+	f.logger.Debug("registered", "productKey", d.ProductKey, "deviceName", d.DeviceName)
+	return nil
+}
+
+func failureCode(err error) string {
+	switch {
+	case errors.Is(err, dynreg.ErrAlreadyRegistered):
+		return "already_registered"
+	case errors.Is(err, dynreg.ErrNotPreRegistered):
+		return "not_pre_registered"
+	case errors.Is(err, dynreg.ErrSignature):
+		return "signature"
+	case errors.Is(err, dynreg.ErrNoCredentials):
+		return "no_credentials"
+	}
+	var unknown *dynreg.UnknownOutcomeError
+	if errors.As(err, &unknown) {
+		return "unknown_outcome"
+	}
+	var regErr *dynreg.RegistrationError
+	if errors.As(err, &regErr) {
+		return strconv.Itoa(regErr.Code)
+	}
+	return "error"
+}
@@ -0,0 +1,142 @@
+// Command iot-provision registers a fleet of devices by dynamic
+// registration, e.g. on a factory line.
+//
+//	iot-provision -manifest devices.csv -output secrets.jsonl
+//
+// The manifest lists productKey and deviceName (and optionally
+// productSecret) as CSV with a header row, a JSON array or JSON lines.
+// Each result is appended to the output manifest as soon as it arrives, so
+// an interrupted run resumes where it stopped when started again with the
+// same output. With IOT_PROVISION_PASSPHRASE set the output is an
+// encrypted credstore.Journal. Devices that register without credentials
+// count as failures and are retried on resume. Failed devices are
+// listed in a CSV report.
+//
+// The registration endpoint, product secret and TLS settings are read from
+// the IOT_* environment variables of package config.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/dynreg"
+	"github.com/iot-go-sdk/pkg/logging"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("iot-provision", flag.ContinueOnError)
+	manifest := fs.String("manifest", "", "device manifest (.csv, .json or .jsonl)")
+	output := fs.String("output", "", "output manifest (.csv or .jsonl; any name when encrypted)")
+	failures := fs.String("failures", "", "failure report, default <output>.failures.csv")
+	concurrency := fs.Int("concurrency", 4, "concurrent registrations")
+	rate := fs.Float64("rate", 10, "registrations started per second, 0 for no limit")
+	endpoint := fs.String("endpoint", "", "registration URL, overrides IOT_DYNREG_*")
+	transport := fs.String("transport", "", "http or mqtt; default http, mqtt with -skip-pre-regist")
+	skipPreRegist := fs.Bool("skip-pre-regist", false, "register devices not created on the platform")
+	timeout := fs.Duration("timeout", time.Minute, "timeout per device")
+	verbose := fs.Bool("v", false, "log every device")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *manifest == "" || *output == "" || *concurrency < 1 || *rate < 0 {
+		fs.Usage()
+		return 2
+	}
+	if *failures == "" {
+		*failures = *output + ".failures.csv"
+	}
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := logging.Redacting(logging.NewSlog(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))))
+	logging.SetDefault(logger)
+
+	cfg := config.NewConfig()
+	cfg.LoadFromEnv()
+	if *endpoint != "" {
+		if err := applyEndpoint(&cfg.DynReg, *endpoint); err != nil {
+			logger.Error("invalid endpoint", "error", err)
+			return 2
+		}
+	}
+
+	devices, err := readManifest(*manifest)
+	if err != nil {
+		logger.Error("failed to read manifest", "error", err)
+		return 1
+	}
+	out, err := openOutput(*output, os.Getenv("IOT_PROVISION_PASSPHRASE"))
+	if err != nil {
+		logger.Error("failed to open output manifest", "error", err)
+		return 1
+	}
+	defer out.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	f := &fleet{
+		config:      cfg,
+		out:         out,
+		options:     dynreg.ProvisionOptions{Transport: *transport, SkipPreRegist: *skipPreRegist, Timeout: *timeout},
+		concurrency: *concurrency,
+		rate:        *rate,
+		logger:      logger,
+	}
+	start := time.Now()
+	summary := f.run(ctx, devices)
+
+	if err := writeFailures(*failures, summary.Failures); err != nil {
+		logger.Error("failed to write failure report", "error", err)
+	}
+	logger.Info("provisioning finished",
+		"registered", summary.Registered,
+		"skipped", summary.Skipped,
+		"failed", len(summary.Failures),
+		"remaining", summary.Remaining,
+		"elapsed", time.Since(start).Round(time.Millisecond))
+
+	if len(summary.Failures) > 0 || summary.Remaining > 0 {
+		return 1
+	}
+	return 0
+}
+
+// applyEndpoint sets the registration endpoint from a URL.
+func applyEndpoint(d *config.DynRegConfig, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return fmt.Errorf("want scheme://host[:port]/path, got %q", endpoint)
+	}
+	d.Scheme = u.Scheme
+	d.Host = u.Hostname()
+	d.Port = 0
+	if port := u.Port(); port != "" {
+		if d.Port, err = strconv.Atoi(port); err != nil {
+			return err
+		}
+	}
+	if u.Path != "" {
+		d.Path = u.Path
+	}
+	return nil
+}
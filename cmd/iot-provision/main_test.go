@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/iot-go-sdk/pkg/credstore"
+)
+
+// registry is a fake registration server that refuses devices named
+// "activated" as already registered and answers "empty" without a secret.
+type registry struct {
+	mutex      sync.Mutex
+	registered map[string]int
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	dn := req.PostForm.Get("deviceName")
+	if dn == "activated" {
+		fmt.Fprint(w, `{"code":6289,"message":"device already active"}`)
+		return
+	}
+	if dn == "empty" {
+		fmt.Fprint(w, `{"code":200,"data":{}}`)
+		return
+	}
+	r.mutex.Lock()
+	r.registered[dn]++
+	r.mutex.Unlock()
+	fmt.Fprintf(w, `{"code":200,"data":{"deviceSecret":"secret-%s"}}`, dn)
+}
+
+func startRegistry(t *testing.T) (*registry, string) {
+	t.Helper()
+	r := &registry{registered: make(map[string]int)}
+	server := httptest.NewTLSServer(r)
+	t.Cleanup(server.Close)
+
+	t.Setenv("IOT_PRODUCT_SECRET", "productSecret")
+	t.Setenv("IOT_TLS_CA_CERT", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	return r, server.URL + "/auth/register/device"
+}
+
+func writeManifest(t *testing.T, dir string, names ...string) string {
+	t.Helper()
+	lines := []string{"productKey,deviceName"}
+	for _, name := range names {
+		lines = append(lines, "pk,"+name)
+	}
+	path := filepath.Join(dir, "devices.csv")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProvisionFleetResumesAndReportsFailures(t *testing.T) {
+	r, endpoint := startRegistry(t)
+	dir := t.TempDir()
+	output := filepath.Join(dir, "secrets.csv")
+
+	manifest := writeManifest(t, dir, "dev1", "dev2")
+	if code := run([]string{"-manifest", manifest, "-output", output, "-endpoint", endpoint, "-rate", "0"}); code != 0 {
+		t.Fatalf("first run exit code %d", code)
+	}
+
+	manifest = writeManifest(t, dir, "dev1", "dev2", "dev3", "activated", "empty")
+	if code := run([]string{"-manifest", manifest, "-output", output, "-endpoint", endpoint, "-rate", "100"}); code != 1 {
+		t.Fatalf("second run exit code %d, want 1 for the failed device", code)
+	}
+
+	for _, dn := range []string{"dev1", "dev2", "dev3"} {
+		if r.registered[dn] != 1 {
+			t.Errorf("%s registered %d times, want once", dn, r.registered[dn])
+		}
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[3][1] != "dev3" || records[3][2] != "secret-dev3" {
+		t.Fatalf("output manifest = %v", records)
+	}
+
+	report, err := os.ReadFile(output + ".failures.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "pk,activated,already_registered,") || !strings.Contains(string(report), "pk,empty,no_credentials,") {
+		t.Fatalf("failure report = %s", report)
+	}
+}
+
+func TestProvisionFleetEncryptedOutput(t *testing.T) {
+	_, endpoint := startRegistry(t)
+	t.Setenv("IOT_PROVISION_PASSPHRASE", "factory-passphrase")
+	dir := t.TempDir()
+	output := filepath.Join(dir, "secrets.enc")
+
+	manifest := writeManifest(t, dir, "dev1")
+	if code := run([]string{"-manifest", manifest, "-output", output, "-endpoint", endpoint}); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+
+	if data, _ := os.ReadFile(output); strings.Contains(string(data), "secret-dev1") {
+		t.Fatal("output manifest is not encrypted")
+	}
+	journal, err := credstore.OpenJournal(output, []byte("factory-passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	identity, err := journal.Get("pk", "dev1")
+	if err != nil || identity.DeviceSecret != "secret-dev1" {
+		t.Fatalf("identity = %+v, err = %v", identity, err)
+	}
+}
+
+func TestReadManifestFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"array.json": `[{"productKey":"pk","deviceName":"a","productSecret":"ps"},{"productKey":"pk","deviceName":"b"}]`,
+		"lines.jsonl": `{"productKey":"pk","deviceName":"a","productSecret":"ps"}
+{"productKey":"pk","deviceName":"b"}`,
+		"columns.csv": "deviceName,productSecret,productKey\na,ps,pk\nb,,pk\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0600)
+		devices, err := readManifest(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(devices) != 2 || devices[0] != (Device{"pk", "a", "ps"}) || devices[1] != (Device{ProductKey: "pk", DeviceName: "b"}) {
+			t.Fatalf("%s: devices = %+v", name, devices)
+		}
+	}
+
+	path := filepath.Join(dir, "dup.csv")
+	os.WriteFile(path, []byte("productKey,deviceName\npk,a\npk,a\n"), 0600)
+	if _, err := readManifest(path); err == nil {
+		t.Fatal("expected duplicate device error")
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/iot-go-sdk/pkg/credstore"
+)
+
+// Device is one manifest entry. ProductSecret is optional; the configured
+// product secret is used when it is empty.
+type Device struct {
+	ProductKey    string `json:"productKey"`
+	DeviceName    string `json:"deviceName"`
+	ProductSecret string `json:"productSecret,omitempty"`
+}
+
+func (d Device) key() string {
+	return d.ProductKey + "/" + d.DeviceName
+}
+
+// readManifest reads devices from a CSV file with a
+// productKey,deviceName[,productSecret] header, or from a JSON array or
+// JSON lines file.
+func readManifest(path string) ([]Device, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []Device
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		devices, err = readCSVManifest(data)
+	case ".json", ".jsonl":
+		devices, err = readJSONManifest(data)
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q: want .csv, .json or .jsonl", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(devices))
+	for i, d := range devices {
+		if d.ProductKey == "" || d.DeviceName == "" {
+			return nil, fmt.Errorf("invalid manifest %s: entry %d lacks productKey or deviceName", path, i+1)
+		}
+		if seen[d.key()] {
+			return nil, fmt.Errorf("invalid manifest %s: duplicate device %s", path, d.key())
+		}
+		seen[d.key()] = true
+	}
+	return devices, nil
+}
+
+func readCSVManifest(data []byte) ([]Device, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{"productkey": -1, "devicename": -1, "productsecret": -1}
+	for i, name := range records[0] {
+		if _, ok := columns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+	}
+	if columns["productkey"] < 0 || columns["devicename"] < 0 {
+		return nil, fmt.Errorf("CSV header must name productKey and deviceName columns")
+	}
+
+	field := func(record []string, column string) string {
+		if i := columns[column]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	devices := make([]Device, 0, len(records)-1)
+	for _, record := range records[1:] {
+		devices = append(devices, Device{
+			ProductKey:    field(record, "productkey"),
+			DeviceName:    field(record, "devicename"),
+			ProductSecret: field(record, "productsecret"),
+		})
+	}
+	return devices, nil
+}
+
+func readJSONManifest(data []byte) ([]Device, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var devices []Device
+		return devices, json.Unmarshal(trimmed, &devices)
+	}
+
+	var devices []Device
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var d Device
+		if err := decoder.Decode(&d); err == io.EOF {
+			return devices, nil
+		} else if err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+}
+
+// outputStore records registration results. It implements credstore.Store
+// so dynreg.Provision saves each result the moment it arrives; results
+// already present when the tool starts mark devices to skip on resume.
+type outputStore struct {
+	mutex sync.Mutex
+	done  map[string]*credstore.Identity
+	// Either results are appended to file as CSV or JSON lines, or they
+	// are kept in an encrypted credential store
+	file    *os.File
+	csv     *csv.Writer
+	format  string
+	journal *credstore.Journal
+}
+
+var outputCSVHeader = []string{"productKey", "deviceName", "deviceSecret", "clientId", "username", "password"}
+
+// openOutput opens the output manifest at path. With a passphrase the
+// output is an encrypted journal readable with credstore.OpenJournal,
+// which derives the key once and appends each result.
+func openOutput(path, passphrase string) (*outputStore, error) {
+	out := &outputStore{done: make(map[string]*credstore.Identity)}
+	if passphrase != "" {
+		journal, err := credstore.OpenJournal(path, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output: %w", err)
+		}
+		out.journal = journal
+		for _, identity := range journal.List() {
+			out.done[identity.ProductKey+"/"+identity.DeviceName] = identity
+		}
+		return out, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		out.format = "csv"
+	case ".json", ".jsonl":
+		out.format = "json"
+	default:
+		return nil, fmt.Errorf("unsupported output format %q: want .csv, .json or .jsonl", filepath.Ext(path))
+	}
+	if err := out.loadFile(path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	out.file = file
+	if err := terminateLastLine(path, file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if out.format == "csv" {
+		out.csv = csv.NewWriter(file)
+		if len(out.done) == 0 {
+			if info, err := file.Stat(); err == nil && info.Size() == 0 {
+				out.csv.Write(outputCSVHeader)
+				out.csv.Flush()
+			}
+		}
+	}
+	return out, nil
+}
+
+// terminateLastLine ends a line cut off by an interrupted run, so the next
+// result starts on a line of its own.
+func terminateLastLine(path string, file *os.File) error {
+	reader, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	info, err := reader.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := reader.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = file.Write([]byte{'\n'})
+	}
+	return err
+}
+
+// loadFile reads the devices of an existing CSV or JSON lines output. A
+// line cut off by an interrupted run is ignored, and so are entries
+// without credentials, so those devices are registered again.
+func (o *outputStore) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+
+	if o.format == "csv" {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil {
+			return fmt.Errorf("failed to read output %s: %w", path, err)
+		}
+		for i, record := range records {
+			if i == 0 && len(record) > 0 && record[0] == outputCSVHeader[0] {
+				continue
+			}
+			record = append(record, make([]string, len(outputCSVHeader))...)
+			o.record(&credstore.Identity{
+				ProductKey:   record[0],
+				DeviceName:   record[1],
+				DeviceSecret: record[2],
+				ClientID:     record[3],
+				Username:     record[4],
+				Password:     record[5],
+			})
+		}
+		return nil
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		var identity credstore.Identity
+		if err := json.Unmarshal(line, &identity); err != nil {
+			return fmt.Errorf("failed to read output %s: %w", path, err)
+		}
+		o.record(&identity)
+	}
+	return nil
+}
+
+func (o *outputStore) record(identity *credstore.Identity) {
+	if identity.Usable() {
+		o.done[identity.ProductKey+"/"+identity.DeviceName] = identity
+	}
+}
+
+func (o *outputStore) Get(productKey, deviceName string) (*credstore.Identity, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if identity, ok := o.done[productKey+"/"+deviceName]; ok {
+		return identity, nil
+	}
+	return nil, credstore.ErrNotFound
+}
+
+// Put persists a registration result before returning. Results without
+// credentials are refused, since resume would skip those devices.
+func (o *outputStore) Put(identity *credstore.Identity) error {
+	if !identity.Usable() {
+		return fmt.Errorf("refusing to record %s/%s without credentials", identity.ProductKey, identity.DeviceName)
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	switch {
+	case o.journal != nil:
+		if err := o.journal.Put(identity); err != nil {
+			return err
+		}
+	case o.csv != nil:
+		o.csv.Write([]string{identity.ProductKey, identity.DeviceName, identity.DeviceSecret,
+			identity.ClientID, identity.Username, identity.Password})
+		o.csv.Flush()
+		if err := o.csv.Error(); err != nil {
+			return err
+		}
+	default:
+		line, err := json.Marshal(identity)
+		if err != nil {
+			return err
+		}
+		if _, err := o.file.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	if o.file != nil {
+		// The platform issues a secret only once; do not lose it to a crash
+		if err := o.file.Sync(); err != nil {
+			return err
+		}
+	}
+	o.done[identity.ProductKey+"/"+identity.DeviceName] = identity
+	return nil
+}
+
+func (o *outputStore) Delete(productKey, deviceName string) error {
+	return fmt.Errorf("output manifest is append-only")
+}
+
+func (o *outputStore) Close() error {
+	if o.journal != nil {
+		return o.journal.Close()
+	}
+	if o.file != nil {
+		return o.file.Close()
+	}
+	return nil
+}
+
+// writeFailures writes the failure report as CSV.
+func writeFailures(path string, failures []Failure) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"productKey", "deviceName", "code", "error"})
+	for _, f := range failures {
+		writer.Write([]string{f.Device.ProductKey, f.Device.DeviceName, f.Code, f.Err.Error()})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/logging"
+	"github.com/iot-go-sdk/pkg/mqtt"
+	"github.com/iot-go-sdk/pkg/rrpc"
+)
+
+// replyTimeout is how long a report waits for the platform's reply before
+// it counts as unanswered.
+const replyTimeout = 30 * time.Second
+
+// device is one simulated device on a lightweight MQTT client.
+type device struct {
+	config *config.Config
+	model  *ThingModel
+	stats  *stats
+	qos    byte
+	logger logging.Logger
+	client *mqtt.Client
+	rand   *rand.Rand
+
+	mutex   sync.Mutex
+	values  map[string]interface{}
+	pending map[string]time.Time
+	seq     int64
+}
+
+func newDevice(cfg *config.Config, model *ThingModel, s *stats, qos byte, logger logging.Logger, seed int64) *device {
+	d := &device{
+		config:  cfg,
+		model:   model,
+		stats:   s,
+		qos:     qos,
+		logger:  logger.With("deviceName", cfg.Device.DeviceName),
+		rand:    rand.New(rand.NewSource(seed)),
+		values:  make(map[string]interface{}),
+		pending: make(map[string]time.Time),
+	}
+	for _, p := range model.Properties {
+		d.values[p.Identifier] = p.random(d.rand)
+	}
+	return d
+}
+
+func (d *device) topic(suffix string) string {
+	return fmt.Sprintf("$SYS/%s/%s/%s", d.config.Device.ProductKey, d.config.Device.DeviceName, suffix)
+}
+
+// connect connects the device and subscribes to commands.
+func (d *device) connect() error {
+	d.client = mqtt.NewClient(d.config)
+	d.client.SetLogger(d.logger)
+
+	start := time.Now()
+	if err := d.client.Connect(); err != nil {
+		d.stats.connectErrors.Add(1)
+		return err
+	}
+	d.stats.connect.observe(time.Since(start))
+	d.stats.connected.Add(1)
+
+	subscriptions := map[string]mqtt.MessageHandler{
+		d.topic("property/post/reply"): d.handleReply,
+		d.topic("event/post/reply"):    d.handleReply,
+		d.topic("property/set"):        d.handlePropertySet,
+		d.topic("service/+/invoke"):    d.handleService,
+	}
+	for topic, handler := range subscriptions {
+		if err := d.client.Subscribe(topic, d.qos, handler); err != nil {
+			d.client.Disconnect()
+			return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+		}
+	}
+
+	rrpcClient := rrpc.NewRRPCClient(d.client, d.config.Device.ProductKey, d.config.Device.DeviceName)
+	rrpcClient.SetLogger(d.logger)
+	for _, action := range d.model.Actions {
+		action := action
+		rrpcClient.Register(action.Identifier, func(ctx context.Context, request *rrpc.RRPCRequest) (interface{}, error) {
+			output, err := d.invoke(&action, request.Params)
+			if err != nil {
+				d.stats.commandErrors.Add(1)
+				return nil, rrpc.Errorf(rrpc.CodeBadRequest, "%v", err)
+			}
+			d.stats.commands.Add(1)
+			return output, nil
+		})
+	}
+	if err := rrpcClient.Start(); err != nil {
+		d.client.Disconnect()
+		return err
+	}
+	return nil
+}
+
+func (d *device) disconnect() {
+	d.mutex.Lock()
+	d.stats.unanswered.Add(int64(len(d.pending)))
+	d.pending = make(map[string]time.Time)
+	d.mutex.Unlock()
+	d.client.Disconnect()
+}
+
+// run reports properties and events until ctx is done. Each stream starts
+// at a random phase so a fleet does not publish in lockstep.
+func (d *device) run(ctx context.Context, propertyInterval, eventInterval time.Duration) {
+	var wg sync.WaitGroup
+	loop := func(interval time.Duration, report func(context.Context)) {
+		defer wg.Done()
+		d.mutex.Lock()
+		phase := time.Duration(d.rand.Int63n(int64(interval)))
+		d.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(phase):
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			report(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+
+	if propertyInterval > 0 && len(d.model.Properties) > 0 {
+		wg.Add(1)
+		go loop(propertyInterval, d.reportProperties)
+	}
+	if eventInterval > 0 && len(d.model.Events) > 0 {
+		wg.Add(1)
+		go loop(eventInterval, d.reportEvent)
+	}
+	wg.Wait()
+}
+
+func (d *device) nextID() string {
+	d.seq++
+	return strconv.FormatInt(d.seq, 10)
+}
+
+func (d *device) reportProperties(ctx context.Context) {
+	now := time.Now()
+	params := make(map[string]interface{})
+	d.mutex.Lock()
+	for _, p := range d.model.Properties {
+		d.values[p.Identifier] = p.next(d.values[p.Identifier], d.rand)
+		params[p.Identifier] = map[string]interface{}{
+			"value": fmt.Sprint(d.values[p.Identifier]),
+			"time":  now.Unix(),
+		}
+	}
+	id := d.nextID()
+	d.mutex.Unlock()
+
+	d.post(ctx, d.topic("property/post"), id, params)
+}
+
+func (d *device) reportEvent(ctx context.Context) {
+	now := time.Now()
+	d.mutex.Lock()
+	event := d.model.Events[d.rand.Intn(len(d.model.Events))]
+	value := make(map[string]interface{})
+	for _, p := range event.OutputData {
+		value[p.Identifier] = p.random(d.rand)
+	}
+	id := d.nextID()
+	d.mutex.Unlock()
+
+	params := map[string]interface{}{
+		event.Identifier: map[string]interface{}{"value": value, "time": now.UnixMilli()},
+	}
+	d.post(ctx, d.topic("event/post"), id, params)
+}
+
+// post publishes a report and tracks it until the platform replies.
+func (d *device) post(ctx context.Context, topic, id string, params map[string]interface{}) {
+	payload, _ := json.Marshal(map[string]interface{}{"id": id, "version": "1.0", "params": params})
+
+	d.mutex.Lock()
+	for pendingID, sent := range d.pending {
+		if time.Since(sent) > replyTimeout {
+			delete(d.pending, pendingID)
+			d.stats.unanswered.Add(1)
+		}
+	}
+	d.pending[id] = time.Now()
+	d.mutex.Unlock()
+
+	delivery := d.client.PublishAsync(ctx, topic, payload, d.qos, false)
+	if err := delivery.Wait(ctx); err != nil {
+		d.mutex.Lock()
+		delete(d.pending, id)
+		d.mutex.Unlock()
+		if ctx.Err() == nil {
+			d.stats.publishErrors.Add(1)
+			d.logger.Debug("publish failed", "topic", topic, "error", err)
+		}
+		return
+	}
+	d.stats.published.Add(1)
+	d.stats.publish.observe(delivery.Latency())
+}
+
+func (d *device) handleReply(topic string, payload []byte) {
+	var reply struct {
+		ID   string `json:"id"`
+		Code int    `json:"code"`
+	}
+	if err := json.Unmarshal(payload, &reply); err != nil {
+		return
+	}
+	d.mutex.Lock()
+	sent, ok := d.pending[reply.ID]
+	delete(d.pending, reply.ID)
+	d.mutex.Unlock()
+	if !ok {
+		return
+	}
+	d.stats.reply.observe(time.Since(sent))
+	if reply.Code != 200 {
+		d.stats.replyErrors.Add(1)
+		d.logger.Debug("report refused", "topic", topic, "code", reply.Code)
+	}
+}
+
+type command struct {
+	ID     string                 `json:"id"`
+	Params map[string]interface{} `json:"params"`
+}
+
+func (d *device) respond(topic, id string, code int, data interface{}, message string) {
+	if code == 200 {
+		d.stats.commands.Add(1)
+	} else {
+		d.stats.commandErrors.Add(1)
+	}
+	response := map[string]interface{}{"id": id, "code": code, "data": data}
+	if message != "" {
+		response["message"] = message
+	}
+	payload, _ := json.Marshal(response)
+	if err := d.client.Publish(topic, payload, 0, false); err != nil {
+		d.logger.Debug("failed to respond", "topic", topic, "error", err)
+	}
+}
+
+func (d *device) handlePropertySet(topic string, payload []byte) {
+	var request command
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return
+	}
+	if err := d.setProperties(request.Params); err != nil {
+		d.respond(topic+"/reply", request.ID, 400, map[string]interface{}{}, err.Error())
+		return
+	}
+	d.respond(topic+"/reply", request.ID, 200, map[string]interface{}{}, "")
+}
+
+func (d *device) handleService(topic string, payload []byte) {
+	var request command
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return
+	}
+	// $SYS/{pk}/{dn}/service/{identifier}/invoke
+	identifier := strings.Split(topic, "/")[4]
+	action := d.model.action(identifier)
+	if action == nil {
+		d.respond(topic+"/reply", request.ID, 404, map[string]interface{}{}, fmt.Sprintf("unknown service %s", identifier))
+		return
+	}
+	output, err := d.invoke(action, request.Params)
+	if err != nil {
+		d.respond(topic+"/reply", request.ID, 400, map[string]interface{}{}, err.Error())
+		return
+	}
+	d.respond(topic+"/reply", request.ID, 200, output, "")
+}
+
+// invoke validates the inputs of an action, applies them to properties of
+// the same name and returns its outputs, taken from properties where they
+// exist.
+func (d *device) invoke(action *Action, params map[string]interface{}) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{})
+	for _, p := range action.InputData {
+		value, ok := params[p.Identifier]
+		if !ok {
+			continue
+		}
+		if _, ok := p.coerce(value); !ok {
+			return nil, fmt.Errorf("invalid value %v for %s", value, p.Identifier)
+		}
+		if d.property(p.Identifier) != nil {
+			inputs[p.Identifier] = value
+		}
+	}
+	if err := d.setProperties(inputs); err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	output := make(map[string]interface{})
+	for _, p := range action.OutputData {
+		if value, ok := d.values[p.Identifier]; ok {
+			output[p.Identifier] = value
+		} else {
+			output[p.Identifier] = p.random(d.rand)
+		}
+	}
+	return output, nil
+}
+
+// setProperties applies values to properties, all or none. Values may be
+// plain or wrapped as {"value": ...}.
+func (d *device) setProperties(params map[string]interface{}) error {
+	values := make(map[string]interface{})
+	for name, value := range params {
+		if wrapped, ok := value.(map[string]interface{}); ok {
+			value = wrapped["value"]
+		}
+		param := d.property(name)
+		if param == nil {
+			return fmt.Errorf("unknown property %s", name)
+		}
+		coerced, ok := param.coerce(value)
+		if !ok {
+			return fmt.Errorf("invalid value %v for %s", value, name)
+		}
+		values[name] = coerced
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for name, value := range values {
+		d.values[name] = value
+	}
+	return nil
+}
+
+func (d *device) property(identifier string) *Param {
+	for i := range d.model.Properties {
+		if d.model.Properties[i].Identifier == identifier {
+			return &d.model.Properties[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Command iot-simulator runs a fleet of simulated devices against a broker
+// for load and soak testing.
+//
+//	iot-simulator -model 物模型.json -count 500 -property-interval 5s
+//
+// Each device reports properties of the thing model as a random walk within
+// their specs and raises random events, answers property set, service
+// invoke and RRPC requests, and measures how long the platform takes to
+// acknowledge and reply. A summary of connect latency, publish throughput,
+// latency percentiles and error counts is printed on exit.
+//
+// Devices are named <prefix>0001, <prefix>0002, ... and share the device
+// secret from IOT_DEVICE_SECRET, or are read from a manifest as written by
+// iot-provision. Broker and TLS settings come from the IOT_* environment
+// variables of package config. With -local the fleet runs against an
+// embedded fake platform instead, see package testplatform.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/logging"
+	"github.com/iot-go-sdk/pkg/testplatform"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+func run(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("iot-simulator", flag.ContinueOnError)
+	modelPath := fs.String("model", "", "thing model JSON")
+	count := fs.Int("count", 10, "number of devices")
+	prefix := fs.String("prefix", "sim-", "device name prefix")
+	manifest := fs.String("manifest", "", "device manifest (.csv or .jsonl with deviceSecret), overrides -count")
+	propertyInterval := fs.Duration("property-interval", 10*time.Second, "property report interval per device, 0 to disable")
+	eventInterval := fs.Duration("event-interval", time.Minute, "event interval per device, 0 to disable")
+	qos := fs.Int("qos", 1, "QoS of reports: 0 or 1")
+	ramp := fs.Float64("ramp", 20, "devices connected per second, 0 for no limit")
+	duration := fs.Duration("duration", 0, "run time, 0 to run until interrupted")
+	reportInterval := fs.Duration("report", 10*time.Second, "progress log interval, 0 to disable")
+	local := fs.Bool("local", false, "run against an embedded fake platform")
+	verbose := fs.Bool("v", false, "log device activity")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *modelPath == "" || *count < 1 || *qos < 0 || *qos > 1 || *ramp < 0 {
+		fs.Usage()
+		return 2
+	}
+
+	level, deviceLevel := slog.LevelInfo, slog.LevelWarn
+	if *verbose {
+		level, deviceLevel = slog.LevelDebug, slog.LevelDebug
+	}
+	logger := logging.Redacting(logging.NewSlog(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))))
+	deviceLogger := logging.NewSlog(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: deviceLevel})))
+	logging.SetDefault(deviceLogger)
+
+	model, err := loadModel(*modelPath)
+	if err != nil {
+		logger.Error("failed to load thing model", "error", err)
+		return 1
+	}
+
+	base := config.NewConfig()
+	base.LoadFromEnv()
+	if *local && base.Device.ProductKey == "" {
+		base.Device.ProductKey = "sim"
+	}
+	var identities []identity
+	if *manifest != "" {
+		if identities, err = readManifest(*manifest); err != nil {
+			logger.Error("failed to read manifest", "error", err)
+			return 1
+		}
+	} else {
+		if base.Device.ProductKey == "" {
+			logger.Error("IOT_PRODUCT_KEY is required without -manifest")
+			return 2
+		}
+		identities = generateIdentities(base, *prefix, *count)
+	}
+
+	if *local {
+		platform, err := testplatform.Start()
+		if err != nil {
+			logger.Error("failed to start local platform", "error", err)
+			return 1
+		}
+		defer platform.Close()
+		for i := range identities {
+			if identities[i].DeviceSecret == "" {
+				identities[i].DeviceSecret = "simulator-secret"
+			}
+			platform.AddDevice(identities[i].ProductKey, identities[i].DeviceName, identities[i].DeviceSecret)
+		}
+		local := platform.Config("", "")
+		base.MQTT = local.MQTT
+		base.TLS = local.TLS
+		logger.Info("local platform started", "broker", platform.BrokerAddr())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	s := newStats()
+	if *reportInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(*reportInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_, p50, _, p99, _ := s.reply.percentiles()
+					logger.Info("progress",
+						"connected", s.connected.Load(),
+						"published", s.published.Load(),
+						"commands", s.commands.Load(),
+						"errors", s.errors(),
+						"replyP50", p50, "replyP99", p99)
+				}
+			}
+		}()
+	}
+
+	simulate(ctx, identities, base, model, s, byte(*qos), *ramp, *propertyInterval, *eventInterval, deviceLogger)
+
+	s.report(stdout)
+	if s.connectErrors.Load() > 0 || s.publishErrors.Load() > 0 {
+		return 1
+	}
+	return 0
+}
+
+// simulate connects the devices at ramp per second and runs them until ctx
+// is done.
+func simulate(ctx context.Context, identities []identity, base *config.Config, model *ThingModel, s *stats,
+	qos byte, ramp float64, propertyInterval, eventInterval time.Duration, logger logging.Logger) {
+	var tokens <-chan time.Time
+	if ramp > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / ramp))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	var wg sync.WaitGroup
+	for i, id := range identities {
+		if tokens != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-tokens:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		d := newDevice(id.config(base), model, s, qos, logger, time.Now().UnixNano()+int64(i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.connect(); err != nil {
+				logger.Warn("device failed to connect", "deviceName", d.config.Device.DeviceName, "error", err)
+				return
+			}
+			d.run(ctx, propertyInterval, eventInterval)
+			d.disconnect()
+		}()
+	}
+	wg.Wait()
+}
+
+// generateIdentities names count devices after prefix, with the product key
+// and device secret of base.
+func generateIdentities(base *config.Config, prefix string, count int) []identity {
+	width := len(fmt.Sprint(count))
+	if width < 4 {
+		width = 4
+	}
+	identities := make([]identity, count)
+	for i := range identities {
+		identities[i] = identity{
+			ProductKey:   base.Device.ProductKey,
+			DeviceName:   fmt.Sprintf("%s%0*d", prefix, width, i+1),
+			DeviceSecret: base.Device.DeviceSecret,
+		}
+	}
+	return identities
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/testplatform"
+)
+
+const ovenModel = "../../examples/framework/simple/带调温的电烤炉物模型.json"
+
+func TestSimulatorAgainstPlatform(t *testing.T) {
+	platform, err := testplatform.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer platform.Close()
+	for _, dn := range []string{"sim-0001", "sim-0002", "sim-0003"} {
+		platform.AddDevice("pk", dn, "secret")
+	}
+	host, port, _ := net.SplitHostPort(platform.BrokerAddr())
+	t.Setenv("IOT_PRODUCT_KEY", "pk")
+	t.Setenv("IOT_DEVICE_SECRET", "secret")
+	t.Setenv("IOT_MQTT_HOST", host)
+	t.Setenv("IOT_MQTT_PORT", port)
+	t.Setenv("IOT_MQTT_SECURE_MODE", "3")
+
+	var stdout bytes.Buffer
+	done := make(chan int)
+	go func() {
+		done <- run([]string{"-model", ovenModel, "-count", "3", "-ramp", "0", "-report", "0",
+			"-property-interval", "50ms", "-event-interval", "100ms", "-duration", "2s"}, &stdout)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := platform.WaitForSubscriber(ctx, "/sys/pk/sim-0001/rrpc/request/1"); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := platform.InvokeService(ctx, "pk", "sim-0001", "set_temperature", map[string]interface{}{"temperature": 180})
+	if err != nil || reply.Code != 200 {
+		t.Fatalf("service reply = %+v, err = %v", reply, err)
+	}
+	reply, err = platform.SetProperties(ctx, "pk", "sim-0001", map[string]interface{}{"target_temperature": 500})
+	if err != nil || reply.Code != 400 {
+		t.Fatalf("out of range property set reply = %+v, err = %v", reply, err)
+	}
+	response, err := platform.RRPC(ctx, "pk", "sim-0001", []byte(`{"id":"1","version":"1.0","method":"toggle_door","params":{}}`))
+	if err != nil || !strings.Contains(string(response), "door_status") {
+		t.Fatalf("RRPC response = %s, err = %v", response, err)
+	}
+
+	if code := <-done; code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stdout.String())
+	}
+	if n := len(platform.Messages("$SYS/pk/+/property/post")); n < 3 {
+		t.Fatalf("%d property reports", n)
+	}
+	if len(platform.Messages("$SYS/pk/+/event/post")) == 0 {
+		t.Fatal("no events reported")
+	}
+	report := stdout.String()
+	if !strings.Contains(report, "devices connected 3, connect errors 0") || !strings.Contains(report, "commands handled 2, command errors 1") {
+		t.Fatalf("report:\n%s", report)
+	}
+}
+
+func TestPropertyStreamStaysWithinSpecs(t *testing.T) {
+	model, err := loadModel(ovenModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, p := range model.Properties {
+		value := p.random(r)
+		for i := 0; i < 1000; i++ {
+			value = p.next(value, r)
+			if _, ok := p.coerce(value); !ok {
+				t.Fatalf("%s: generated invalid value %v", p.Identifier, value)
+			}
+		}
+	}
+
+	temperature := model.Properties[0]
+	if _, ok := temperature.coerce(301.0); ok {
+		t.Fatal("accepted a value above max")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/iot-go-sdk/pkg/config"
+)
+
+// identity is a simulated device's credentials: a device secret, or the
+// connect credentials issued by registration without pre-registration.
+type identity struct {
+	ProductKey   string `json:"productKey"`
+	DeviceName   string `json:"deviceName"`
+	DeviceSecret string `json:"deviceSecret"`
+	ClientID     string `json:"clientId"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+}
+
+// config returns the device config, a copy of base with the identity.
+func (id identity) config(base *config.Config) *config.Config {
+	cfg := *base
+	cfg.Device.ProductKey = id.ProductKey
+	cfg.Device.DeviceName = id.DeviceName
+	cfg.Device.DeviceSecret = id.DeviceSecret
+	cfg.Device.DeviceSecretKey, cfg.Device.DeviceSecretCommand = nil, ""
+	cfg.MQTT.ClientID = id.ClientID
+	cfg.MQTT.Username = id.Username
+	cfg.MQTT.Password = id.Password
+	return &cfg
+}
+
+// readManifest reads devices from an iot-provision output manifest: CSV
+// with a header row naming the columns, or JSON lines.
+func readManifest(path string) ([]identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []identity
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		identities, err = readCSVManifest(data)
+	case ".json", ".jsonl":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var id identity
+			if err = decoder.Decode(&id); err == io.EOF {
+				err = nil
+				break
+			} else if err != nil {
+				break
+			}
+			identities = append(identities, id)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q: want .csv or .jsonl", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+
+	for i, id := range identities {
+		if id.ProductKey == "" || id.DeviceName == "" {
+			return nil, fmt.Errorf("invalid manifest %s: entry %d lacks productKey or deviceName", path, i+1)
+		}
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no devices", path)
+	}
+	return identities, nil
+}
+
+func readCSVManifest(data []byte) ([]identity, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	identities := make([]identity, 0, len(records)-1)
+	for _, record := range records[1:] {
+		identities = append(identities, identity{
+			ProductKey:   field(record, "productkey"),
+			DeviceName:   field(record, "devicename"),
+			DeviceSecret: field(record, "devicesecret"),
+			ClientID:     field(record, "clientid"),
+			Username:     field(record, "username"),
+			Password:     field(record, "password"),
+		})
+	}
+	return identities, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ThingModel is a product's thing model as exported by the platform, see
+// examples/framework/simple/带调温的电烤炉物模型.json.
+type ThingModel struct {
+	Properties []Param  `json:"properties"`
+	Events     []Event  `json:"events"`
+	Actions    []Action `json:"actions"`
+}
+
+// Param is a property or an input or output parameter.
+type Param struct {
+	Identifier string   `json:"identifier"`
+	Name       string   `json:"name"`
+	DataType   DataType `json:"data_type"`
+}
+
+type DataType struct {
+	Type  string `json:"type"`
+	Specs Specs  `json:"specs"`
+}
+
+// Specs constrain values. Enum is an object mapping values to names for
+// enum types and an empty string otherwise.
+type Specs struct {
+	Min    *float64        `json:"min"`
+	Max    *float64        `json:"max"`
+	Length int             `json:"length"`
+	Enum   json.RawMessage `json:"enum"`
+}
+
+type Event struct {
+	Identifier string  `json:"identifier"`
+	EventType  string  `json:"event_type"`
+	OutputData []Param `json:"output_data"`
+}
+
+type Action struct {
+	Identifier string  `json:"identifier"`
+	InputData  []Param `json:"input_data"`
+	OutputData []Param `json:"output_data"`
+}
+
+func loadModel(path string) (*ThingModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var model ThingModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("invalid thing model %s: %w", path, err)
+	}
+	if len(model.Properties) == 0 && len(model.Events) == 0 {
+		return nil, fmt.Errorf("thing model %s has no properties or events", path)
+	}
+	return &model, nil
+}
+
+func (m *ThingModel) action(identifier string) *Action {
+	for i := range m.Actions {
+		if m.Actions[i].Identifier == identifier {
+			return &m.Actions[i]
+		}
+	}
+	return nil
+}
+
+func (p Param) bounds() (float64, float64) {
+	min, max := 0.0, 100.0
+	if p.DataType.Specs.Min != nil {
+		min = *p.DataType.Specs.Min
+	}
+	if p.DataType.Specs.Max != nil {
+		max = *p.DataType.Specs.Max
+	}
+	return min, math.Max(min, max)
+}
+
+func (p Param) enumValues() []int {
+	var names map[string]string
+	if json.Unmarshal(p.DataType.Specs.Enum, &names) != nil {
+		return nil
+	}
+	var values []int
+	for key := range names {
+		if v, err := strconv.Atoi(key); err == nil {
+			values = append(values, v)
+		}
+	}
+	sort.Ints(values)
+	return values
+}
+
+// random returns a random valid value.
+func (p Param) random(r *rand.Rand) interface{} {
+	switch p.DataType.Type {
+	case "float", "double":
+		min, max := p.bounds()
+		return math.Round((min+r.Float64()*(max-min))*10) / 10
+	case "int", "int32", "int64":
+		min, max := p.bounds()
+		return int64(min) + r.Int63n(int64(max-min)+1)
+	case "bool":
+		return r.Intn(2) == 1
+	case "enum":
+		if values := p.enumValues(); len(values) > 0 {
+			return values[r.Intn(len(values))]
+		}
+		return 0
+	case "date":
+		return strconv.FormatInt(time.Now().UnixMilli(), 10)
+	default:
+		length := p.DataType.Specs.Length
+		if length <= 0 || length > 8 {
+			length = 8
+		}
+		b := make([]byte, length)
+		for i := range b {
+			b[i] = byte('a' + r.Intn(26))
+		}
+		return string(b)
+	}
+}
+
+// next returns the value following current in a plausible stream: numbers
+// drift by up to 2% of their range, other values change occasionally.
+func (p Param) next(current interface{}, r *rand.Rand) interface{} {
+	switch p.DataType.Type {
+	case "float", "double", "int", "int32", "int64":
+		value, ok := toFloat(current)
+		if !ok {
+			return p.random(r)
+		}
+		min, max := p.bounds()
+		value = math.Max(min, math.Min(max, value+(r.Float64()*2-1)*(max-min)*0.02))
+		if p.DataType.Type == "float" || p.DataType.Type == "double" {
+			return math.Round(value*10) / 10
+		}
+		return int64(math.Round(value))
+	case "date":
+		return p.random(r)
+	default:
+		if r.Intn(20) == 0 {
+			return p.random(r)
+		}
+		return current
+	}
+}
+
+// coerce converts a value set by the platform to the parameter type,
+// reporting false when it does not fit.
+func (p Param) coerce(value interface{}) (interface{}, bool) {
+	switch p.DataType.Type {
+	case "float", "double", "int", "int32", "int64", "enum":
+		f, ok := toFloat(value)
+		if !ok {
+			return nil, false
+		}
+		if p.DataType.Type != "enum" {
+			if min, max := p.bounds(); f < min || f > max {
+				return nil, false
+			}
+		}
+		if p.DataType.Type == "float" || p.DataType.Type == "double" {
+			return f, true
+		}
+		return int64(f), true
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		}
+		f, ok := toFloat(value)
+		return f != 0, ok
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
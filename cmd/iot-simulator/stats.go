@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxSamples bounds the memory of a latency metric in long soak runs.
+const maxSamples = 10000
+
+// latency keeps a uniform sample of observed durations for percentiles.
+type latency struct {
+	mutex   sync.Mutex
+	count   int64
+	max     time.Duration
+	samples []time.Duration
+	rand    *rand.Rand
+}
+
+func newLatency() *latency {
+	return &latency{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (l *latency) observe(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.count++
+	if d > l.max {
+		l.max = d
+	}
+	// Reservoir sampling keeps every observation equally likely
+	if len(l.samples) < maxSamples {
+		l.samples = append(l.samples, d)
+	} else if i := l.rand.Int63n(l.count); i < maxSamples {
+		l.samples[i] = d
+	}
+}
+
+// percentiles returns p50, p90, p99 and the maximum.
+func (l *latency) percentiles() (count int64, p50, p90, p99, max time.Duration) {
+	l.mutex.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	count, max = l.count, l.max
+	l.mutex.Unlock()
+	if len(sorted) == 0 {
+		return
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	return count, at(0.5), at(0.9), at(0.99), max
+}
+
+// stats are shared by all simulated devices.
+type stats struct {
+	start time.Time
+
+	connect *latency
+	// publish is the time to PUBACK, or to the write with QoS 0; reply is
+	// the time until the platform answered a property or event post
+	publish *latency
+	reply   *latency
+
+	connected     atomic.Int64
+	connectErrors atomic.Int64
+	published     atomic.Int64
+	publishErrors atomic.Int64
+	replyErrors   atomic.Int64
+	commands      atomic.Int64
+	commandErrors atomic.Int64
+	unanswered    atomic.Int64
+}
+
+func newStats() *stats {
+	return &stats{start: time.Now(), connect: newLatency(), publish: newLatency(), reply: newLatency()}
+}
+
+func (s *stats) errors() int64 {
+	return s.connectErrors.Load() + s.publishErrors.Load() + s.replyErrors.Load() + s.commandErrors.Load()
+}
+
+// report writes a summary of the run so far.
+func (s *stats) report(w io.Writer) {
+	elapsed := time.Since(s.start)
+	published := s.published.Load()
+	fmt.Fprintf(w, "elapsed %s, devices connected %d, connect errors %d\n",
+		elapsed.Round(time.Second), s.connected.Load(), s.connectErrors.Load())
+	fmt.Fprintf(w, "published %d (%.1f msg/s), publish errors %d, error replies %d, unanswered %d\n",
+		published, float64(published)/elapsed.Seconds(), s.publishErrors.Load(), s.replyErrors.Load(), s.unanswered.Load())
+	fmt.Fprintf(w, "commands handled %d, command errors %d\n", s.commands.Load(), s.commandErrors.Load())
+	fmt.Fprintf(w, "%-8s %8s %10s %10s %10s %10s\n", "latency", "count", "p50", "p90", "p99", "max")
+	for _, metric := range []struct {
+		name string
+		l    *latency
+	}{{"connect", s.connect}, {"publish", s.publish}, {"reply", s.reply}} {
+		count, p50, p90, p99, max := metric.l.percentiles()
+		fmt.Fprintf(w, "%-8s %8d %10s %10s %10s %10s\n", metric.name, count,
+			p50.Round(time.Microsecond), p90.Round(time.Microsecond), p99.Round(time.Microsecond), max.Round(time.Microsecond))
+	}
+}
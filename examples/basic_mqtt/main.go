@@ -16,12 +16,23 @@ func main() {
 
 	cfg.Device.ProductKey = "QLTMkOfW"
 	cfg.Device.DeviceName = "THYYENG5wd"
-	cfg.Device.DeviceSecret = "1kaFD2aghSTw4aKV"
 
 	cfg.MQTT.Host = "121.40.253.224"
 	cfg.MQTT.Port = 1883
 	cfg.MQTT.UseTLS = false
 
+	// 设备密钥来自 IOT_DEVICE_SECRET 或凭据存储（IOT_CREDSTORE_PATH），不写在代码里
+	cfg.LoadFromEnv()
+	store, err := cfg.OpenCredentialStore()
+	if err != nil {
+		log.Fatalf("Failed to open credential store: %v", err)
+	}
+	if store != nil {
+		if err := cfg.LoadIdentity(store); err != nil {
+			log.Fatalf("Failed to load device identity: %v", err)
+		}
+	}
+
 	client := mqtt.NewClient(cfg)
 
 	if err := client.Connect(); err != nil {
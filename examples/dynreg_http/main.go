@@ -17,6 +17,13 @@ func main() {
 	cfg.MQTT.Host = "iot.know-act.com"
 	cfg.MQTT.Port = 80
 
+	// 注册请求默认使用 https://<MQTT 主机>/auth/register/device；
+	// 平台未开启 HTTPS 时可设置 cfg.DynReg.Scheme = "http"
+
+	// 注册结果自动写入加密的凭据存储（密钥由本机 machine-id 派生）
+	cfg.CredentialStore.Path = "device_identity.enc"
+	cfg.LoadFromEnv()
+
 	client := dynreg.NewHTTPDynRegClient(cfg)
 
 	log.Println("Starting HTTP dynamic registration...")
@@ -27,7 +34,7 @@ func main() {
 	}
 
 	log.Printf("Dynamic registration successful!")
-	log.Printf("Device Secret: %s", deviceSecret)
+	log.Printf("Device identity saved to %s", cfg.CredentialStore.Path)
 
 	cfg.Device.DeviceSecret = deviceSecret
 
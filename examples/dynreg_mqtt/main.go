@@ -21,6 +21,10 @@ func main() {
 
 	cfg.TLS.SkipVerify = true // 跳过证书验证（自签名证书）
 
+	// 注册结果自动写入加密的凭据存储（密钥由本机 machine-id 派生）
+	cfg.CredentialStore.Path = "device_identity.enc"
+	cfg.LoadFromEnv()
+
 	client := dynreg.NewMQTTDynRegClient(cfg)
 
 	log.Println("Starting MQTT dynamic registration...")
@@ -34,9 +38,9 @@ func main() {
 	}
 
 	log.Printf("MQTT dynamic registration successful!")
+	log.Printf("Device identity saved to %s", cfg.CredentialStore.Path)
 
 	if responseData.DeviceSecret != "" {
-		log.Printf("Device Secret: %s", responseData.DeviceSecret)
 		cfg.Device.DeviceSecret = responseData.DeviceSecret
 	}
 
@@ -51,7 +55,6 @@ func main() {
 	}
 
 	if responseData.Password != "" {
-		log.Printf("Password: %s", responseData.Password)
 		cfg.MQTT.Password = responseData.Password
 	}
 
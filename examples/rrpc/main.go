@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -49,19 +50,28 @@ func main() {
 		return json.Marshal(response)
 	})
 
-	rrpcClient.RegisterHandler("GetStatus", func(requestId string, payload []byte) ([]byte, error) {
-		log.Printf("Received GetStatus request (ID: %s): %s", requestId, string(payload))
+	rrpcClient.Register("GetStatus", func(ctx context.Context, request *rrpc.RRPCRequest) (interface{}, error) {
+		log.Printf("Received GetStatus request (ID: %s): %v", request.ID, request.Params)
 
-		response := map[string]interface{}{
+		return map[string]interface{}{
 			"status":      "online",
 			"temperature": 25.6,
 			"humidity":    60.3,
 			"timestamp":   1234567890,
-		}
-
-		return json.Marshal(response)
+		}, nil
 	})
 
+	type brightness struct {
+		Level int `json:"level"`
+	}
+	rrpcClient.Register("SetBrightness", rrpc.Bind(func(ctx context.Context, request *rrpc.RRPCRequest, params brightness) (interface{}, error) {
+		if params.Level < 0 || params.Level > 100 {
+			return nil, rrpc.Errorf(rrpc.CodeBadRequest, "level %d out of range", params.Level)
+		}
+		log.Printf("Brightness set to %d", params.Level)
+		return params, nil
+	}))
+
 	if err := rrpcClient.Start(); err != nil {
 		log.Fatalf("Failed to start RRPC client: %v", err)
 	}
@@ -2,10 +2,15 @@ module github.com/iot-go-sdk
 
 go 1.21
 
-require github.com/eclipse/paho.mqtt.golang v1.4.3
-
 require (
-	github.com/gorilla/websocket v1.5.0 // indirect
-	golang.org/x/net v0.8.0 // indirect
-	golang.org/x/sync v0.1.0 // indirect
+	github.com/BurntSushi/toml v1.3.2
+	github.com/eclipse/paho.golang v0.22.0
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/crypto v0.25.0
+	golang.org/x/net v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
+	software.sslmate.com/src/go-pkcs12 v0.4.0
 )
+
+require golang.org/x/sync v0.1.0 // indirect
@@ -1,11 +1,10 @@
 package auth
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"time"
+
+	"github.com/iot-go-sdk/pkg/clock"
 )
 
 type Credentials struct {
@@ -14,28 +13,97 @@ type Credentials struct {
 	Password string
 }
 
+// Credentials are timestamped with clock.Now, so a clock corrected by the
+// timesync package keeps them valid on devices with a wrong RTC.
 func GenerateMQTTCredentials(productKey, deviceName, deviceSecret, secureMode string) *Credentials {
-	return GenerateMQTTCredentialsAt(productKey, deviceName, deviceSecret, secureMode, time.Now())
+	return GenerateMQTTCredentialsAt(productKey, deviceName, deviceSecret, secureMode, clock.Now())
 }
 
 func GenerateMQTTCredentialsAt(productKey, deviceName, deviceSecret, secureMode string, now time.Time) *Credentials {
-	timestamp := fmt.Sprintf("%d", now.UnixMilli())
-	nonce := fmt.Sprintf("%d", now.UnixNano())
+	// The built-in sign method and layout cannot fail
+	credentials, _ := GenerateCredentialsAt(productKey, deviceName, deviceSecret, secureMode, Options{}, now)
+	return credentials
+}
 
-	clientID := fmt.Sprintf("%s.%s|timestamp=%s,_ss=1,_v=4,securemode=%s,signmethod=hmacsha256,ext=3,%s|",
-		productKey, deviceName, timestamp, secureMode, nonce)
+// Options selects the sign method and credential layout. Zero values select
+// hmacsha256 and LayoutDefault.
+type Options struct {
+	SignMethod string
+	Layout     string
+	// Clock timestamps the credentials; nil uses clock.Default()
+	Clock clock.Clock
+}
+
+func (o Options) now() time.Time {
+	if o.Clock != nil {
+		return o.Clock.Now()
+	}
+	return clock.Now()
+}
+
+// GenerateCredentials signs connect credentials with the sign method and
+// layout chosen by opts.
+func GenerateCredentials(productKey, deviceName, deviceSecret, secureMode string, opts Options) (*Credentials, error) {
+	return GenerateCredentialsAt(productKey, deviceName, deviceSecret, secureMode, opts, opts.now())
+}
+
+func GenerateCredentialsAt(productKey, deviceName, deviceSecret, secureMode string, opts Options, now time.Time) (*Credentials, error) {
+	return GenerateCredentialsWithKeyAt(productKey, deviceName, secureMode, opts, NewMemoryKeyProvider(deviceSecret), now)
+}
+
+// GenerateCredentialsWithKey is like GenerateCredentials but has key sign
+// the password, so the device secret can stay in hardware.
+func GenerateCredentialsWithKey(productKey, deviceName, secureMode string, opts Options, key KeyProvider) (*Credentials, error) {
+	return GenerateCredentialsWithKeyAt(productKey, deviceName, secureMode, opts, key, opts.now())
+}
+
+func GenerateCredentialsWithKeyAt(productKey, deviceName, secureMode string, opts Options, key KeyProvider, now time.Time) (*Credentials, error) {
+	layout, err := LookupLayout(opts.Layout)
+	if err != nil {
+		return nil, err
+	}
+	method := opts.SignMethod
+	if method == "" {
+		method = SignMethodHMACSHA256
+	}
+
+	params := SignParams{
+		ProductKey: productKey,
+		DeviceName: deviceName,
+		SecureMode: secureMode,
+		SignMethod: method,
+		Timestamp:  fmt.Sprintf("%d", now.UnixMilli()),
+		Nonce:      fmt.Sprintf("%d", now.UnixNano()),
+	}
+	password, err := key.Sign(method, layout.SignContent(params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign credentials: %w", err)
+	}
+
+	return &Credentials{
+		ClientID: layout.ClientID(params),
+		Username: layout.Username(params),
+		Password: password,
+	}, nil
+}
 
-	username := fmt.Sprintf("%s&%s", deviceName, productKey)
+// GenerateX509Credentials returns the connect credentials for a device that
+// authenticates with its X.509 client certificate. The certificate already
+// identifies the device, so no password is signed.
+func GenerateX509Credentials(productKey, deviceName, secureMode string) *Credentials {
+	return GenerateX509CredentialsAt(productKey, deviceName, secureMode, clock.Now())
+}
 
-	signContent := fmt.Sprintf("clientId%s.%sdeviceName%sproductKey%stimestamp%s",
-		productKey, deviceName, deviceName, productKey, timestamp)
+func GenerateX509CredentialsAt(productKey, deviceName, secureMode string, now time.Time) *Credentials {
+	timestamp := fmt.Sprintf("%d", now.UnixMilli())
+	nonce := fmt.Sprintf("%d", now.UnixNano())
 
-	password := calculateHMACSHA256(signContent, deviceSecret)
+	clientID := fmt.Sprintf("%s.%s|timestamp=%s,_ss=1,_v=4,securemode=%s,authtype=x509,ext=3,%s|",
+		productKey, deviceName, timestamp, secureMode, nonce)
 
 	return &Credentials{
 		ClientID: clientID,
-		Username: username,
-		Password: password,
+		Username: fmt.Sprintf("%s&%s", deviceName, productKey),
 	}
 }
 
@@ -44,12 +112,14 @@ func GenerateMQTTCredentialsLegacy(productKey, deviceName, deviceSecret string)
 }
 
 func GenerateDynRegSignature(productKey, deviceName, productSecret, random string) string {
-	signContent := fmt.Sprintf("deviceName%sproductKey%srandom%s", deviceName, productKey, random)
-	return calculateHMACSHA256(signContent, productSecret)
+	// hmacsha256 over a memory key cannot fail
+	signature, _ := GenerateDynRegSignatureWithKey(productKey, deviceName, random, NewMemoryKeyProvider(productSecret))
+	return signature
 }
 
-func calculateHMACSHA256(data, key string) string {
-	h := hmac.New(sha256.New, []byte(key))
-	h.Write([]byte(data))
-	return hex.EncodeToString(h.Sum(nil))
+// GenerateDynRegSignatureWithKey signs a dynamic registration request with
+// the product secret held by key.
+func GenerateDynRegSignatureWithKey(productKey, deviceName, random string, key KeyProvider) (string, error) {
+	signContent := fmt.Sprintf("deviceName%sproductKey%srandom%s", deviceName, productKey, random)
+	return key.Sign(SignMethodHMACSHA256, signContent)
 }
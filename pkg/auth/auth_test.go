@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -25,3 +26,78 @@ func TestGenerateMQTTCredentialsAtMatchesPlatformSignature(t *testing.T) {
 		t.Fatalf("client id = %q", credentials.ClientID)
 	}
 }
+
+func TestGenerateX509CredentialsSkipsPassword(t *testing.T) {
+	credentials := GenerateX509CredentialsAt("testProduct", "testDevice", "x509", time.UnixMilli(1700000000000))
+
+	if credentials.Password != "" {
+		t.Fatalf("password = %q, want empty", credentials.Password)
+	}
+	if credentials.Username != "testDevice&testProduct" {
+		t.Fatalf("username = %q", credentials.Username)
+	}
+	if !strings.HasPrefix(credentials.ClientID, "testProduct.testDevice|timestamp=1700000000000,_ss=1,_v=4,securemode=x509,authtype=x509,ext=3,") {
+		t.Fatalf("client id = %q", credentials.ClientID)
+	}
+	if strings.Contains(credentials.ClientID, "signmethod") {
+		t.Fatalf("client id should not carry a sign method: %q", credentials.ClientID)
+	}
+}
+
+func TestSignersMatchRFCVectors(t *testing.T) {
+	// RFC 2202 and RFC 4231 test case 2
+	tests := map[string]string{
+		SignMethodHMACMD5:    "750c783e6ab0b503eaa86e310a5db738",
+		SignMethodHMACSHA1:   "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79",
+		SignMethodHMACSHA256: "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+	}
+	for method, want := range tests {
+		signer, err := LookupSigner(method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := signer.Sign("what do ya want for nothing?", []byte("Jefe"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s signature = %s, want %s", method, got, want)
+		}
+	}
+}
+
+func TestGenerateCredentialsSignMethods(t *testing.T) {
+	tests := []struct {
+		opts     Options
+		password string
+		clientID string
+	}{
+		{Options{}, "cf010f6c3258ec9917e7cee0dad88c964d0fd125343140b6a78e715e87da3040",
+			"testProduct.testDevice|timestamp=1700000000000,_ss=1,_v=4,securemode=3,signmethod=hmacsha256,ext=3,"},
+		{Options{SignMethod: SignMethodHMACSHA1}, "9232b5397c585d8ab4c208640a3798fefa326550",
+			"testProduct.testDevice|timestamp=1700000000000,_ss=1,_v=4,securemode=3,signmethod=hmacsha1,ext=3,"},
+		{Options{SignMethod: SignMethodHMACMD5}, "5485eac94e4984394aaa4fde7aadc91b",
+			"testProduct.testDevice|timestamp=1700000000000,_ss=1,_v=4,securemode=3,signmethod=hmacmd5,ext=3,"},
+		{Options{SignMethod: SignMethodHMACSHA1, Layout: LayoutAliyun}, "9232b5397c585d8ab4c208640a3798fefa326550",
+			"testProduct.testDevice|securemode=3,signmethod=hmacsha1,timestamp=1700000000000|"},
+	}
+	for _, tt := range tests {
+		credentials, err := GenerateCredentialsAt("testProduct", "testDevice", "testSecret", "3", tt.opts, time.UnixMilli(1700000000000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if credentials.Password != tt.password {
+			t.Errorf("%+v: password = %s, want %s", tt.opts, credentials.Password, tt.password)
+		}
+		if !strings.HasPrefix(credentials.ClientID, tt.clientID) {
+			t.Errorf("%+v: client id = %s", tt.opts, credentials.ClientID)
+		}
+	}
+
+	if _, err := GenerateCredentials("p", "d", "s", "3", Options{SignMethod: "rsa"}); !errors.Is(err, ErrUnknownSignMethod) {
+		t.Fatalf("err = %v, want ErrUnknownSignMethod", err)
+	}
+	if _, err := GenerateCredentials("p", "d", "s", "3", Options{Layout: "nope"}); !errors.Is(err, ErrUnknownLayout) {
+		t.Fatalf("err = %v, want ErrUnknownLayout", err)
+	}
+}
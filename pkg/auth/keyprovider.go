@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyProvider performs the keyed sign operation with a secret it holds, so
+// the secret does not have to live in process memory. Implementations can
+// delegate to a secure element, TPM or HSM.
+type KeyProvider interface {
+	// Sign returns the lowercase hex signature of content for the sign
+	// method, e.g. "hmacsha256"
+	Sign(method, content string) (string, error)
+}
+
+// NewMemoryKeyProvider signs with a secret held in memory using the
+// registered Signer for each method. It is the default for DeviceSecret and
+// is useful in tests.
+func NewMemoryKeyProvider(secret string) KeyProvider {
+	return memoryKey(secret)
+}
+
+type memoryKey []byte
+
+func (k memoryKey) Sign(method, content string) (string, error) {
+	signer, err := LookupSigner(method)
+	if err != nil {
+		return "", err
+	}
+	return signer.Sign(content, k)
+}
+
+// PKCS#11 HMAC mechanisms.
+const (
+	PKCS11MechanismMD5HMAC    uint = 0x211
+	PKCS11MechanismSHA1HMAC   uint = 0x221
+	PKCS11MechanismSHA256HMAC uint = 0x251
+)
+
+// PKCS11Session is the part of a PKCS#11 session used for signing.
+// Middleware exposing a secure element through PKCS#11 (for example via
+// github.com/miekg/pkcs11) implements it with a few lines of glue.
+type PKCS11Session interface {
+	// FindKey returns the handle of the secret key object labelled label
+	FindKey(label string) (uint, error)
+	// Sign runs C_SignInit with mechanism and key, then C_Sign over data
+	Sign(mechanism, key uint, data []byte) ([]byte, error)
+}
+
+// PKCS11KeyProvider signs with a secret key object stored in a PKCS#11
+// token. The key handle is looked up on first use and cached.
+type PKCS11KeyProvider struct {
+	session PKCS11Session
+	label   string
+
+	mutex  sync.Mutex
+	handle uint
+	found  bool
+}
+
+func NewPKCS11KeyProvider(session PKCS11Session, label string) *PKCS11KeyProvider {
+	return &PKCS11KeyProvider{session: session, label: label}
+}
+
+func (p *PKCS11KeyProvider) Sign(method, content string) (string, error) {
+	var mechanism uint
+	switch method {
+	case "", SignMethodHMACSHA256:
+		mechanism = PKCS11MechanismSHA256HMAC
+	case SignMethodHMACSHA1:
+		mechanism = PKCS11MechanismSHA1HMAC
+	case SignMethodHMACMD5:
+		mechanism = PKCS11MechanismMD5HMAC
+	default:
+		return "", fmt.Errorf("%w for PKCS#11: %s", ErrUnknownSignMethod, method)
+	}
+
+	// PKCS#11 sessions are not safe for concurrent use
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.found {
+		handle, err := p.session.FindKey(p.label)
+		if err != nil {
+			return "", fmt.Errorf("failed to find PKCS#11 key %q: %w", p.label, err)
+		}
+		p.handle, p.found = handle, true
+	}
+	sum, err := p.session.Sign(mechanism, p.handle, []byte(content))
+	if err != nil {
+		return "", fmt.Errorf("PKCS#11 sign failed: %w", err)
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// CommandKeyProvider runs an external program for every signature, such as
+// a tool shipped with secure element middleware. The program gets the sign
+// method in the IOT_SIGN_METHOD environment variable and the content on
+// stdin, and must print the hex signature.
+type CommandKeyProvider struct {
+	Path    string
+	Args    []string
+	Timeout time.Duration
+}
+
+// NewCommandKeyProvider splits command on spaces into the program and its
+// arguments.
+func NewCommandKeyProvider(command string) *CommandKeyProvider {
+	fields := strings.Fields(command)
+	p := &CommandKeyProvider{Timeout: 10 * time.Second}
+	if len(fields) > 0 {
+		p.Path, p.Args = fields[0], fields[1:]
+	}
+	return p
+}
+
+func (p *CommandKeyProvider) Sign(method, content string) (string, error) {
+	if method == "" {
+		method = SignMethodHMACSHA256
+	}
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, p.Path, p.Args...)
+	cmd.Env = append(os.Environ(), "IOT_SIGN_METHOD="+method)
+	cmd.Stdin = strings.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("sign command %s failed: %w: %s", p.Path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	signature := strings.ToLower(strings.TrimSpace(string(out)))
+	if _, err := hex.DecodeString(signature); err != nil || signature == "" {
+		return "", fmt.Errorf("sign command %s printed no hex signature", p.Path)
+	}
+	return signature, nil
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// fakeSession is a PKCS#11 token holding one HMAC key.
+type fakeSession struct {
+	secret []byte
+	finds  int
+}
+
+func (s *fakeSession) FindKey(label string) (uint, error) {
+	s.finds++
+	if label != "device-secret" {
+		return 0, errors.New("CKR_OBJECT_HANDLE_INVALID")
+	}
+	return 7, nil
+}
+
+func (s *fakeSession) Sign(mechanism, key uint, data []byte) ([]byte, error) {
+	if key != 7 {
+		return nil, errors.New("CKR_KEY_HANDLE_INVALID")
+	}
+	var h = hmac.New(sha256.New, s.secret)
+	switch mechanism {
+	case PKCS11MechanismSHA256HMAC:
+	case PKCS11MechanismSHA1HMAC:
+		h = hmac.New(sha1.New, s.secret)
+	default:
+		return nil, errors.New("CKR_MECHANISM_INVALID")
+	}
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+func TestKeyProvidersMatchMemorySignature(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	session := &fakeSession{secret: []byte("testSecret")}
+
+	providers := map[string]KeyProvider{
+		"memory":  NewMemoryKeyProvider("testSecret"),
+		"pkcs11":  NewPKCS11KeyProvider(session, "device-secret"),
+		"command": &CommandKeyProvider{Path: os.Args[0], Args: []string{"-test.run=TestHelperSignCommand"}, Timeout: 10 * time.Second},
+	}
+	for name, key := range providers {
+		for _, method := range []string{SignMethodHMACSHA256, SignMethodHMACSHA1} {
+			want, err := GenerateCredentialsAt("testProduct", "testDevice", "testSecret", "3", Options{SignMethod: method}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := GenerateCredentialsWithKeyAt("testProduct", "testDevice", "3", Options{SignMethod: method}, key, now)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", name, method, err)
+			}
+			if got.Password != want.Password {
+				t.Errorf("%s/%s: password = %s, want %s", name, method, got.Password, want.Password)
+			}
+		}
+	}
+	if session.finds != 1 {
+		t.Fatalf("PKCS#11 key looked up %d times, want 1", session.finds)
+	}
+
+	if _, err := NewPKCS11KeyProvider(session, "device-secret").Sign("rsa", "x"); !errors.Is(err, ErrUnknownSignMethod) {
+		t.Fatalf("err = %v, want ErrUnknownSignMethod", err)
+	}
+}
+
+func TestCommandKeyProviderReportsFailure(t *testing.T) {
+	key := &CommandKeyProvider{Path: os.Args[0], Args: []string{"-test.run=TestHelperSignCommand"}}
+	t.Setenv("IOT_TEST_SIGN_FAIL", "1")
+	if _, err := key.Sign(SignMethodHMACSHA256, "content"); err == nil {
+		t.Fatal("expected error from failing sign command")
+	}
+}
+
+// TestHelperSignCommand acts as an external sign command when run by
+// CommandKeyProvider.
+func TestHelperSignCommand(t *testing.T) {
+	method := os.Getenv("IOT_SIGN_METHOD")
+	if method == "" {
+		return
+	}
+	if os.Getenv("IOT_TEST_SIGN_FAIL") != "" {
+		fmt.Fprintln(os.Stderr, "secure element unavailable")
+		os.Exit(1)
+	}
+	content, _ := io.ReadAll(os.Stdin)
+	signature, err := NewMemoryKeyProvider("testSecret").Sign(method, string(content))
+	if err != nil {
+		os.Exit(2)
+	}
+	fmt.Println(signature)
+	os.Exit(0)
+}
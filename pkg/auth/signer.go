@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+// Sign methods sent as signmethod in the client ID.
+const (
+	SignMethodHMACSHA256 = "hmacsha256"
+	SignMethodHMACSHA1   = "hmacsha1"
+	SignMethodHMACMD5    = "hmacmd5"
+)
+
+var (
+	ErrUnknownSignMethod = errors.New("unknown sign method")
+	ErrUnknownLayout     = errors.New("unknown credential layout")
+)
+
+// Signer computes the password signature for one sign method.
+type Signer interface {
+	// Method is the name sent as signmethod, e.g. "hmacsha256"
+	Method() string
+	// Sign returns the hex signature of content keyed with secret
+	Sign(content string, secret []byte) (string, error)
+}
+
+type hmacSigner struct {
+	method string
+	hash   func() hash.Hash
+}
+
+// NewHMACSigner returns a Signer computing HMAC with hash.
+func NewHMACSigner(method string, hash func() hash.Hash) Signer {
+	return &hmacSigner{method: method, hash: hash}
+}
+
+func (s *hmacSigner) Method() string {
+	return s.method
+}
+
+func (s *hmacSigner) Sign(content string, secret []byte) (string, error) {
+	h := hmac.New(s.hash, secret)
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+var (
+	registryMutex sync.RWMutex
+	signers       = make(map[string]Signer)
+	layouts       = make(map[string]Layout)
+)
+
+func init() {
+	RegisterSigner(NewHMACSigner(SignMethodHMACSHA256, sha256.New))
+	RegisterSigner(NewHMACSigner(SignMethodHMACSHA1, sha1.New))
+	RegisterSigner(NewHMACSigner(SignMethodHMACMD5, md5.New))
+	RegisterLayout(LayoutDefault, defaultLayout{})
+	RegisterLayout(LayoutAliyun, aliyunLayout{})
+}
+
+// RegisterSigner makes signer available under its Method, replacing any
+// signer registered under the same name.
+func RegisterSigner(signer Signer) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	signers[signer.Method()] = signer
+}
+
+// LookupSigner returns the signer for method; an empty method selects
+// hmacsha256.
+func LookupSigner(method string) (Signer, error) {
+	if method == "" {
+		method = SignMethodHMACSHA256
+	}
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	if signer, ok := signers[method]; ok {
+		return signer, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSignMethod, method)
+}
+
+// SignMethods lists the registered sign methods.
+func SignMethods() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	methods := make([]string, 0, len(signers))
+	for method := range signers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Credential layouts.
+const (
+	// LayoutDefault is this platform's _v=4,ext=3 client ID
+	LayoutDefault = "default"
+	// LayoutAliyun is the classic Alibaba Cloud IoT layout used by some
+	// partner platforms
+	LayoutAliyun = "aliyun"
+)
+
+// SignParams are the inputs of a Layout.
+type SignParams struct {
+	ProductKey string
+	DeviceName string
+	SecureMode string
+	SignMethod string
+	// Timestamp is in milliseconds; Nonce makes client IDs unique
+	Timestamp string
+	Nonce     string
+}
+
+// Layout arranges the client ID, username and signed content of a
+// platform.
+type Layout interface {
+	ClientID(p SignParams) string
+	Username(p SignParams) string
+	SignContent(p SignParams) string
+}
+
+// RegisterLayout makes layout available under name.
+func RegisterLayout(name string, layout Layout) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	layouts[name] = layout
+}
+
+// LookupLayout returns the layout registered as name; an empty name
+// selects LayoutDefault.
+func LookupLayout(name string) (Layout, error) {
+	if name == "" {
+		name = LayoutDefault
+	}
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	if layout, ok := layouts[name]; ok {
+		return layout, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownLayout, name)
+}
+
+type defaultLayout struct{}
+
+func (defaultLayout) ClientID(p SignParams) string {
+	return fmt.Sprintf("%s.%s|timestamp=%s,_ss=1,_v=4,securemode=%s,signmethod=%s,ext=3,%s|",
+		p.ProductKey, p.DeviceName, p.Timestamp, p.SecureMode, p.SignMethod, p.Nonce)
+}
+
+func (defaultLayout) Username(p SignParams) string {
+	return fmt.Sprintf("%s&%s", p.DeviceName, p.ProductKey)
+}
+
+func (defaultLayout) SignContent(p SignParams) string {
+	return fmt.Sprintf("clientId%s.%sdeviceName%sproductKey%stimestamp%s",
+		p.ProductKey, p.DeviceName, p.DeviceName, p.ProductKey, p.Timestamp)
+}
+
+type aliyunLayout struct{}
+
+func (aliyunLayout) ClientID(p SignParams) string {
+	return fmt.Sprintf("%s.%s|securemode=%s,signmethod=%s,timestamp=%s|",
+		p.ProductKey, p.DeviceName, p.SecureMode, p.SignMethod, p.Timestamp)
+}
+
+func (aliyunLayout) Username(p SignParams) string {
+	return fmt.Sprintf("%s&%s", p.DeviceName, p.ProductKey)
+}
+
+func (aliyunLayout) SignContent(p SignParams) string {
+	return fmt.Sprintf("clientId%s.%sdeviceName%sproductKey%stimestamp%s",
+		p.ProductKey, p.DeviceName, p.DeviceName, p.ProductKey, p.Timestamp)
+}
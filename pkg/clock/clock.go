@@ -0,0 +1,97 @@
+// Package clock provides the time source used for credential timestamps
+// and reported data, so it can be corrected when the device RTC is wrong.
+package clock
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System returns the local system clock.
+func System() Clock {
+	return systemClock{}
+}
+
+var defaultClock atomic.Pointer[Clock]
+
+// Default returns the process wide clock. Until SetDefault is called it is
+// the system clock.
+func Default() Clock {
+	if c := defaultClock.Load(); c != nil {
+		return *c
+	}
+	return systemClock{}
+}
+
+// SetDefault replaces the clock returned by Default; nil restores the
+// system clock.
+func SetDefault(c Clock) {
+	if c == nil {
+		defaultClock.Store(nil)
+		return
+	}
+	defaultClock.Store(&c)
+}
+
+// InstallDefault makes c the default clock unless another clock is
+// installed, and reports whether it did.
+func InstallDefault(c Clock) bool {
+	return defaultClock.CompareAndSwap(nil, &c)
+}
+
+// RemoveDefault restores the system clock if c is the default clock, and
+// reports whether it did. Use it to undo InstallDefault without removing
+// a clock installed by someone else.
+func RemoveDefault(c Clock) bool {
+	current := defaultClock.Load()
+	return current != nil && *current == c && defaultClock.CompareAndSwap(current, nil)
+}
+
+// Now returns Default().Now().
+func Now() time.Time {
+	return Default().Now()
+}
+
+// Corrected is the system clock shifted by an offset, typically measured
+// against server time by the timesync package.
+type Corrected struct {
+	offset atomic.Int64
+	synced atomic.Bool
+}
+
+// NewCorrected returns a clock without offset.
+func NewCorrected() *Corrected {
+	return &Corrected{}
+}
+
+func (c *Corrected) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+// Offset is added to the system time; it is positive when the local clock
+// is behind.
+func (c *Corrected) Offset() time.Duration {
+	return time.Duration(c.offset.Load())
+}
+
+// SetOffset sets the offset and marks the clock as synchronized.
+func (c *Corrected) SetOffset(offset time.Duration) {
+	c.offset.Store(int64(offset))
+	c.synced.Store(true)
+}
+
+// Synced reports whether an offset has been set.
+func (c *Corrected) Synced() bool {
+	return c.synced.Load()
+}
@@ -1,10 +1,18 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/credstore"
 )
 
 type DeviceConfig struct {
@@ -12,6 +20,38 @@ type DeviceConfig struct {
 	DeviceName    string
 	DeviceSecret  string
 	ProductSecret string
+	// DeviceSecretKey and ProductSecretKey sign with secrets held outside
+	// the process, e.g. in a secure element, in place of DeviceSecret and
+	// ProductSecret. DeviceSecretCommand and ProductSecretCommand configure
+	// an auth.CommandKeyProvider instead.
+	DeviceSecretKey      auth.KeyProvider
+	ProductSecretKey     auth.KeyProvider
+	DeviceSecretCommand  string
+	ProductSecretCommand string
+}
+
+// DeviceKeyProvider returns the key that signs with the device secret, or
+// nil when none is configured.
+func (d *DeviceConfig) DeviceKeyProvider() auth.KeyProvider {
+	return keyProvider(d.DeviceSecretKey, d.DeviceSecretCommand, d.DeviceSecret)
+}
+
+// ProductKeyProvider returns the key that signs with the product secret,
+// or nil when none is configured.
+func (d *DeviceConfig) ProductKeyProvider() auth.KeyProvider {
+	return keyProvider(d.ProductSecretKey, d.ProductSecretCommand, d.ProductSecret)
+}
+
+func keyProvider(key auth.KeyProvider, command, secret string) auth.KeyProvider {
+	switch {
+	case key != nil:
+		return key
+	case command != "":
+		return auth.NewCommandKeyProvider(command)
+	case secret != "":
+		return auth.NewMemoryKeyProvider(secret)
+	}
+	return nil
 }
 
 type MQTTConfig struct {
@@ -24,107 +64,375 @@ type MQTTConfig struct {
 	Password     string
 	CleanSession bool
 	SecureMode   string
+	// SignMethod is hmacsha256 (default), hmacsha1, hmacmd5 or any method
+	// registered with auth.RegisterSigner
+	SignMethod string
+	// CredentialLayout selects the client ID and username format:
+	// "default" or a layout registered with auth.RegisterLayout
+	CredentialLayout string
+	// ProtocolVersion selects the MQTT protocol: 4 (or 0) for 3.1.1, 5 for MQTT 5.0
+	ProtocolVersion int
+	// SessionExpiry and TopicAliasMaximum only apply to MQTT 5.0
+	SessionExpiry     time.Duration
+	TopicAliasMaximum uint16
+	// Transport is "tcp" (default) or "websocket"; UseTLS selects ssl:// or wss://
+	Transport     string
+	WebSocketPath string
+	Proxy         ProxyConfig
 }
 
+// ProxyConfig routes the broker connection through an outbound proxy.
+// URL is http://host:port for HTTP CONNECT or socks5://host:port for SOCKS5;
+// Username/Password take precedence over credentials embedded in the URL.
+type ProxyConfig struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Secure modes sent in the MQTT client ID.
+const (
+	SecureModeTLS  = "2"
+	SecureModeTCP  = "3"
+	SecureModeX509 = "x509"
+)
+
 type TLSConfig struct {
-	CACert     string
+	// CACert is inline PEM or a PEM file path; CAFiles adds further CA
+	// bundles. The embedded platform CA is used when both are empty.
+	CACert  string
+	CAFiles []string
+	// ClientCert and ClientKey enable X.509 client-certificate (mutual TLS)
+	// authentication. Each holds inline PEM or the path of a PEM file.
 	ClientCert string
 	ClientKey  string
+	// ClientPKCS12 is the path of a PKCS#12 bundle holding the client
+	// certificate and key; it takes precedence over ClientCert/ClientKey.
+	ClientPKCS12         string
+	ClientPKCS12Password string
+	// SkipVerify disables CA and server name checks; pins still apply.
 	SkipVerify bool
+	// ServerName is the name the broker certificate must match, which lets
+	// the broker be reached by IP. Defaults to the MQTT host.
 	ServerName string
+	// PinnedSPKI holds base64 SHA-256 hashes of a SubjectPublicKeyInfo and
+	// PinnedCertSHA256 hex SHA-256 hashes of a DER certificate. When any pin
+	// is set, some certificate of the broker chain must match one.
+	PinnedSPKI       []string
+	PinnedCertSHA256 []string
+}
+
+// HasClientCert reports whether a client certificate is configured.
+func (t *TLSConfig) HasClientCert() bool {
+	return t.ClientPKCS12 != "" || t.ClientCert != ""
+}
+
+// OfflineQueueConfig configures the disk-backed queue that buffers
+// outbound messages while the MQTT connection is down. The queue is
+// disabled when Dir is empty.
+type OfflineQueueConfig struct {
+	Dir      string
+	MaxBytes int64
+	MaxAge   time.Duration
+	// DropPolicies maps a topic filter (MQTT wildcards allowed) to the
+	// policy applied when that topic is published while offline:
+	// "drop-oldest" (default), "drop-newest" or "never-queue".
+	DropPolicies map[string]string
+}
+
+// CredentialStoreConfig locates the on-device credential store that holds
+// the device identity. The store is disabled when Path is empty.
+type CredentialStoreConfig struct {
+	Path string
+	// Type is "encrypted" (default) or "plain" for development.
+	Type string
+	// Passphrase derives the encryption key; the machine ID is used when
+	// it is empty.
+	Passphrase string
+}
+
+// DynRegConfig locates the HTTP dynamic registration endpoint,
+// Scheme://Host:Port/Path. Host defaults to the MQTT host and Port to the
+// default port of Scheme.
+type DynRegConfig struct {
+	// Scheme is "https" (default) or "http"; TLS uses the CA and pins of
+	// TLSConfig
+	Scheme string
+	Host   string
+	Port   int
+	Path   string
+	// Retries is how often a request failing with a network or server
+	// error is repeated, with exponential backoff
+	Retries int
+	Timeout time.Duration
+}
+
+// URL returns the registration endpoint; mqttHost is used when Host is
+// empty.
+func (d *DynRegConfig) URL(mqttHost string) string {
+	scheme := d.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	host := d.Host
+	if host == "" {
+		host = mqttHost
+	}
+	if d.Port > 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(d.Port))
+	}
+	path := d.Path
+	if path == "" {
+		path = "/auth/register/device"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return scheme + "://" + host + path
+}
+
+// TimeSyncConfig enables synchronizing the clock with server time over
+// MQTT, see package timesync. A skew above SkewThreshold is reported.
+type TimeSyncConfig struct {
+	Enabled       bool
+	Interval      time.Duration
+	SkewThreshold time.Duration
+}
+
+// RRPCConfig controls how the device answers RRPC requests, see package
+// rrpc. ResponseFormat is "envelope" (the default) or "csdk" for the reply
+// shape of the C SDK. Up to Workers handlers run at a time with QueueSize
+// more requests waiting; Timeout is how long the platform waits for a
+// reply.
+type RRPCConfig struct {
+	ResponseFormat string
+	Workers        int
+	QueueSize      int
+	Timeout        time.Duration
 }
 
 type Config struct {
-	Device DeviceConfig
-	MQTT   MQTTConfig
-	TLS    TLSConfig
+	Device          DeviceConfig
+	MQTT            MQTTConfig
+	TLS             TLSConfig
+	OfflineQueue    OfflineQueueConfig
+	CredentialStore CredentialStoreConfig
+	DynReg          DynRegConfig
+	TimeSync        TimeSyncConfig
+	RRPC            RRPCConfig
 }
 
 func NewConfig() *Config {
 	return &Config{
 		MQTT: MQTTConfig{
-			Host:         "localhost",
-			Port:         1883,
-			UseTLS:       false,
-			KeepAlive:    60 * time.Second,
-			CleanSession: true,
+			Host:          "localhost",
+			Port:          1883,
+			UseTLS:        false,
+			KeepAlive:     60 * time.Second,
+			CleanSession:  true,
+			Transport:     "tcp",
+			WebSocketPath: "/mqtt",
 		},
 		TLS: TLSConfig{
 			SkipVerify: false,
 		},
+		OfflineQueue: OfflineQueueConfig{
+			MaxBytes: 8 * 1024 * 1024,
+			MaxAge:   24 * time.Hour,
+		},
+		DynReg: DynRegConfig{
+			Scheme:  "https",
+			Path:    "/auth/register/device",
+			Retries: 3,
+			Timeout: 30 * time.Second,
+		},
+		TimeSync: TimeSyncConfig{
+			Interval:      time.Hour,
+			SkewThreshold: 5 * time.Second,
+		},
+		RRPC: RRPCConfig{
+			Workers:   4,
+			QueueSize: 16,
+			Timeout:   5 * time.Second,
+		},
 	}
 }
 
+// LoadFromEnv applies the IOT_* environment variables listed in the field
+// table. Malformed values are ignored; use a Loader to reject them.
 func (c *Config) LoadFromEnv() error {
-	if val := os.Getenv("IOT_PRODUCT_KEY"); val != "" {
-		c.Device.ProductKey = val
-	}
-	if val := os.Getenv("IOT_DEVICE_NAME"); val != "" {
-		c.Device.DeviceName = val
-	}
-	if val := os.Getenv("IOT_DEVICE_SECRET"); val != "" {
-		c.Device.DeviceSecret = val
+	for _, f := range sdkFields(c) {
+		if val := os.Getenv(f.Env); val != "" {
+			f.Set(val)
+		}
 	}
-	if val := os.Getenv("IOT_PRODUCT_SECRET"); val != "" {
-		c.Device.ProductSecret = val
+	return nil
+}
+
+// splitList splits a comma separated environment value, dropping blanks.
+func splitList(val string) []string {
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	if val := os.Getenv("IOT_MQTT_HOST"); val != "" {
-		c.MQTT.Host = val
+func (c *Config) Validate() error {
+	if c.Device.ProductKey == "" {
+		return fmt.Errorf("product key is required")
 	}
-	if val := os.Getenv("IOT_MQTT_PORT"); val != "" {
-		if port, err := strconv.Atoi(val); err == nil {
-			c.MQTT.Port = port
-		}
+	if c.Device.DeviceName == "" {
+		return fmt.Errorf("device name is required")
 	}
-	if val := os.Getenv("IOT_MQTT_USE_TLS"); val != "" {
-		if useTLS, err := strconv.ParseBool(val); err == nil {
-			c.MQTT.UseTLS = useTLS
+	if c.TLS.HasClientCert() {
+		if !c.MQTT.UseTLS {
+			return fmt.Errorf("client certificate authentication requires TLS")
+		}
+		if c.TLS.ClientPKCS12 == "" && c.TLS.ClientKey == "" {
+			return fmt.Errorf("client key is required with a client certificate")
 		}
+	} else if c.Device.DeviceKeyProvider() == nil && c.Device.ProductKeyProvider() == nil && c.MQTT.Password == "" {
+		return fmt.Errorf("either device secret or product secret is required")
+	}
+	if c.MQTT.Host == "" {
+		return fmt.Errorf("MQTT host is required")
+	}
+	if c.MQTT.Port <= 0 || c.MQTT.Port > 65535 {
+		return fmt.Errorf("MQTT port must be between 1 and 65535")
+	}
+	// The CONNECT packet carries keepalive as 16-bit seconds; 0 disables it
+	if c.MQTT.KeepAlive != 0 && (c.MQTT.KeepAlive < time.Second || c.MQTT.KeepAlive > 65535*time.Second) {
+		return fmt.Errorf("MQTT keepalive must be 0 or between 1s and 65535s")
+	}
+	switch c.MQTT.ProtocolVersion {
+	case 0, 3, 4, 5:
+	default:
+		return fmt.Errorf("unsupported MQTT protocol version: %d", c.MQTT.ProtocolVersion)
 	}
-	if val := os.Getenv("IOT_MQTT_KEEPALIVE"); val != "" {
-		if keepAlive, err := strconv.Atoi(val); err == nil {
-			c.MQTT.KeepAlive = time.Duration(keepAlive) * time.Second
+	if !c.MQTT.IsWebSocket() {
+		switch c.MQTT.Transport {
+		case "", "tcp":
+		default:
+			return fmt.Errorf("unsupported MQTT transport: %s", c.MQTT.Transport)
 		}
 	}
+	if c.MQTT.Transport == "wss" && !c.MQTT.UseTLS {
+		return fmt.Errorf("MQTT transport wss requires TLS")
+	}
+	if _, err := auth.LookupSigner(c.MQTT.SignMethod); err != nil {
+		return err
+	}
+	if _, err := auth.LookupLayout(c.MQTT.CredentialLayout); err != nil {
+		return err
+	}
+	switch c.DynReg.Scheme {
+	case "", "https", "http":
+	default:
+		return fmt.Errorf("unsupported dynamic registration scheme: %s", c.DynReg.Scheme)
+	}
+	if c.DynReg.Port < 0 || c.DynReg.Port > 65535 {
+		return fmt.Errorf("dynamic registration port must be 0 (default) or 1-65535")
+	}
+	if c.TimeSync.Interval < 0 || c.TimeSync.SkewThreshold < 0 {
+		return fmt.Errorf("time sync interval and skew threshold must not be negative")
+	}
+	switch c.RRPC.ResponseFormat {
+	case "", "envelope", "csdk":
+	default:
+		return fmt.Errorf("unsupported RRPC response format: %s", c.RRPC.ResponseFormat)
+	}
+	if c.RRPC.Workers < 0 || c.RRPC.QueueSize < 0 || c.RRPC.Timeout < 0 {
+		return fmt.Errorf("RRPC workers, queue size and timeout must not be negative")
+	}
+	if err := c.TLS.validate(); err != nil {
+		return err
+	}
+	return nil
+}
 
-	if val := os.Getenv("IOT_TLS_CA_CERT"); val != "" {
-		c.TLS.CACert = val
+// validate checks pin formats and that referenced files exist.
+func (t *TLSConfig) validate() error {
+	for _, pin := range t.PinnedSPKI {
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(pin), "sha256/"))
+		if err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("invalid SPKI pin %q: want base64 SHA-256", pin)
+		}
 	}
-	if val := os.Getenv("IOT_TLS_SKIP_VERIFY"); val != "" {
-		if skipVerify, err := strconv.ParseBool(val); err == nil {
-			c.TLS.SkipVerify = skipVerify
+	for _, pin := range t.PinnedCertSHA256 {
+		sum, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+		if err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("invalid certificate pin %q: want hex SHA-256", pin)
 		}
 	}
-	if val := os.Getenv("IOT_TLS_SERVER_NAME"); val != "" {
-		c.TLS.ServerName = val
+
+	// CACert, ClientCert and ClientKey may also hold inline PEM
+	files := append([]string{t.ClientPKCS12}, t.CAFiles...)
+	for _, value := range []string{t.CACert, t.ClientCert, t.ClientKey} {
+		if !strings.Contains(value, "-----BEGIN") {
+			files = append(files, value)
+		}
 	}
-	if val := os.Getenv("IOT_MQTT_SECURE_MODE"); val != "" {
-		c.MQTT.SecureMode = val
+	for _, path := range files {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("TLS file: %w", err)
+		}
 	}
-
 	return nil
 }
 
-func (c *Config) Validate() error {
-	if c.Device.ProductKey == "" {
-		return fmt.Errorf("product key is required")
+// OpenCredentialStore opens the store described by CredentialStore. It
+// returns nil and no error when no store is configured.
+func (c *Config) OpenCredentialStore() (credstore.Store, error) {
+	if c.CredentialStore.Path == "" {
+		return nil, nil
 	}
-	if c.Device.DeviceName == "" {
-		return fmt.Errorf("device name is required")
+	return credstore.Open(c.CredentialStore.Path, c.CredentialStore.Type, c.CredentialStore.Passphrase)
+}
+
+// LoadIdentity fills the device secret and any registered MQTT credentials
+// from store. It returns an error wrapping credstore.ErrNotFound when the
+// device has not been stored yet, or its record holds no usable
+// credentials.
+func (c *Config) LoadIdentity(store credstore.Store) error {
+	identity, err := store.Get(c.Device.ProductKey, c.Device.DeviceName)
+	if err != nil {
+		return fmt.Errorf("failed to load device identity: %w", err)
 	}
-	if c.Device.DeviceSecret == "" && c.Device.ProductSecret == "" {
-		return fmt.Errorf("either device secret or product secret is required")
+	if !identity.Usable() {
+		return fmt.Errorf("failed to load device identity: stored record has no credentials: %w", credstore.ErrNotFound)
 	}
-	if c.MQTT.Host == "" {
-		return fmt.Errorf("MQTT host is required")
+
+	if identity.DeviceSecret != "" {
+		c.Device.DeviceSecret = identity.DeviceSecret
 	}
-	if c.MQTT.Port <= 0 || c.MQTT.Port > 65535 {
-		return fmt.Errorf("MQTT port must be between 1 and 65535")
+	if identity.ClientID != "" {
+		c.MQTT.ClientID = identity.ClientID
+		c.MQTT.Username = identity.Username
+		c.MQTT.Password = identity.Password
 	}
 	return nil
 }
 
+// AuthOptions returns the sign method and layout used to sign credentials.
+func (m *MQTTConfig) AuthOptions() auth.Options {
+	return auth.Options{SignMethod: m.SignMethod, Layout: m.CredentialLayout}
+}
+
+// IsWebSocket reports whether the broker connection uses MQTT over WebSocket.
+func (m *MQTTConfig) IsWebSocket() bool {
+	switch m.Transport {
+	case "websocket", "ws", "wss":
+		return true
+	}
+	return false
+}
+
 func (c *Config) GenerateClientID() string {
 	if c.MQTT.ClientID != "" {
 		return c.MQTT.ClientID
@@ -138,8 +446,11 @@ func (c *Config) GetSecureMode() string {
 	}
 	
 	if c.MQTT.UseTLS {
-		return "2"
+		if c.TLS.HasClientCert() {
+			return SecureModeX509
+		}
+		return SecureModeTLS
 	}
 	
-	return "3"
+	return SecureModeTCP
 }
\ No newline at end of file
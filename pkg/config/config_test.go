@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/iot-go-sdk/pkg/credstore"
+)
+
+func TestLoadIdentity(t *testing.T) {
+	store := credstore.NewFileStore(filepath.Join(t.TempDir(), "identity.json"))
+	cfg := NewConfig()
+	cfg.Device.ProductKey, cfg.Device.DeviceName = "pk", "dn"
+
+	if err := cfg.LoadIdentity(store); !errors.Is(err, credstore.ErrNotFound) {
+		t.Fatalf("missing identity: err = %v, want ErrNotFound", err)
+	}
+
+	// A record without a secret or password does not count as provisioned
+	for _, empty := range []*credstore.Identity{
+		{ProductKey: "pk", DeviceName: "dn"},
+		{ProductKey: "pk", DeviceName: "dn", ClientID: "cid", Username: "user"},
+	} {
+		if err := store.Put(empty); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.LoadIdentity(store); !errors.Is(err, credstore.ErrNotFound) {
+			t.Fatalf("identity %+v: err = %v, want ErrNotFound", empty, err)
+		}
+	}
+
+	if err := store.Put(&credstore.Identity{ProductKey: "pk", DeviceName: "dn", ClientID: "cid", Username: "user", Password: "pass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadIdentity(store); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MQTT.ClientID != "cid" || cfg.MQTT.Username != "user" || cfg.MQTT.Password != "pass" {
+		t.Fatalf("MQTT credentials = %+v", cfg.MQTT)
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field binds one configuration key to its environment variable and setter.
+// Key is the dotted path used in files and, with a leading dash, as the
+// command-line flag name, e.g. "mqtt.port" and -mqtt.port.
+type Field struct {
+	Key   string
+	Env   string
+	Usage string
+	Set   func(value string) error
+}
+
+// sdkFields describes every key of Config.
+func sdkFields(c *Config) []Field {
+	return []Field{
+		{"device.productKey", "IOT_PRODUCT_KEY", "product key", StringValue(&c.Device.ProductKey)},
+		{"device.deviceName", "IOT_DEVICE_NAME", "device name", StringValue(&c.Device.DeviceName)},
+		{"device.deviceSecret", "IOT_DEVICE_SECRET", "device secret", StringValue(&c.Device.DeviceSecret)},
+		{"device.productSecret", "IOT_PRODUCT_SECRET", "product secret for dynamic registration", StringValue(&c.Device.ProductSecret)},
+		{"device.deviceSecretCommand", "IOT_DEVICE_SECRET_COMMAND", "external command signing with the device secret", StringValue(&c.Device.DeviceSecretCommand)},
+		{"device.productSecretCommand", "IOT_PRODUCT_SECRET_COMMAND", "external command signing with the product secret", StringValue(&c.Device.ProductSecretCommand)},
+
+		{"mqtt.host", "IOT_MQTT_HOST", "MQTT broker host", StringValue(&c.MQTT.Host)},
+		{"mqtt.port", "IOT_MQTT_PORT", "MQTT broker port", IntValue(&c.MQTT.Port)},
+		{"mqtt.useTLS", "IOT_MQTT_USE_TLS", "connect with TLS", BoolValue(&c.MQTT.UseTLS)},
+		{"mqtt.keepAlive", "IOT_MQTT_KEEPALIVE", "keepalive, in seconds or as a duration", DurationValue(&c.MQTT.KeepAlive)},
+		{"mqtt.clientId", "IOT_MQTT_CLIENT_ID", "MQTT client ID", StringValue(&c.MQTT.ClientID)},
+		{"mqtt.cleanSession", "IOT_MQTT_CLEAN_SESSION", "start a clean session", BoolValue(&c.MQTT.CleanSession)},
+		{"mqtt.secureMode", "IOT_MQTT_SECURE_MODE", "secure mode: 2, 3 or x509", StringValue(&c.MQTT.SecureMode)},
+		{"mqtt.signMethod", "IOT_MQTT_SIGN_METHOD", "hmacsha256, hmacsha1 or hmacmd5", StringValue(&c.MQTT.SignMethod)},
+		{"mqtt.credentialLayout", "IOT_MQTT_CREDENTIAL_LAYOUT", "client ID and username layout: default or aliyun", StringValue(&c.MQTT.CredentialLayout)},
+		{"mqtt.protocolVersion", "IOT_MQTT_PROTOCOL_VERSION", "MQTT protocol version: 4 or 5", IntValue(&c.MQTT.ProtocolVersion)},
+		{"mqtt.sessionExpiry", "IOT_MQTT_SESSION_EXPIRY", "MQTT 5 session expiry", DurationValue(&c.MQTT.SessionExpiry)},
+		{"mqtt.topicAliasMaximum", "IOT_MQTT_TOPIC_ALIAS_MAXIMUM", "MQTT 5 topic alias maximum", uint16Value(&c.MQTT.TopicAliasMaximum)},
+		{"mqtt.transport", "IOT_MQTT_TRANSPORT", "tcp or websocket", StringValue(&c.MQTT.Transport)},
+		{"mqtt.webSocketPath", "IOT_MQTT_WS_PATH", "WebSocket path", StringValue(&c.MQTT.WebSocketPath)},
+		{"mqtt.proxy.url", "IOT_MQTT_PROXY_URL", "http:// or socks5:// proxy URL", StringValue(&c.MQTT.Proxy.URL)},
+		{"mqtt.proxy.username", "IOT_MQTT_PROXY_USERNAME", "proxy username", StringValue(&c.MQTT.Proxy.Username)},
+		{"mqtt.proxy.password", "IOT_MQTT_PROXY_PASSWORD", "proxy password", StringValue(&c.MQTT.Proxy.Password)},
+
+		{"tls.caCert", "IOT_TLS_CA_CERT", "CA certificate, inline PEM or file", StringValue(&c.TLS.CACert)},
+		{"tls.caFiles", "IOT_TLS_CA_FILES", "additional CA bundle files", ListValue(&c.TLS.CAFiles)},
+		{"tls.clientCert", "IOT_TLS_CLIENT_CERT", "client certificate, inline PEM or file", StringValue(&c.TLS.ClientCert)},
+		{"tls.clientKey", "IOT_TLS_CLIENT_KEY", "client key, inline PEM or file", StringValue(&c.TLS.ClientKey)},
+		{"tls.clientPKCS12", "IOT_TLS_CLIENT_PKCS12", "client PKCS#12 bundle", StringValue(&c.TLS.ClientPKCS12)},
+		{"tls.clientPKCS12Password", "IOT_TLS_CLIENT_PKCS12_PASSWORD", "PKCS#12 password", StringValue(&c.TLS.ClientPKCS12Password)},
+		{"tls.skipVerify", "IOT_TLS_SKIP_VERIFY", "skip CA and server name checks", BoolValue(&c.TLS.SkipVerify)},
+		{"tls.serverName", "IOT_TLS_SERVER_NAME", "name the broker certificate must match", StringValue(&c.TLS.ServerName)},
+		{"tls.pinnedSPKI", "IOT_TLS_PINNED_SPKI", "base64 SHA-256 SPKI pins", ListValue(&c.TLS.PinnedSPKI)},
+		{"tls.pinnedCertSHA256", "IOT_TLS_PINNED_CERT_SHA256", "hex SHA-256 certificate pins", ListValue(&c.TLS.PinnedCertSHA256)},
+
+		{"offlineQueue.dir", "IOT_OFFLINE_QUEUE_DIR", "offline queue directory", StringValue(&c.OfflineQueue.Dir)},
+		{"offlineQueue.maxBytes", "IOT_OFFLINE_QUEUE_MAX_BYTES", "offline queue size limit", int64Value(&c.OfflineQueue.MaxBytes)},
+		{"offlineQueue.maxAge", "IOT_OFFLINE_QUEUE_MAX_AGE", "offline queue message age limit", DurationValue(&c.OfflineQueue.MaxAge)},
+		{"offlineQueue.dropPolicies", "IOT_OFFLINE_QUEUE_DROP_POLICIES", "filter=policy pairs", mapValue(&c.OfflineQueue.DropPolicies)},
+
+		{"credentialStore.path", "IOT_CREDSTORE_PATH", "credential store file", StringValue(&c.CredentialStore.Path)},
+		{"credentialStore.type", "IOT_CREDSTORE_TYPE", "encrypted or plain", StringValue(&c.CredentialStore.Type)},
+		{"credentialStore.passphrase", "IOT_CREDSTORE_PASSPHRASE", "credential store passphrase", StringValue(&c.CredentialStore.Passphrase)},
+
+		{"dynreg.scheme", "IOT_DYNREG_SCHEME", "registration endpoint scheme: https or http", StringValue(&c.DynReg.Scheme)},
+		{"dynreg.host", "IOT_DYNREG_HOST", "registration endpoint host, defaults to the MQTT host", StringValue(&c.DynReg.Host)},
+		{"dynreg.port", "IOT_DYNREG_PORT", "registration endpoint port", IntValue(&c.DynReg.Port)},
+		{"dynreg.path", "IOT_DYNREG_PATH", "registration endpoint path", StringValue(&c.DynReg.Path)},
+		{"dynreg.retries", "IOT_DYNREG_RETRIES", "retries on connection errors before sending and HTTP 503/429", IntValue(&c.DynReg.Retries)},
+		{"dynreg.timeout", "IOT_DYNREG_TIMEOUT", "registration request timeout", DurationValue(&c.DynReg.Timeout)},
+
+		{"timeSync.enabled", "IOT_TIME_SYNC", "synchronize the clock with server time", BoolValue(&c.TimeSync.Enabled)},
+		{"timeSync.interval", "IOT_TIME_SYNC_INTERVAL", "time sync interval", DurationValue(&c.TimeSync.Interval)},
+		{"timeSync.skewThreshold", "IOT_TIME_SYNC_SKEW_THRESHOLD", "clock skew reported as an event", DurationValue(&c.TimeSync.SkewThreshold)},
+
+		{"rrpc.responseFormat", "IOT_RRPC_RESPONSE_FORMAT", "RRPC reply shape: envelope or csdk", StringValue(&c.RRPC.ResponseFormat)},
+		{"rrpc.workers", "IOT_RRPC_WORKERS", "RRPC handlers running at a time", IntValue(&c.RRPC.Workers)},
+		{"rrpc.queueSize", "IOT_RRPC_QUEUE_SIZE", "RRPC requests waiting for a handler before busy replies", IntValue(&c.RRPC.QueueSize)},
+		{"rrpc.timeout", "IOT_RRPC_TIMEOUT", "how long the platform waits for an RRPC reply", DurationValue(&c.RRPC.Timeout)},
+	}
+}
+
+// StringValue returns a Field setter for p.
+func StringValue(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+// IntValue returns a Field setter for p.
+func IntValue(p *int) func(string) error {
+	return func(value string) error {
+		v, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*p = v
+		return nil
+	}
+}
+
+func int64Value(p *int64) func(string) error {
+	return func(value string) error {
+		v, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*p = v
+		return nil
+	}
+}
+
+func uint16Value(p *uint16) func(string) error {
+	return func(value string) error {
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid value %q: want 0-65535", value)
+		}
+		*p = uint16(v)
+		return nil
+	}
+}
+
+// BoolValue returns a Field setter for p.
+func BoolValue(p *bool) func(string) error {
+	return func(value string) error {
+		v, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*p = v
+		return nil
+	}
+}
+
+// DurationValue returns a Field setter for p that accepts a Go duration
+// ("90s", "1h") or a plain number of seconds.
+func DurationValue(p *time.Duration) func(string) error {
+	return func(value string) error {
+		value = strings.TrimSpace(value)
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			*p = time.Duration(seconds * float64(time.Second))
+			return nil
+		}
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*p = v
+		return nil
+	}
+}
+
+// ListValue returns a Field setter for p that splits a comma separated
+// value.
+func ListValue(p *[]string) func(string) error {
+	return func(value string) error {
+		*p = splitList(value)
+		return nil
+	}
+}
+
+// mapValue parses comma separated key=value pairs.
+func mapValue(p *map[string]string) func(string) error {
+	return func(value string) error {
+		m := make(map[string]string)
+		for _, pair := range splitList(value) {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid pair %q: want key=value", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		*p = m
+		return nil
+	}
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Source identifies where a configuration value came from.
+type Source int
+
+// Sources in increasing order of precedence.
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceEnv
+	SourceFlag
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	default:
+		return "unknown"
+	}
+}
+
+// Origin records the source that set a key: the file path, environment
+// variable or flag name.
+type Origin struct {
+	Source Source
+	Name   string
+}
+
+func (o Origin) String() string {
+	switch o.Source {
+	case SourceDefault:
+		return "default"
+	case SourceFlag:
+		return "flag -" + o.Name
+	default:
+		return o.Source.String() + " " + o.Name
+	}
+}
+
+// Loader merges defaults, configuration files, environment variables and
+// command-line flags into a Config, in that order of precedence. Files are
+// YAML, JSON or TOML, chosen by extension, and use the dotted lowerCamel
+// keys of Field, e.g.
+//
+//	mqtt:
+//	  host: iot.example.com
+//	  keepAlive: 30s
+//
+// A Loader is not safe for concurrent use.
+type Loader struct {
+	config   *Config
+	fields   []Field
+	index    map[string]int
+	prefixes map[string]func(key, value string) error
+	files    []string
+	flags    map[string]string
+	origins  map[string]Origin
+}
+
+func NewLoader() *Loader {
+	l := &Loader{
+		config:   NewConfig(),
+		index:    make(map[string]int),
+		prefixes: make(map[string]func(key, value string) error),
+		flags:    make(map[string]string),
+		origins:  make(map[string]Origin),
+	}
+	l.Bind(sdkFields(l.config)...)
+	return l
+}
+
+// Bind adds keys stored outside Config, such as framework settings. Their
+// targets must be reset by the caller before each Load.
+func (l *Loader) Bind(fields ...Field) {
+	for _, f := range fields {
+		name := strings.ToLower(f.Key)
+		if _, ok := l.index[name]; ok {
+			panic("config: key bound twice: " + f.Key)
+		}
+		l.index[name] = len(l.fields)
+		l.fields = append(l.fields, f)
+	}
+}
+
+// BindPrefix routes file keys below prefix that have no Field of their
+// own, such as plugins.ota.check_interval, to set with the rest of the key.
+// These keys are only read from files.
+func (l *Loader) BindPrefix(prefix string, set func(key, value string) error) {
+	l.prefixes[strings.ToLower(prefix)+"."] = set
+}
+
+// AddFile appends a configuration file; later files override earlier ones.
+func (l *Loader) AddFile(path string) {
+	l.files = append(l.files, path)
+}
+
+// Files returns the configuration files in load order.
+func (l *Loader) Files() []string {
+	return append([]string(nil), l.files...)
+}
+
+// RegisterFlags defines a flag for every bound key, e.g. -mqtt.port, and a
+// repeatable -config flag that adds files. Call it after Bind.
+func (l *Loader) RegisterFlags(fs *flag.FlagSet) {
+	fs.Func("config", "configuration file (YAML, JSON or TOML); may be repeated", func(path string) error {
+		l.AddFile(path)
+		return nil
+	})
+	for _, f := range l.fields {
+		f := f
+		usage := f.Usage
+		if f.Env != "" {
+			usage += " ($" + f.Env + ")"
+		}
+		fs.Func(f.Key, usage, func(value string) error {
+			if err := f.Set(value); err != nil {
+				return err
+			}
+			l.flags[strings.ToLower(f.Key)] = value
+			return nil
+		})
+	}
+}
+
+// Load builds and validates the configuration. It may be called again to
+// pick up changed files and environment variables.
+func (l *Loader) Load() (*Config, error) {
+	*l.config = *NewConfig()
+	l.origins = make(map[string]Origin)
+
+	for _, path := range l.files {
+		values, err := readConfigFile(path, l.isKey)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := l.set(key, values[key], Origin{SourceFile, path}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, f := range l.fields {
+		if f.Env == "" {
+			continue
+		}
+		if val := os.Getenv(f.Env); val != "" {
+			if err := l.set(f.Key, val, Origin{SourceEnv, f.Env}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, f := range l.fields {
+		if val, ok := l.flags[strings.ToLower(f.Key)]; ok {
+			if err := l.set(f.Key, val, Origin{SourceFlag, f.Key}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := l.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	cfg := *l.config
+	return &cfg, nil
+}
+
+func (l *Loader) set(key, value string, origin Origin) error {
+	i, ok := l.index[strings.ToLower(key)]
+	if !ok {
+		for prefix, setPrefixed := range l.prefixes {
+			if strings.HasPrefix(strings.ToLower(key), prefix) {
+				if err := setPrefixed(key[len(prefix):], value); err != nil {
+					return fmt.Errorf("%s: %v (%s)", key, err, origin)
+				}
+				l.origins[key] = origin
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown configuration key %q (%s)", key, origin)
+	}
+	f := l.fields[i]
+	if err := f.Set(value); err != nil {
+		return fmt.Errorf("%s: %v (%s)", f.Key, err, origin)
+	}
+	l.origins[f.Key] = origin
+	return nil
+}
+
+// Source reports where the last Load took key from.
+func (l *Loader) Source(key string) Origin {
+	if i, ok := l.index[strings.ToLower(key)]; ok {
+		return l.origins[l.fields[i].Key]
+	}
+	return l.origins[key]
+}
+
+// Sources returns the origin of every bound key, keyed by Field.Key, and
+// of every key loaded below a BindPrefix prefix.
+func (l *Loader) Sources() map[string]Origin {
+	sources := make(map[string]Origin, len(l.fields))
+	for key, origin := range l.origins {
+		sources[key] = origin
+	}
+	for _, f := range l.fields {
+		sources[f.Key] = l.origins[f.Key]
+	}
+	return sources
+}
+
+func (l *Loader) isKey(key string) bool {
+	_, ok := l.index[strings.ToLower(key)]
+	return ok
+}
+
+// readConfigFile decodes a configuration file into flattened dotted keys.
+func readConfigFile(path string, isKey func(string) bool) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", doc, isKey, values)
+	return values, nil
+}
+
+// flatten maps nested tables to dotted keys. A table stored under a bound
+// key, such as offlineQueue.dropPolicies, is kept whole.
+func flatten(prefix string, doc map[string]interface{}, isKey func(string) bool, values map[string]string) {
+	for key, value := range doc {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && !isKey(key) {
+			flatten(key, nested, isKey, values)
+			continue
+		}
+		values[key] = formatValue(value)
+	}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatValue(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, key+"="+formatValue(item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoaderPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "base.yaml", `
+device:
+  productKey: pk
+  deviceName: dn
+  deviceSecret: secret
+mqtt:
+  host: file.example.com
+  port: 1883
+  keepAlive: 30s
+offlineQueue:
+  dropPolicies:
+    sensors/#: drop-newest
+`)
+	tomlFile := writeConfigFile(t, "override.toml", `
+[mqtt]
+port = 8883
+useTLS = true
+
+[tls]
+skipVerify = true
+`)
+	t.Setenv("IOT_MQTT_PORT", "9883")
+	t.Setenv("IOT_MQTT_KEEPALIVE", "45")
+
+	l := NewLoader()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-config", yamlFile, "-config", tomlFile, "-mqtt.port", "10883"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := l.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MQTT.Host != "file.example.com" || !cfg.MQTT.UseTLS || !cfg.TLS.SkipVerify {
+		t.Fatalf("file values not applied: %+v", cfg.MQTT)
+	}
+	if cfg.MQTT.Port != 10883 {
+		t.Fatalf("port = %d, want flag value", cfg.MQTT.Port)
+	}
+	if cfg.MQTT.KeepAlive != 45*time.Second {
+		t.Fatalf("keepalive = %v, want env value", cfg.MQTT.KeepAlive)
+	}
+	if cfg.OfflineQueue.DropPolicies["sensors/#"] != "drop-newest" {
+		t.Fatalf("drop policies = %v", cfg.OfflineQueue.DropPolicies)
+	}
+
+	sources := map[string]Origin{
+		"mqtt.host":      {SourceFile, yamlFile},
+		"mqtt.useTLS":    {SourceFile, tomlFile},
+		"mqtt.keepAlive": {SourceEnv, "IOT_MQTT_KEEPALIVE"},
+		"mqtt.port":      {SourceFlag, "mqtt.port"},
+		"mqtt.transport": {SourceDefault, ""},
+	}
+	for key, want := range sources {
+		if got := l.Source(key); got != want {
+			t.Errorf("Source(%s) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLoaderRejectsBadInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"unknown key", "c.json", `{"mqtt":{"hots":"x"}}`, `unknown configuration key "mqtt.hots"`},
+		{"bad value", "c.json", `{"mqtt":{"port":"abc"}}`, "mqtt.port: invalid integer"},
+		{"keepalive range", "c.yaml", "mqtt:\n  keepAlive: 70000", "keepalive"},
+		{"pin format", "c.yaml", "tls:\n  pinnedSPKI: [nope]", "invalid SPKI pin"},
+		{"missing CA file", "c.toml", "[tls]\ncaFiles = [\"/nonexistent/ca.pem\"]", "TLS file"},
+		{"wss without TLS", "c.yaml", "mqtt:\n  transport: wss", "wss requires TLS"},
+		{"rrpc workers", "c.yaml", "rrpc:\n  workers: -1", "must not be negative"},
+		{"rrpc response format", "c.yaml", "rrpc:\n  responseFormat: xml", "unsupported RRPC response format"},
+		{"format", "c.ini", "", "unsupported config file format"},
+	}
+	base := `{"device":{"productKey":"pk","deviceName":"dn","deviceSecret":"s"}}`
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLoader()
+			l.AddFile(writeConfigFile(t, "base.json", base))
+			l.AddFile(writeConfigFile(t, tt.file, tt.content))
+			_, err := l.Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFromEnvIgnoresMalformedValues(t *testing.T) {
+	t.Setenv("IOT_MQTT_HOST", "env.example.com")
+	t.Setenv("IOT_MQTT_PORT", "not-a-port")
+
+	cfg := NewConfig()
+	if err := cfg.LoadFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MQTT.Host != "env.example.com" || cfg.MQTT.Port != 1883 {
+		t.Fatalf("host = %s, port = %d", cfg.MQTT.Host, cfg.MQTT.Port)
+	}
+}
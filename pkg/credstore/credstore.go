@@ -0,0 +1,68 @@
+// Package credstore keeps device identities (device secret and, for
+// non-whitelist dynamic registration, MQTT credentials) on the device.
+package credstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Store types accepted by Open.
+const (
+	TypeEncrypted = "encrypted"
+	TypePlain     = "plain"
+)
+
+// ErrNotFound is returned by Get when no identity is stored for a device.
+var ErrNotFound = errors.New("credstore: identity not found")
+
+// Identity is what a device needs to connect.
+type Identity struct {
+	ProductKey   string    `json:"productKey"`
+	DeviceName   string    `json:"deviceName"`
+	DeviceSecret string    `json:"deviceSecret,omitempty"`
+	ClientID     string    `json:"clientId,omitempty"`
+	Username     string    `json:"username,omitempty"`
+	Password     string    `json:"password,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Usable reports whether the identity can authenticate: it holds a device
+// secret, or a client ID and password issued by registration.
+func (i *Identity) Usable() bool {
+	return i.DeviceSecret != "" || (i.ClientID != "" && i.Password != "")
+}
+
+// Store persists device identities keyed by product key and device name.
+type Store interface {
+	Get(productKey, deviceName string) (*Identity, error)
+	Put(identity *Identity) error
+	Delete(productKey, deviceName string) error
+}
+
+// Open opens the store at path. storeType is TypeEncrypted (the default)
+// or TypePlain. Encrypted stores derive their key from passphrase, or from
+// the machine ID when passphrase is empty.
+func Open(path, storeType, passphrase string) (Store, error) {
+	switch storeType {
+	case "", TypeEncrypted:
+		secret := []byte(passphrase)
+		if passphrase == "" {
+			id, err := MachineID()
+			if err != nil {
+				return nil, fmt.Errorf("credstore: no passphrase and %w", err)
+			}
+			secret = id
+		}
+		return NewEncryptedFileStore(path, secret), nil
+	case TypePlain:
+		return NewFileStore(path), nil
+	default:
+		return nil, fmt.Errorf("credstore: unsupported store type: %s", storeType)
+	}
+}
+
+func key(productKey, deviceName string) string {
+	return productKey + "/" + deviceName
+}
@@ -0,0 +1,182 @@
+package credstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.json")
+	store := NewFileStore(path)
+
+	if _, err := store.Get("pk", "dn"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get on empty store = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Put(&Identity{ProductKey: "pk", DeviceName: "dn", DeviceSecret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := NewFileStore(path).Get("pk", "dn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.DeviceSecret != "s3cret" || identity.UpdatedAt.IsZero() {
+		t.Fatalf("identity = %+v", identity)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("store mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := store.Delete("pk", "dn"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("pk", "dn"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Put(&Identity{DeviceSecret: "x"}); err == nil {
+		t.Fatal("expected error for identity without product key and device name")
+	}
+}
+
+func TestEncryptedFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.enc")
+	store := NewEncryptedFileStore(path, []byte("passphrase"))
+
+	identity := &Identity{ProductKey: "pk", DeviceName: "dn", DeviceSecret: "s3cret", Password: "mqtt-password"}
+	if err := store.Put(identity); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), "mqtt-password") {
+		t.Fatal("secrets stored in clear text")
+	}
+
+	got, err := NewEncryptedFileStore(path, []byte("passphrase")).Get("pk", "dn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DeviceSecret != "s3cret" || got.Password != "mqtt-password" {
+		t.Fatalf("identity = %+v", got)
+	}
+
+	if _, err := NewEncryptedFileStore(path, []byte("wrong")).Get("pk", "dn"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Get with wrong passphrase = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := Open(filepath.Join(dir, "a"), "vault", ""); err == nil {
+		t.Fatal("expected error for unsupported store type")
+	}
+
+	store, err := Open(filepath.Join(dir, "b"), TypePlain, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs, ok := store.(*FileStore); !ok || fs.codec != (plainCodec{}) {
+		t.Fatalf("Open(plain) = %#v", store)
+	}
+
+	store, err = Open(filepath.Join(dir, "c"), "", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*FileStore).codec.(aesCodec); !ok {
+		t.Fatal("default store type is not encrypted")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.enc")
+	journal, err := OpenJournal(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, identity := range []*Identity{
+		{ProductKey: "pk", DeviceName: "a", DeviceSecret: "s3cret-a"},
+		{ProductKey: "pk", DeviceName: "b", DeviceSecret: "s3cret-b"},
+		{ProductKey: "pk", DeviceName: "a", DeviceSecret: "s3cret-a2"},
+	} {
+		if err := journal.Put(identity); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := journal.Delete("pk", "b"); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Fatal("secrets stored in clear text")
+	}
+	// A record cut off by a crash is dropped on open
+	if err := os.WriteFile(path, append(data, `{"nonce":"AAAA`...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	journal, err = OpenJournal(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := journal.List()
+	if len(list) != 1 || list[0].DeviceName != "a" || list[0].DeviceSecret != "s3cret-a2" {
+		t.Fatalf("identities = %+v", list)
+	}
+	if err := journal.Put(&Identity{ProductKey: "pk", DeviceName: "c", DeviceSecret: "s3cret-c"}); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	journal, err = OpenJournal(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	if _, err := journal.Get("pk", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := journal.Get("pk", "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted device: err = %v", err)
+	}
+
+	if _, err := OpenJournal(path, []byte("wrong")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("OpenJournal with wrong passphrase = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestJournalWithoutRecordsChecksPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.enc")
+	journal, err := OpenJournal(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	if _, err := OpenJournal(path, []byte("wrong")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("OpenJournal with wrong passphrase = %v, want ErrDecrypt", err)
+	}
+	journal, err = OpenJournal(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+}
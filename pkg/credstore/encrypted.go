@@ -0,0 +1,111 @@
+package credstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrDecrypt is returned when the store cannot be decrypted, usually
+// because the passphrase or machine ID differs from the one that wrote it.
+var ErrDecrypt = errors.New("credstore: failed to decrypt store")
+
+// envelope is the on-disk form of an encrypted store.
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// aesCodec encrypts the store with AES-256-GCM under a key derived from a
+// secret with scrypt. A fresh salt and nonce are used for every write.
+type aesCodec struct {
+	secret []byte
+}
+
+// NewEncryptedFileStore returns a store encrypted with a key derived from
+// secret, typically a passphrase or the value returned by MachineID.
+func NewEncryptedFileStore(path string, secret []byte) *FileStore {
+	return &FileStore{path: path, codec: aesCodec{secret: secret}}
+}
+
+func (c aesCodec) encode(plain []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := c.cipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&envelope{
+		Version:    1,
+		KDF:        "scrypt",
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plain, nil),
+	})
+}
+
+func (c aesCodec) decode(data []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Version != 1 || env.KDF != "scrypt" {
+		return nil, fmt.Errorf("%w: unrecognised format", ErrDecrypt)
+	}
+	gcm, err := c.cipher(env.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+func (c aesCodec) cipher(salt []byte) (cipher.AEAD, error) {
+	if len(c.secret) == 0 {
+		return nil, fmt.Errorf("credstore: encryption secret is empty")
+	}
+	key, err := scrypt.Key(c.secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// machineIDPaths are checked in order by MachineID.
+var machineIDPaths = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// MachineID returns the host's machine ID, which binds an encrypted store
+// to the device it was written on.
+func MachineID() ([]byte, error) {
+	for _, path := range machineIDPaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return []byte(id), nil
+		}
+	}
+	return nil, fmt.Errorf("machine ID not found")
+}
@@ -0,0 +1,147 @@
+package credstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// codec converts the identity table to and from its on-disk form.
+type codec interface {
+	encode(plain []byte) ([]byte, error)
+	decode(data []byte) ([]byte, error)
+}
+
+type plainCodec struct{}
+
+func (plainCodec) encode(plain []byte) ([]byte, error) { return plain, nil }
+func (plainCodec) decode(data []byte) ([]byte, error)  { return data, nil }
+
+// FileStore keeps all identities in one JSON file written with mode 0600.
+// Every change rewrites the file atomically.
+type FileStore struct {
+	path  string
+	codec codec
+	mutex sync.Mutex
+}
+
+// NewFileStore returns an unencrypted store. Secrets are readable by anyone
+// with access to the file; use it for development only.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path, codec: plainCodec{}}
+}
+
+func (s *FileStore) Get(productKey, deviceName string) (*Identity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	identities, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := identities[key(productKey, deviceName)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return identity, nil
+}
+
+func (s *FileStore) Put(identity *Identity) error {
+	if identity.ProductKey == "" || identity.DeviceName == "" {
+		return fmt.Errorf("credstore: product key and device name are required")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	identities, err := s.load()
+	if err != nil {
+		return err
+	}
+	stored := *identity
+	if stored.UpdatedAt.IsZero() {
+		stored.UpdatedAt = time.Now()
+	}
+	identities[key(identity.ProductKey, identity.DeviceName)] = &stored
+	return s.save(identities)
+}
+
+// List returns every stored identity, ordered by product key and device
+// name.
+func (s *FileStore) List() ([]*Identity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	identities, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(identities))
+	for k := range identities {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]*Identity, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, identities[k])
+	}
+	return list, nil
+}
+
+func (s *FileStore) Delete(productKey, deviceName string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	identities, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(identities, key(productKey, deviceName))
+	return s.save(identities)
+}
+
+func (s *FileStore) load() (map[string]*Identity, error) {
+	identities := make(map[string]*Identity)
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return identities, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("credstore: failed to read %s: %w", s.path, err)
+	}
+
+	plain, err := s.codec.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(plain, &identities); err != nil {
+		return nil, fmt.Errorf("credstore: corrupt store %s: %w", s.path, err)
+	}
+	return identities, nil
+}
+
+func (s *FileStore) save(identities map[string]*Identity) error {
+	plain, err := json.MarshalIndent(identities, "", "  ")
+	if err != nil {
+		return err
+	}
+	data, err := s.codec.encode(plain)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("credstore: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("credstore: failed to write %s: %w", s.path, err)
+	}
+	return os.Rename(tmp, s.path)
+}
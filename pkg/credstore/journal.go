@@ -0,0 +1,207 @@
+package credstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// journalHeader is the first line of a journal. Check seals journalCheck
+// so a wrong secret is detected even before any record is written.
+type journalHeader struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	CheckNonce []byte `json:"checkNonce"`
+	Check      []byte `json:"check"`
+}
+
+var journalCheck = []byte("iot-go-sdk credstore journal")
+
+// journalRecord is one encrypted identity.
+type journalRecord struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Journal is an encrypted store for writing many identities, e.g. when
+// provisioning a fleet. Unlike an encrypted FileStore it derives its key
+// once when opened and appends one line per change instead of rewriting
+// the file, and every Put is synced to disk before it returns. The last
+// record for a device wins; a record without credentials deletes it.
+type Journal struct {
+	mutex      sync.Mutex
+	file       *os.File
+	gcm        cipher.AEAD
+	identities map[string]*Identity
+}
+
+// OpenJournal opens or creates the journal at path, encrypted with a key
+// derived from secret. A record cut off by an interrupted write is
+// dropped.
+func OpenJournal(path string, secret []byte) (*Journal, error) {
+	codec := aesCodec{secret: secret}
+	j := &Journal{identities: make(map[string]*Identity)}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("credstore: failed to read %s: %w", path, err)
+	}
+	// Only complete lines count
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+
+	var header journalHeader
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	if scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Version != 1 || header.KDF != "scrypt" || len(header.Check) == 0 {
+			return nil, fmt.Errorf("%w: unrecognised journal format", ErrDecrypt)
+		}
+	} else {
+		header = journalHeader{Version: 1, KDF: "scrypt", Salt: make([]byte, 16)}
+		if _, err := rand.Read(header.Salt); err != nil {
+			return nil, err
+		}
+	}
+	if j.gcm, err = codec.cipher(header.Salt); err != nil {
+		return nil, err
+	}
+	if header.Check == nil {
+		header.CheckNonce = make([]byte, j.gcm.NonceSize())
+		if _, err := rand.Read(header.CheckNonce); err != nil {
+			return nil, err
+		}
+		header.Check = j.gcm.Seal(nil, header.CheckNonce, journalCheck, nil)
+	} else if plain, err := j.gcm.Open(nil, header.CheckNonce, header.Check, nil); err != nil || !bytes.Equal(plain, journalCheck) {
+		return nil, ErrDecrypt
+	}
+
+	for scanner.Scan() {
+		var record journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("credstore: corrupt journal %s: %w", path, err)
+		}
+		plain, err := j.gcm.Open(nil, record.Nonce, record.Ciphertext, nil)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		var identity Identity
+		if err := json.Unmarshal(plain, &identity); err != nil {
+			return nil, fmt.Errorf("credstore: corrupt journal %s: %w", path, err)
+		}
+		j.apply(&identity)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("credstore: failed to read %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("credstore: %w", err)
+	}
+	// Rewrite the complete lines to drop a cut off record, then append
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("credstore: %w", err)
+	}
+	err = file.Truncate(int64(len(data)))
+	if err == nil && len(data) == 0 {
+		line, _ := json.Marshal(&header)
+		_, err = file.Write(append(line, '\n'))
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("credstore: failed to write %s: %w", path, err)
+	}
+	j.file = file
+	return j, nil
+}
+
+func (j *Journal) apply(identity *Identity) {
+	if identity.Usable() {
+		j.identities[key(identity.ProductKey, identity.DeviceName)] = identity
+	} else {
+		delete(j.identities, key(identity.ProductKey, identity.DeviceName))
+	}
+}
+
+func (j *Journal) Get(productKey, deviceName string) (*Identity, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	identity, ok := j.identities[key(productKey, deviceName)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return identity, nil
+}
+
+func (j *Journal) Put(identity *Identity) error {
+	if identity.ProductKey == "" || identity.DeviceName == "" {
+		return fmt.Errorf("credstore: product key and device name are required")
+	}
+	stored := *identity
+	if stored.UpdatedAt.IsZero() {
+		stored.UpdatedAt = time.Now()
+	}
+	plain, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	nonce := make([]byte, j.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	line, err := json.Marshal(&journalRecord{Nonce: nonce, Ciphertext: j.gcm.Seal(nil, nonce, plain, nil)})
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("credstore: failed to write journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("credstore: failed to write journal: %w", err)
+	}
+	j.apply(&stored)
+	return nil
+}
+
+// Delete appends a record without credentials for the device.
+func (j *Journal) Delete(productKey, deviceName string) error {
+	return j.Put(&Identity{ProductKey: productKey, DeviceName: deviceName})
+}
+
+// List returns every stored identity, ordered by product key and device
+// name.
+func (j *Journal) List() []*Identity {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	keys := make([]string, 0, len(j.identities))
+	for k := range j.identities {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]*Identity, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, j.identities[k])
+	}
+	return list
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
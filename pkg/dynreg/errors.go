@@ -0,0 +1,93 @@
+package dynreg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrAlreadyRegistered means the device has been activated before; the
+	// platform hands out the device secret only once
+	ErrAlreadyRegistered = errors.New("device already registered")
+	// ErrNotPreRegistered means whitelist registration was attempted for a
+	// device that was not created on the platform
+	ErrNotPreRegistered = errors.New("device not pre-registered")
+	// ErrSignature means the request could not be signed or the platform
+	// rejected its signature, usually because of a wrong product secret
+	ErrSignature = errors.New("registration signature invalid")
+	// ErrNoCredentials means the platform accepted the registration but
+	// its response holds neither a device secret nor MQTT credentials
+	ErrNoCredentials = errors.New("registration response carries no credentials")
+)
+
+// Platform result codes of a registration request.
+const (
+	CodeSuccess           = 200
+	CodeSignatureInvalid  = 6207
+	CodeNotPreRegistered  = 6288
+	CodeAlreadyRegistered = 6289
+)
+
+// RegistrationError is a registration refused by the platform. It wraps
+// ErrAlreadyRegistered, ErrNotPreRegistered or ErrSignature when the
+// reason is known.
+type RegistrationError struct {
+	// StatusCode is the HTTP status; it is 0 for MQTT registration
+	StatusCode int
+	Code       int
+	Message    string
+	RequestID  string
+	Err        error
+}
+
+func newRegistrationError(statusCode, code int, message, requestID string) *RegistrationError {
+	e := &RegistrationError{StatusCode: statusCode, Code: code, Message: message, RequestID: requestID}
+	switch {
+	case code == CodeAlreadyRegistered || statusCode == http.StatusConflict:
+		e.Err = ErrAlreadyRegistered
+	case code == CodeNotPreRegistered || statusCode == http.StatusNotFound:
+		e.Err = ErrNotPreRegistered
+	case code == CodeSignatureInvalid || statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		e.Err = ErrSignature
+	}
+	return e
+}
+
+func (e *RegistrationError) Error() string {
+	msg := fmt.Sprintf("dynamic registration failed: code=%d, message=%s", e.Code, e.Message)
+	if e.StatusCode != 0 && e.StatusCode != http.StatusOK {
+		msg = fmt.Sprintf("dynamic registration failed: HTTP %d, code=%d, message=%s", e.StatusCode, e.Code, e.Message)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *RegistrationError) Unwrap() error {
+	return e.Err
+}
+
+// temporary reports whether retrying may succeed. Only statuses saying the
+// request was not processed qualify, since registration is not idempotent.
+func (e *RegistrationError) temporary() bool {
+	return e.StatusCode == http.StatusServiceUnavailable || e.StatusCode == http.StatusTooManyRequests
+}
+
+// UnknownOutcomeError is a registration that failed after the request was
+// sent, e.g. because the connection broke before the response arrived.
+// The platform may have activated the device; it is not retried, since a
+// retry would be refused as already registered and the device secret
+// would be lost.
+type UnknownOutcomeError struct {
+	Err error
+}
+
+func (e *UnknownOutcomeError) Error() string {
+	return "dynamic registration outcome unknown, the device may have been activated: " + e.Err.Error()
+}
+
+func (e *UnknownOutcomeError) Unwrap() error {
+	return e.Err
+}
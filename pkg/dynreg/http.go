@@ -1,21 +1,34 @@
 package dynreg
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/iot-go-sdk/pkg/auth"
 	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/credstore"
+	"github.com/iot-go-sdk/pkg/logging"
+	tlsutil "github.com/iot-go-sdk/pkg/tls"
 )
 
 type HTTPDynRegClient struct {
 	config     *config.Config
 	httpClient *http.Client
+	store      credstore.Store
+	logger     logging.Logger
+	// retryBackoff is the delay before the first retry; it doubles on
+	// every further attempt
+	retryBackoff time.Duration
 }
 
 type DynRegRequest struct {
@@ -37,28 +50,125 @@ type Data struct {
 	DeviceSecret string `json:"deviceSecret"`
 }
 
+const maxRetryBackoff = 30 * time.Second
+
 func NewHTTPDynRegClient(cfg *config.Config) *HTTPDynRegClient {
+	timeout := cfg.DynReg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
 	return &HTTPDynRegClient{
 		config: cfg,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: timeout,
 		},
+		logger:       logging.Default().Named("dynreg"),
+		retryBackoff: time.Second,
 	}
 }
 
+func (c *HTTPDynRegClient) SetLogger(logger logging.Logger) {
+	c.logger = logging.Redacting(logger)
+}
+
+// SetCredentialStore sets the store the device secret is saved to after a
+// successful registration, overriding Config.CredentialStore.
+func (c *HTTPDynRegClient) SetCredentialStore(store credstore.Store) {
+	c.store = store
+}
+
+// Register obtains the device secret and saves it to the credential store,
+// if one is configured. When saving fails the secret is returned together
+// with the error, since the platform hands it out only once.
 func (c *HTTPDynRegClient) Register() (string, error) {
-	if c.config.Device.ProductSecret == "" {
+	return c.RegisterContext(context.Background())
+}
+
+// RegisterContext is like Register; ctx cancels the request and any
+// pending retry. Connection failures before the request is sent and HTTP
+// 503/429 responses are retried up to DynRegConfig.Retries times.
+// Refusals by the platform are returned as a *RegistrationError, failures
+// after the request was sent as an *UnknownOutcomeError.
+func (c *HTTPDynRegClient) RegisterContext(ctx context.Context) (string, error) {
+	key := c.config.Device.ProductKeyProvider()
+	if key == nil {
 		return "", fmt.Errorf("product secret is required for dynamic registration")
 	}
 
+	endpoint, err := url.Parse(c.config.DynReg.URL(c.config.MQTT.Host))
+	if err != nil {
+		return "", fmt.Errorf("invalid registration endpoint: %w", err)
+	}
+	httpClient, err := c.client(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if endpoint.Scheme != "https" {
+		c.logger.Warn("dynamic registration over plaintext HTTP", "url", endpoint.String())
+	}
+
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		deviceSecret, err := c.register(ctx, httpClient, endpoint.String(), key)
+		if err == nil {
+			if err := saveIdentity(c.config, c.store, &credstore.Identity{DeviceSecret: deviceSecret}); err != nil {
+				return deviceSecret, err
+			}
+			return deviceSecret, nil
+		}
+		if attempt >= c.config.DynReg.Retries || !retryable(ctx, err) {
+			return "", err
+		}
+
+		c.logger.Warn("dynamic registration failed, retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", fmt.Errorf("dynamic registration cancelled: %w", ctx.Err())
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// client returns the HTTP client for endpoint; HTTPS verifies the server
+// with the CA and pins of the MQTT TLS settings.
+func (c *HTTPDynRegClient) client(endpoint *url.URL) (*http.Client, error) {
+	if endpoint.Scheme != "https" || c.httpClient.Transport != nil {
+		return c.httpClient, nil
+	}
+
+	// Registration authenticates with the product secret, not a device certificate
+	registrationTLS := c.config.TLS
+	registrationTLS.ClientCert, registrationTLS.ClientKey, registrationTLS.ClientPKCS12 = "", "", ""
+	tlsConfig, err := tlsutil.NewConfig(&registrationTLS, endpoint.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	client := *c.httpClient
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	return &client, nil
+}
+
+func (c *HTTPDynRegClient) register(ctx context.Context, httpClient *http.Client, reqURL string, key auth.KeyProvider) (string, error) {
 	random := fmt.Sprintf("%d", time.Now().UnixMilli())
 
-	signature := auth.GenerateDynRegSignature(
+	signature, err := auth.GenerateDynRegSignatureWithKey(
 		c.config.Device.ProductKey,
 		c.config.Device.DeviceName,
-		c.config.Device.ProductSecret,
 		random,
+		key,
 	)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to sign registration request: %v", ErrSignature, err)
+	}
 
 	formData := url.Values{}
 	formData.Set("productKey", c.config.Device.ProductKey)
@@ -67,9 +177,12 @@ func (c *HTTPDynRegClient) Register() (string, error) {
 	formData.Set("sign", signature)
 	formData.Set("signMethod", "hmacsha256")
 
-	reqURL := fmt.Sprintf("http://%s/auth/register/device", c.config.MQTT.Host)
-	
-	req, err := http.NewRequest("POST", reqURL, strings.NewReader(formData.Encode()))
+	// Errors after the request was written leave the outcome unknown
+	var sent atomic.Bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) { sent.Store(info.Err == nil) },
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, strings.NewReader(formData.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -77,30 +190,52 @@ func (c *HTTPDynRegClient) Register() (string, error) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "text/xml,text/javascript,text/html,application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
+		if sent.Load() {
+			return "", &UnknownOutcomeError{Err: err}
+		}
 		return "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, string(body))
+		return "", &UnknownOutcomeError{Err: fmt.Errorf("failed to read response: %w", err)}
 	}
 
 	var dynRegResp DynRegResponse
 	if err := json.Unmarshal(body, &dynRegResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", newRegistrationError(resp.StatusCode, 0, strings.TrimSpace(string(body)), "")
+		}
 		return "", fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	if dynRegResp.Code != 200 {
-		return "", fmt.Errorf("dynamic registration failed: code=%d, message=%s", dynRegResp.Code, dynRegResp.Message)
+	if resp.StatusCode != http.StatusOK || dynRegResp.Code != CodeSuccess {
+		return "", newRegistrationError(resp.StatusCode, dynRegResp.Code, dynRegResp.Message, dynRegResp.RequestId)
+	}
+	if dynRegResp.Data.DeviceSecret == "" {
+		return "", fmt.Errorf("dynamic registration failed: %w", ErrNoCredentials)
 	}
-
 	return dynRegResp.Data.DeviceSecret, nil
 }
 
+// retryable reports whether a failed attempt may succeed when repeated
+// without risking a second activation: network errors before the request
+// was sent and HTTP 503/429 are, refusals and unknown outcomes are not.
+func retryable(ctx context.Context, err error) bool {
+	var unknown *UnknownOutcomeError
+	if ctx.Err() != nil || errors.Is(err, ErrSignature) || errors.As(err, &unknown) {
+		return false
+	}
+	var regErr *RegistrationError
+	if errors.As(err, &regErr) {
+		return regErr.temporary()
+	}
+	// Connection failures and timeouts, but not certificate errors
+	var opErr *net.OpError
+	var netErr net.Error
+	return errors.As(err, &opErr) || (errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,185 @@
+package dynreg
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/config"
+)
+
+// newTLSRegistry starts an HTTPS registration endpoint and returns a config
+// trusting its certificate.
+func newTLSRegistry(t *testing.T, handler http.HandlerFunc) *config.Config {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	cfg := config.NewConfig()
+	cfg.Device.ProductKey = "pk"
+	cfg.Device.DeviceName = "dn"
+	cfg.Device.ProductSecret = "productSecret"
+	cfg.MQTT.Host = "broker.invalid"
+	cfg.DynReg.Host = host
+	cfg.DynReg.Port, _ = strconv.Atoi(port)
+	cfg.TLS.CACert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	return cfg
+}
+
+func TestHTTPRegisterOverTLSWithRetry(t *testing.T) {
+	var attempts atomic.Int32
+	cfg := newTLSRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/auth/register/device" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		r.ParseForm()
+		want := auth.GenerateDynRegSignature("pk", "dn", "productSecret", r.PostForm.Get("random"))
+		if r.PostForm.Get("sign") != want {
+			fmt.Fprint(w, `{"code":6207,"message":"sign check failed"}`)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"data":{"deviceSecret":"issuedSecret"}}`)
+	})
+
+	client := NewHTTPDynRegClient(cfg)
+	client.retryBackoff = time.Millisecond
+	secret, err := client.RegisterContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "issuedSecret" || attempts.Load() != 2 {
+		t.Fatalf("secret = %q after %d attempts", secret, attempts.Load())
+	}
+}
+
+func TestHTTPRegisterTypedErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusOK, `{"code":6289,"message":"device already active"}`, ErrAlreadyRegistered},
+		{http.StatusOK, `{"code":6288,"message":"device not found"}`, ErrNotPreRegistered},
+		{http.StatusOK, `{"code":6207,"message":"sign check failed"}`, ErrSignature},
+		{http.StatusForbidden, `forbidden`, ErrSignature},
+	}
+	for _, tt := range tests {
+		var attempts atomic.Int32
+		cfg := newTLSRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		})
+
+		_, err := NewHTTPDynRegClient(cfg).Register()
+		var regErr *RegistrationError
+		if !errors.Is(err, tt.want) || !errors.As(err, &regErr) {
+			t.Errorf("%s: err = %v, want %v", tt.body, err, tt.want)
+		}
+		if attempts.Load() != 1 {
+			t.Errorf("%s: refusal retried %d times", tt.body, attempts.Load()-1)
+		}
+	}
+}
+
+func TestHTTPRegisterRejectsUntrustedServer(t *testing.T) {
+	cfg := newTLSRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached untrusted server")
+	})
+	cfg.TLS.CACert = ""
+
+	if _, err := NewHTTPDynRegClient(cfg).Register(); err == nil {
+		t.Fatal("expected certificate verification error")
+	}
+}
+
+func TestHTTPRegisterCancel(t *testing.T) {
+	cfg := newTLSRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	})
+	client := NewHTTPDynRegClient(cfg)
+	client.retryBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.RegisterContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestHTTPRegisterDoesNotRetryAfterSending(t *testing.T) {
+	var attempts atomic.Int32
+	cfg := newTLSRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// The platform processed the request but the response is lost
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	})
+	cfg.DynReg.Retries = 3
+	client := NewHTTPDynRegClient(cfg)
+	client.retryBackoff = time.Millisecond
+
+	_, err := client.RegisterContext(context.Background())
+	var unknown *UnknownOutcomeError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("err = %v, want *UnknownOutcomeError", err)
+	}
+	if attempts.Load() != 1 {
+		t.Fatalf("sent request retried %d times", attempts.Load()-1)
+	}
+}
+
+func TestHTTPRegisterRetriesConnectionFailures(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var attempts atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// Fail the TLS handshake, before the request is written
+			attempts.Add(1)
+			conn.Close()
+		}
+	}()
+
+	cfg := newTLSRegistry(t, nil)
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	cfg.DynReg.Host = host
+	cfg.DynReg.Port, _ = strconv.Atoi(port)
+	cfg.DynReg.Retries = 2
+	client := NewHTTPDynRegClient(cfg)
+	client.retryBackoff = time.Millisecond
+
+	_, err = client.RegisterContext(context.Background())
+	var unknown *UnknownOutcomeError
+	if err == nil || errors.As(err, &unknown) {
+		t.Fatalf("err = %v, want a connection error", err)
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("%d attempts, want 3", attempts.Load())
+	}
+}
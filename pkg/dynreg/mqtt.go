@@ -1,13 +1,10 @@
 package dynreg
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"context"
 	"crypto/tls"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 	"time"
@@ -15,16 +12,20 @@ import (
 	"github.com/eclipse/paho.mqtt.golang"
 	"github.com/iot-go-sdk/pkg/auth"
 	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/credstore"
+	"github.com/iot-go-sdk/pkg/logging"
+	iotmqtt "github.com/iot-go-sdk/pkg/mqtt"
 	tlsutil "github.com/iot-go-sdk/pkg/tls"
 )
 
 type MQTTDynRegClient struct {
 	config        *config.Config
 	mqttClient    mqtt.Client
-	logger        *log.Logger
+	logger        logging.Logger
 	response      chan *MQTTDynRegResponse
 	mutex         sync.Mutex
 	skipPreRegist bool // Store skipPreRegist flag for auth type determination
+	store         credstore.Store
 }
 
 type MQTTDynRegRequest struct {
@@ -53,17 +54,35 @@ type MQTTDynRegResponseData struct {
 func NewMQTTDynRegClient(cfg *config.Config) *MQTTDynRegClient {
 	return &MQTTDynRegClient{
 		config:   cfg,
-		logger:   log.Default(),
+		logger:   logging.Default().Named("dynreg"),
 		response: make(chan *MQTTDynRegResponse, 1),
 	}
 }
 
-func (c *MQTTDynRegClient) SetLogger(logger *log.Logger) {
-	c.logger = logger
+func (c *MQTTDynRegClient) SetLogger(logger logging.Logger) {
+	c.logger = logging.Redacting(logger)
 }
 
+// SetCredentialStore sets the store the registration result is saved to,
+// overriding Config.CredentialStore.
+func (c *MQTTDynRegClient) SetCredentialStore(store credstore.Store) {
+	c.store = store
+}
+
+// Register performs dynamic registration and saves the result to the
+// credential store, if one is configured. When saving fails the result is
+// returned together with the error, since the platform hands it out only
+// once.
 func (c *MQTTDynRegClient) Register(skipPreRegist bool, timeout time.Duration) (*MQTTDynRegResponseData, error) {
-	if c.config.Device.ProductSecret == "" {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.RegisterContext(ctx, skipPreRegist)
+}
+
+// RegisterContext is like Register but waits for the platform's answer
+// until ctx is done.
+func (c *MQTTDynRegClient) RegisterContext(ctx context.Context, skipPreRegist bool) (*MQTTDynRegResponseData, error) {
+	if c.config.Device.ProductKeyProvider() == nil {
 		return nil, fmt.Errorf("product secret is required for MQTT dynamic registration")
 	}
 
@@ -84,11 +103,24 @@ func (c *MQTTDynRegClient) Register(skipPreRegist bool, timeout time.Duration) (
 	select {
 	case resp := <-c.response:
 		if resp.Code != 200 && resp.Code != 0 {  // Some servers may return 0 for success
-			return nil, fmt.Errorf("dynamic registration failed: code=%d, message=%s", resp.Code, resp.Message)
+			return nil, newRegistrationError(0, resp.Code, resp.Message, resp.RequestId)
+		}
+		identity := &credstore.Identity{
+			DeviceSecret: resp.Data.DeviceSecret,
+			ClientID:     resp.Data.ClientId,
+			Username:     resp.Data.Username,
+			Password:     resp.Data.Password,
+		}
+		// Saving an empty identity would keep the device from registering again
+		if !identity.Usable() {
+			return nil, fmt.Errorf("dynamic registration failed: %w", ErrNoCredentials)
+		}
+		if err := saveIdentity(c.config, c.store, identity); err != nil {
+			return &resp.Data, err
 		}
 		return &resp.Data, nil
-	case <-time.After(timeout):
-		return nil, fmt.Errorf("dynamic registration timeout after %v", timeout)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("dynamic registration timeout: %w", ctx.Err())
 	}
 }
 
@@ -116,38 +148,43 @@ func (c *MQTTDynRegClient) connect() error {
 	
 	// Generate password using HMAC-SHA256
 	// For dynamic registration: sign(content) where content = "deviceName{deviceName}productKey{productKey}random{random}"
-	content := fmt.Sprintf("deviceName%sproductKey%srandom%s", 
-		c.config.Device.DeviceName,
+	signature, err := auth.GenerateDynRegSignatureWithKey(
 		c.config.Device.ProductKey,
-		random)
-	password := calculateHMACSHA256(content, c.config.Device.ProductSecret)
+		c.config.Device.DeviceName,
+		random,
+		c.config.Device.ProductKeyProvider())
+	if err != nil {
+		return fmt.Errorf("failed to sign registration credentials: %w", err)
+	}
+	// Use uppercase hex to match C SDK format
+	password := strings.ToUpper(signature)
 	
-	c.logger.Printf("Dynamic registration auth type: %s", authType)
-	c.logger.Printf("Dynamic registration random: %s", random)
-	c.logger.Printf("Dynamic registration sign content: %s", content)
-	c.logger.Printf("Dynamic registration product secret: %s", c.config.Device.ProductSecret)
-	c.logger.Printf("Dynamic registration password: %s", password)
+	c.logger.Debug("dynamic registration credentials",
+		"authType", authType,
+		"random", random,
+		"password", password)
 	
 	opts := mqtt.NewClientOptions()
 	
-	broker := fmt.Sprintf("tcp://%s:%d", c.config.MQTT.Host, c.config.MQTT.Port)
+	var tlsConfig *tls.Config
 	if c.config.MQTT.UseTLS {
-		broker = fmt.Sprintf("ssl://%s:%d", c.config.MQTT.Host, c.config.MQTT.Port)
-		
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: c.config.TLS.SkipVerify,
-		}
-		
-		certPool, err := tlsutil.LoadCACert(c.config.TLS.CACert)
+		// Registration authenticates with the product secret, not a device certificate
+		registrationTLS := c.config.TLS
+		registrationTLS.ClientCert, registrationTLS.ClientKey, registrationTLS.ClientPKCS12 = "", "", ""
+
+		var err error
+		tlsConfig, err = tlsutil.NewConfig(&registrationTLS, c.config.MQTT.Host)
 		if err != nil {
-			return fmt.Errorf("failed to load CA certificate: %w", err)
+			return err
 		}
-		tlsConfig.RootCAs = certPool
-		
-		opts.SetTLSConfig(tlsConfig)
 	}
 	
-	opts.AddBroker(broker)
+	// Use the same TCP/WebSocket and proxy settings as the device connection
+	broker, err := iotmqtt.ConfigureTransport(opts, c.config, tlsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to configure MQTT transport: %w", err)
+	}
+	
 	opts.SetClientID(clientID)
 	opts.SetUsername(username)
 	opts.SetPassword(password)
@@ -157,7 +194,7 @@ func (c *MQTTDynRegClient) connect() error {
 	
 	// Set default message handler to receive registration response
 	opts.SetDefaultPublishHandler(func(client mqtt.Client, msg mqtt.Message) {
-		c.logger.Printf("Received message on topic %s: %s", msg.Topic(), string(msg.Payload()))
+		c.logger.Debug("received message", "topic", msg.Topic(), "bytes", len(msg.Payload()))
 		
 		// Check if this is a registration response
 		expectedTopic := fmt.Sprintf("/ext/register/%s/%s", c.config.Device.ProductKey, c.config.Device.DeviceName)
@@ -166,8 +203,7 @@ func (c *MQTTDynRegClient) connect() error {
 		}
 	})
 	
-	c.logger.Printf("Dynamic registration connecting with ClientID: %s", clientID)
-	c.logger.Printf("Dynamic registration Username: %s", username)
+	c.logger.Debug("dynamic registration connecting", "clientId", clientID, "username", username)
 
 	c.mqttClient = mqtt.NewClient(opts)
 	
@@ -176,14 +212,14 @@ func (c *MQTTDynRegClient) connect() error {
 		return token.Error()
 	}
 	
-	c.logger.Printf("Connected to MQTT broker for dynamic registration: %s", broker)
+	c.logger.Info("connected to MQTT broker for dynamic registration", "broker", broker)
 	return nil
 }
 
 func (c *MQTTDynRegClient) disconnect() {
 	if c.mqttClient != nil && c.mqttClient.IsConnected() {
 		c.mqttClient.Disconnect(250)
-		c.logger.Println("Disconnected from MQTT broker")
+		c.logger.Info("disconnected from MQTT broker")
 	}
 }
 
@@ -193,19 +229,22 @@ func (c *MQTTDynRegClient) subscribe(topic string) error {
 		return token.Error()
 	}
 	
-	c.logger.Printf("Subscribed to topic: %s", topic)
+	c.logger.Info("subscribed", "topic", topic)
 	return nil
 }
 
 func (c *MQTTDynRegClient) publishRequest(skipPreRegist bool) error {
 	random := fmt.Sprintf("%d", time.Now().UnixMilli())
 
-	signature := auth.GenerateDynRegSignature(
+	signature, err := auth.GenerateDynRegSignatureWithKey(
 		c.config.Device.ProductKey,
 		c.config.Device.DeviceName,
-		c.config.Device.ProductSecret,
 		random,
+		c.config.Device.ProductKeyProvider(),
 	)
+	if err != nil {
+		return fmt.Errorf("failed to sign registration request: %w", err)
+	}
 
 	skipPreRegistInt := 0
 	if skipPreRegist {
@@ -232,49 +271,37 @@ func (c *MQTTDynRegClient) publishRequest(skipPreRegist bool) error {
 		return token.Error()
 	}
 	
-	c.logger.Printf("Published registration request to topic: %s", topic)
+	c.logger.Info("published registration request", "topic", topic)
 	return nil
 }
 
 func (c *MQTTDynRegClient) messageHandler(client mqtt.Client, msg mqtt.Message) {
-	c.logger.Printf("Processing registration response: %s", string(msg.Payload()))
+	c.logger.Debug("processing registration response", "payload", string(msg.Payload()))
 	
-	// First try to parse as direct response (like C SDK receives)
-	// Format: {"deviceSecret":"xxx"} or {"clientId":"xxx","username":"xxx","password":"xxx"}
-	var directResponse MQTTDynRegResponseData
-	if err := json.Unmarshal(msg.Payload(), &directResponse); err == nil {
-		// Direct response format
-		response := &MQTTDynRegResponse{
-			Code: 0,  // Success
-			Data: directResponse,
-		}
-		
-		select {
-		case c.response <- response:
-			c.logger.Printf("Registration successful, received deviceSecret: %s", directResponse.DeviceSecret)
-		default:
-			c.logger.Println("Response channel is full, dropping message")
-		}
+	// The result is either wrapped like {"code":200,"data":{...}}, which
+	// also carries refusals, or direct like the C SDK receives it:
+	// {"deviceSecret":"xxx"} or {"clientId":"xxx","username":"xxx","password":"xxx"}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Payload(), &fields); err != nil {
+		c.logger.Warn("failed to unmarshal response", "error", err)
 		return
 	}
-	
-	// Try to parse as wrapped response
 	var response MQTTDynRegResponse
-	if err := json.Unmarshal(msg.Payload(), &response); err != nil {
-		c.logger.Printf("Failed to unmarshal response: %v", err)
+	_, hasCode := fields["code"]
+	_, hasData := fields["data"]
+	if hasCode || hasData {
+		if err := json.Unmarshal(msg.Payload(), &response); err != nil {
+			c.logger.Warn("failed to unmarshal response", "error", err)
+			return
+		}
+	} else if err := json.Unmarshal(msg.Payload(), &response.Data); err != nil {
+		c.logger.Warn("failed to unmarshal response", "error", err)
 		return
 	}
-	
+
 	select {
 	case c.response <- &response:
 	default:
-		c.logger.Println("Response channel is full, dropping message")
+		c.logger.Warn("response channel is full, dropping message")
 	}
 }
-
-func calculateHMACSHA256(data, key string) string {
-	h := hmac.New(sha256.New, []byte(key))
-	h.Write([]byte(data))
-	// Use uppercase hex to match C SDK format
-	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
-}
\ No newline at end of file
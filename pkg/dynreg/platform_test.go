@@ -0,0 +1,73 @@
+package dynreg_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/credstore"
+	"github.com/iot-go-sdk/pkg/dynreg"
+	"github.com/iot-go-sdk/pkg/testplatform"
+)
+
+func startPlatform(t *testing.T) (*testplatform.Platform, credstore.Store) {
+	t.Helper()
+	p, err := testplatform.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	p.AddProduct("pk", "productSecret")
+	p.AddDevice("pk", "dn", "")
+	return p, credstore.NewFileStore(filepath.Join(t.TempDir(), "identity.json"))
+}
+
+func TestProvisionRejectsResponsesWithoutCredentials(t *testing.T) {
+	tests := []struct {
+		transport string
+		response  string
+		want      error
+	}{
+		{dynreg.TransportHTTP, `{"code":200,"data":{"deviceSecret":""},"message":"success"}`, dynreg.ErrNoCredentials},
+		{dynreg.TransportMQTT, `{}`, dynreg.ErrNoCredentials},
+		{dynreg.TransportMQTT, `{"clientId":"cid","username":"user"}`, dynreg.ErrNoCredentials},
+		{dynreg.TransportMQTT, `{"code":200,"data":{}}`, dynreg.ErrNoCredentials},
+		{dynreg.TransportMQTT, `{"code":6289,"message":"device already active"}`, dynreg.ErrAlreadyRegistered},
+	}
+	for _, tt := range tests {
+		p, store := startPlatform(t)
+		p.SetRegistrationResponse([]byte(tt.response))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+		cfg, err := dynreg.Provision(ctx, p.Config("pk", "dn"), dynreg.ProvisionOptions{Transport: tt.transport, Store: store})
+		cancel()
+		if cfg != nil || !errors.Is(err, tt.want) {
+			t.Errorf("%s %s: cfg = %v, err = %v, want %v", tt.transport, tt.response, cfg, err, tt.want)
+		}
+		if _, err := store.Get("pk", "dn"); !errors.Is(err, credstore.ErrNotFound) {
+			t.Errorf("%s %s: identity stored, err = %v", tt.transport, tt.response, err)
+		}
+	}
+}
+
+func TestProvisionRegistersOverEmptyStoredIdentity(t *testing.T) {
+	p, store := startPlatform(t)
+	if err := store.Put(&credstore.Identity{ProductKey: "pk", DeviceName: "dn"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg, err := dynreg.Provision(ctx, p.Config("pk", "dn"), dynreg.ProvisionOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Device.DeviceSecret == "" || cfg.Device.DeviceSecret != p.DeviceSecret("pk", "dn") {
+		t.Fatalf("device secret = %q", cfg.Device.DeviceSecret)
+	}
+	if identity, err := store.Get("pk", "dn"); err != nil || identity.DeviceSecret != cfg.Device.DeviceSecret {
+		t.Fatalf("stored identity = %+v, err = %v", identity, err)
+	}
+}
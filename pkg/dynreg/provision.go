@@ -0,0 +1,142 @@
+package dynreg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/credstore"
+	"github.com/iot-go-sdk/pkg/logging"
+)
+
+// Registration transports.
+const (
+	TransportHTTP = "http"
+	TransportMQTT = "mqtt"
+)
+
+const defaultProvisionTimeout = 60 * time.Second
+
+// ProvisionOptions controls Provision.
+type ProvisionOptions struct {
+	// Transport is TransportHTTP or TransportMQTT. By default whitelist
+	// registration uses HTTP and non-whitelist registration MQTT.
+	Transport string
+	// SkipPreRegist registers a device that was not created on the
+	// platform beforehand. The platform then issues MQTT credentials
+	// instead of a device secret; this requires the MQTT transport.
+	SkipPreRegist bool
+	// Store overrides Config.CredentialStore
+	Store credstore.Store
+	// Timeout bounds the registration when ctx has no deadline; it
+	// defaults to 60s
+	Timeout time.Duration
+	Logger  logging.Logger
+}
+
+// Provision returns a copy of cfg that can connect. A device secret or
+// MQTT password already in cfg is used as is; otherwise the identity is
+// loaded from the credential store, and only when none is stored the
+// device registers dynamically and the result is saved to the store.
+func Provision(ctx context.Context, cfg *config.Config, opts ProvisionOptions) (*config.Config, error) {
+	provisioned := *cfg
+	if hasIdentity(&provisioned) {
+		return &provisioned, nil
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.Default().Named("dynreg")
+	}
+
+	store := opts.Store
+	if store == nil {
+		var err error
+		if store, err = cfg.OpenCredentialStore(); err != nil {
+			return nil, fmt.Errorf("failed to open credential store: %w", err)
+		}
+	}
+	if store != nil {
+		err := provisioned.LoadIdentity(store)
+		if err == nil {
+			logger.Info("loaded device identity from credential store")
+			return &provisioned, nil
+		}
+		if !errors.Is(err, credstore.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	transport := opts.Transport
+	if transport == "" {
+		transport = TransportHTTP
+		if opts.SkipPreRegist {
+			transport = TransportMQTT
+		}
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = defaultProvisionTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	logger.Info("registering device", "transport", transport, "skipPreRegist", opts.SkipPreRegist)
+	switch transport {
+	case TransportHTTP:
+		if opts.SkipPreRegist {
+			return nil, fmt.Errorf("non-whitelist registration requires the MQTT transport")
+		}
+		client := NewHTTPDynRegClient(&provisioned)
+		client.SetLogger(logger)
+		client.SetCredentialStore(store)
+		deviceSecret, err := client.RegisterContext(ctx)
+		if deviceSecret == "" {
+			if err == nil {
+				err = ErrNoCredentials
+			}
+			return nil, err
+		}
+		provisioned.Device.DeviceSecret = deviceSecret
+		// A failure to save still leaves a usable configuration
+		return &provisioned, err
+
+	case TransportMQTT:
+		client := NewMQTTDynRegClient(&provisioned)
+		client.SetLogger(logger)
+		client.SetCredentialStore(store)
+		data, err := client.RegisterContext(ctx, opts.SkipPreRegist)
+		if data == nil {
+			if err == nil {
+				err = ErrNoCredentials
+			}
+			return nil, err
+		}
+		applyRegistration(&provisioned, data)
+		return &provisioned, err
+
+	default:
+		return nil, fmt.Errorf("unsupported registration transport: %s", transport)
+	}
+}
+
+// hasIdentity reports whether cfg can connect without registering.
+func hasIdentity(cfg *config.Config) bool {
+	return cfg.Device.DeviceKeyProvider() != nil || cfg.MQTT.Password != "" || cfg.TLS.HasClientCert()
+}
+
+func applyRegistration(cfg *config.Config, data *MQTTDynRegResponseData) {
+	if data.DeviceSecret != "" {
+		cfg.Device.DeviceSecret = data.DeviceSecret
+	}
+	if data.ClientId != "" {
+		cfg.MQTT.ClientID = data.ClientId
+		cfg.MQTT.Username = data.Username
+		cfg.MQTT.Password = data.Password
+	}
+}
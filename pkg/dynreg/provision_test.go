@@ -0,0 +1,73 @@
+package dynreg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iot-go-sdk/pkg/credstore"
+	"github.com/iot-go-sdk/pkg/mqtt"
+)
+
+func TestProvisionRegistersOnceAndPersists(t *testing.T) {
+	var registrations atomic.Int32
+	cfg := newTLSRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		registrations.Add(1)
+		fmt.Fprint(w, `{"code":200,"data":{"deviceSecret":"issuedSecret"}}`)
+	})
+	cfg.CredentialStore.Path = filepath.Join(t.TempDir(), "identity.json")
+	cfg.CredentialStore.Type = credstore.TypePlain
+
+	for i := 0; i < 2; i++ {
+		provisioned, err := Provision(context.Background(), cfg, ProvisionOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if provisioned.Device.DeviceSecret != "issuedSecret" {
+			t.Fatalf("device secret = %q", provisioned.Device.DeviceSecret)
+		}
+	}
+	if registrations.Load() != 1 {
+		t.Fatalf("registered %d times, want once", registrations.Load())
+	}
+	if cfg.Device.DeviceSecret != "" {
+		t.Fatal("Provision modified the passed config")
+	}
+}
+
+func TestProvisionKeepsExistingIdentity(t *testing.T) {
+	cfg := newTLSRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("registered although a device secret is configured")
+	})
+	cfg.Device.DeviceSecret = "configured"
+
+	provisioned, err := Provision(context.Background(), cfg, ProvisionOptions{})
+	if err != nil || provisioned.Device.DeviceSecret != "configured" {
+		t.Fatalf("provisioned = %+v, err = %v", provisioned.Device, err)
+	}
+
+	cfg.Device.DeviceSecret = ""
+	if _, err := Provision(context.Background(), cfg, ProvisionOptions{Transport: TransportHTTP, SkipPreRegist: true}); err == nil {
+		t.Fatal("expected error for non-whitelist registration over HTTP")
+	}
+}
+
+func TestRegisteredCredentialsConnectWithoutSecret(t *testing.T) {
+	cfg := newTLSRegistry(t, nil)
+	cfg.Device.ProductSecret = ""
+	applyRegistration(cfg, &MQTTDynRegResponseData{ClientId: "cid", Username: "user", Password: "pass"})
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := mqtt.NewConfigCredentialProvider(cfg).Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.ClientID != "cid" || credentials.Username != "user" || credentials.Password != "pass" {
+		t.Fatalf("credentials = %+v", credentials)
+	}
+}
@@ -0,0 +1,31 @@
+package dynreg
+
+import (
+	"fmt"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/credstore"
+)
+
+// saveIdentity writes a registration result to store, or to the store
+// configured in cfg.CredentialStore when store is nil. It does nothing when
+// neither is set.
+func saveIdentity(cfg *config.Config, store credstore.Store, identity *credstore.Identity) error {
+	if store == nil {
+		var err error
+		store, err = cfg.OpenCredentialStore()
+		if err != nil {
+			return fmt.Errorf("failed to open credential store: %w", err)
+		}
+		if store == nil {
+			return nil
+		}
+	}
+
+	identity.ProductKey = cfg.Device.ProductKey
+	identity.DeviceName = cfg.Device.DeviceName
+	if err := store.Put(identity); err != nil {
+		return fmt.Errorf("registered but failed to save device identity: %w", err)
+	}
+	return nil
+}
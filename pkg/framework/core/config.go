@@ -0,0 +1,192 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/logging"
+)
+
+// DefaultConfig returns the framework defaults, with the device and MQTT
+// sections taken from config.NewConfig.
+func DefaultConfig() Config {
+	c := Config{
+		MQTT: MQTTConfig{
+			AutoReconnect: true,
+			ReconnectMax:  10,
+			Timeout:       10 * time.Second,
+		},
+		Features: FeatureConfig{EnableOTA: true},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+			Output: "stdout",
+		},
+		Advanced: AdvancedConfig{
+			WorkerCount:     10,
+			EventBufferSize: 100,
+			RequestTimeout:  30 * time.Second,
+		},
+	}
+	c.applySDK(config.NewConfig())
+	return c
+}
+
+// FromSDKConfig returns DefaultConfig with the device and MQTT settings
+// shared with sdk.
+func FromSDKConfig(sdk *config.Config) Config {
+	c := DefaultConfig()
+	c.applySDK(sdk)
+	return c
+}
+
+// SDKConfig returns an SDK configuration carrying the shared device and
+// MQTT settings; everything else keeps the config.NewConfig defaults.
+func (c Config) SDKConfig() *config.Config {
+	sdk := config.NewConfig()
+	c.ApplyTo(sdk)
+	return sdk
+}
+
+// ApplyTo copies the shared device and MQTT settings into sdk.
+func (c Config) ApplyTo(sdk *config.Config) {
+	sdk.Device.ProductKey = c.Device.ProductKey
+	sdk.Device.DeviceName = c.Device.DeviceName
+	sdk.Device.DeviceSecret = c.Device.DeviceSecret
+	sdk.Device.ProductSecret = c.Device.ProductSecret
+	sdk.MQTT.Host = c.MQTT.Host
+	sdk.MQTT.Port = c.MQTT.Port
+	sdk.MQTT.UseTLS = c.MQTT.UseTLS
+	sdk.MQTT.KeepAlive = time.Duration(c.MQTT.KeepAlive) * time.Second
+	sdk.MQTT.CleanSession = c.MQTT.CleanSession
+}
+
+func (c *Config) applySDK(sdk *config.Config) {
+	c.Device.ProductKey = sdk.Device.ProductKey
+	c.Device.DeviceName = sdk.Device.DeviceName
+	c.Device.DeviceSecret = sdk.Device.DeviceSecret
+	c.Device.ProductSecret = sdk.Device.ProductSecret
+	c.MQTT.Host = sdk.MQTT.Host
+	c.MQTT.Port = sdk.MQTT.Port
+	c.MQTT.UseTLS = sdk.MQTT.UseTLS
+	c.MQTT.KeepAlive = int(sdk.MQTT.KeepAlive / time.Second)
+	c.MQTT.CleanSession = sdk.MQTT.CleanSession
+}
+
+// Validate checks the framework-only settings; the shared device and MQTT
+// settings are checked by config.Config.Validate.
+func (c Config) Validate() error {
+	if c.MQTT.ReconnectMax < 0 {
+		return fmt.Errorf("mqtt.reconnectMax must not be negative")
+	}
+	if c.MQTT.Timeout < 0 {
+		return fmt.Errorf("mqtt.timeout must not be negative")
+	}
+	if c.Logging.Level != "" {
+		if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+			return err
+		}
+	}
+	switch c.Logging.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unknown log format: %s", c.Logging.Format)
+	}
+	if c.Logging.MaxSize < 0 || c.Logging.MaxBackups < 0 || c.Logging.MaxAge < 0 {
+		return fmt.Errorf("log rotation limits must not be negative")
+	}
+	if c.Advanced.WorkerCount < 0 || c.Advanced.EventBufferSize < 0 {
+		return fmt.Errorf("advanced.workerCount and advanced.eventBufferSize must not be negative")
+	}
+	if c.Advanced.RequestTimeout < 0 || c.Advanced.PropertyCacheTTL < 0 {
+		return fmt.Errorf("advanced timeouts must not be negative")
+	}
+	return nil
+}
+
+// ConfigLoader loads the framework and SDK configurations from the same
+// defaults, files, environment variables and flags. The framework-only keys
+// (device.region, mqtt.autoReconnect, features.*, logging.*, advanced.*) are
+// bound next to the SDK keys of config.Loader; plugin settings are read
+// from the plugins.<name>.<key> entries of configuration files.
+type ConfigLoader struct {
+	*config.Loader
+	framework *Config
+}
+
+func NewConfigLoader() *ConfigLoader {
+	l := &ConfigLoader{Loader: config.NewLoader(), framework: new(Config)}
+	l.Bind(frameworkFields(l.framework)...)
+	l.BindPrefix("plugins", l.setPluginValue)
+	return l
+}
+
+func (l *ConfigLoader) setPluginValue(key, value string) error {
+	name, setting, ok := strings.Cut(key, ".")
+	if !ok || name == "" || setting == "" {
+		return fmt.Errorf("want plugins.<name>.<key>")
+	}
+	if l.framework.Plugins == nil {
+		l.framework.Plugins = make(map[string]map[string]interface{})
+	}
+	if l.framework.Plugins[name] == nil {
+		l.framework.Plugins[name] = make(map[string]interface{})
+	}
+	l.framework.Plugins[name][setting] = value
+	return nil
+}
+
+// Load returns the validated framework configuration and the SDK
+// configuration for plugins such as the MQTT plugin.
+func (l *ConfigLoader) Load() (Config, *config.Config, error) {
+	*l.framework = DefaultConfig()
+	sdk, err := l.Loader.Load()
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	c := *l.framework
+	c.applySDK(sdk)
+	if err := c.Validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return c, sdk, nil
+}
+
+// Reload loads the framework configuration again; pass it to WatchConfig
+// together with Files.
+func (l *ConfigLoader) Reload() (Config, error) {
+	c, _, err := l.Load()
+	return c, err
+}
+
+func frameworkFields(c *Config) []config.Field {
+	return []config.Field{
+		{Key: "device.region", Env: "IOT_DEVICE_REGION", Usage: "device region", Set: config.StringValue(&c.Device.Region)},
+
+		{Key: "mqtt.autoReconnect", Env: "IOT_MQTT_AUTO_RECONNECT", Usage: "reconnect automatically", Set: config.BoolValue(&c.MQTT.AutoReconnect)},
+		{Key: "mqtt.reconnectMax", Env: "IOT_MQTT_RECONNECT_MAX", Usage: "maximum reconnect attempts", Set: config.IntValue(&c.MQTT.ReconnectMax)},
+		{Key: "mqtt.timeout", Env: "IOT_MQTT_TIMEOUT", Usage: "MQTT operation timeout", Set: config.DurationValue(&c.MQTT.Timeout)},
+
+		{Key: "features.enableOTA", Env: "IOT_FEATURE_OTA", Usage: "enable OTA", Set: config.BoolValue(&c.Features.EnableOTA)},
+		{Key: "features.enableShadow", Env: "IOT_FEATURE_SHADOW", Usage: "enable device shadow", Set: config.BoolValue(&c.Features.EnableShadow)},
+		{Key: "features.enableRules", Env: "IOT_FEATURE_RULES", Usage: "enable rules", Set: config.BoolValue(&c.Features.EnableRules)},
+		{Key: "features.enableMetrics", Env: "IOT_FEATURE_METRICS", Usage: "enable metrics", Set: config.BoolValue(&c.Features.EnableMetrics)},
+
+		{Key: "logging.level", Env: "IOT_LOG_LEVEL", Usage: "debug, info, warn or error", Set: config.StringValue(&c.Logging.Level)},
+		{Key: "logging.format", Env: "IOT_LOG_FORMAT", Usage: "text or json", Set: config.StringValue(&c.Logging.Format)},
+		{Key: "logging.output", Env: "IOT_LOG_OUTPUT", Usage: "stdout, stderr or a file path", Set: config.StringValue(&c.Logging.Output)},
+		{Key: "logging.maxSize", Env: "IOT_LOG_MAX_SIZE", Usage: "log file size limit in MB", Set: config.IntValue(&c.Logging.MaxSize)},
+		{Key: "logging.maxBackups", Env: "IOT_LOG_MAX_BACKUPS", Usage: "rotated log files kept", Set: config.IntValue(&c.Logging.MaxBackups)},
+		{Key: "logging.maxAge", Env: "IOT_LOG_MAX_AGE", Usage: "days rotated log files are kept", Set: config.IntValue(&c.Logging.MaxAge)},
+		{Key: "logging.redactFields", Env: "IOT_LOG_REDACT_FIELDS", Usage: "extra payload fields masked in logs", Set: config.ListValue(&c.Logging.RedactFields)},
+		{Key: "logging.unsafeDebug", Env: "IOT_LOG_UNSAFE_DEBUG", Usage: "log secrets unredacted", Set: config.BoolValue(&c.Logging.UnsafeDebug)},
+
+		{Key: "advanced.workerCount", Env: "IOT_WORKER_COUNT", Usage: "event bus workers", Set: config.IntValue(&c.Advanced.WorkerCount)},
+		{Key: "advanced.eventBufferSize", Env: "IOT_EVENT_BUFFER_SIZE", Usage: "event buffer size", Set: config.IntValue(&c.Advanced.EventBufferSize)},
+		{Key: "advanced.requestTimeout", Env: "IOT_REQUEST_TIMEOUT", Usage: "request timeout", Set: config.DurationValue(&c.Advanced.RequestTimeout)},
+		{Key: "advanced.propertyCacheTime", Env: "IOT_PROPERTY_CACHE_TIME", Usage: "property cache TTL", Set: config.DurationValue(&c.Advanced.PropertyCacheTTL)},
+	}
+}
@@ -3,15 +3,18 @@ package core
 import (
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"reflect"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/iot-go-sdk/pkg/framework/event"
 	"github.com/iot-go-sdk/pkg/framework/plugin"
+	"github.com/iot-go-sdk/pkg/logging"
 )
 
 // Framework is the main IoT framework interface
@@ -46,15 +49,26 @@ type Framework interface {
 	// Service management
 	RegisterService(name string, handler func(params map[string]interface{}) (interface{}, error)) error
 
+	// Configuration
+	Config() Config
+	ApplyConfig(config Config, allowReconnect bool) (*ConfigChange, error)
+	WatchConfig(load func() (Config, error), opts WatchOptions) error
+
 	// Status
 	GetState() LifecycleState
 	GetConnectionState() ConnectionState
+
+	// Logger returns the framework's root logger; plugins derive their
+	// component loggers from it with Named
+	Logger() logging.Logger
 }
 
 // IoTFramework is the concrete implementation of the Framework interface
 type IoTFramework struct {
 	// Configuration
-	config Config
+	config      Config
+	configMutex sync.RWMutex
+	reloadMutex sync.Mutex
 
 	// Core components
 	eventBus     *event.Bus
@@ -80,7 +94,10 @@ type IoTFramework struct {
 	shutdownCh chan os.Signal
 
 	// Logging
-	logger *log.Logger
+	rootLogger logging.Logger
+	logger     logging.Logger
+	logLevel   *slog.LevelVar
+	logCloser  io.Closer
 }
 
 type propertyHandler struct {
@@ -93,7 +110,7 @@ type serviceHandler func(params map[string]interface{}) (interface{}, error)
 
 // New creates a new IoT framework instance
 func New(config Config) Framework {
-	return &IoTFramework{
+	f := &IoTFramework{
 		config:          config,
 		devices:         make(map[string]Device),
 		properties:      make(map[string]*propertyHandler),
@@ -101,8 +118,58 @@ func New(config Config) Framework {
 		state:           LifecycleUninitialized,
 		connectionState: StateDisconnected,
 		shutdownCh:      make(chan os.Signal, 1),
-		logger:          log.New(os.Stdout, "[Framework] ", log.LstdFlags),
 	}
+	f.configureLogging(config.Logging)
+	return f
+}
+
+// configureLogging builds the root logger from cfg. A zero LoggingConfig
+// keeps logging.Default so applications can install their own logger.
+func (f *IoTFramework) configureLogging(cfg LoggingConfig) {
+	root := logging.Default()
+	var closer io.Closer
+	var configErr error
+	var level *slog.LevelVar
+
+	if !reflect.DeepEqual(cfg, LoggingConfig{}) {
+		logging.SetRedaction(logging.RedactionConfig{
+			PayloadFields: cfg.RedactFields,
+			UnsafeDebug:   cfg.UnsafeDebug,
+		})
+		level = new(slog.LevelVar)
+		logger, c, err := logging.New(logging.Config{
+			Level:      cfg.Level,
+			Format:     cfg.Format,
+			Output:     cfg.Output,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+			LevelVar:   level,
+		})
+		if err != nil {
+			configErr = err
+			level = nil
+		} else {
+			root, closer = logger, c
+		}
+	}
+
+	if f.logCloser != nil {
+		f.logCloser.Close()
+	}
+	f.rootLogger = root
+	f.logger = root.Named("framework")
+	f.logCloser = closer
+	f.logLevel = level
+
+	if configErr != nil {
+		f.logger.Error("invalid logging config, using default logger", "error", configErr)
+	}
+}
+
+// Logger returns the framework's root logger
+func (f *IoTFramework) Logger() logging.Logger {
+	return f.rootLogger
 }
 
 // Initialize initializes the framework
@@ -115,10 +182,15 @@ func (f *IoTFramework) Initialize(config Config) error {
 	f.state = LifecycleInitializing
 	f.stateMutex.Unlock()
 
-	f.logger.Println("Initializing framework...")
-
 	// Update configuration
+	if !reflect.DeepEqual(config.Logging, f.config.Logging) {
+		f.configureLogging(config.Logging)
+	}
+	f.configMutex.Lock()
 	f.config = config
+	f.configMutex.Unlock()
+
+	f.logger.Info("initializing framework")
 
 	// Create context
 	f.ctx, f.cancel = context.WithCancel(context.Background())
@@ -129,11 +201,11 @@ func (f *IoTFramework) Initialize(config Config) error {
 		workerCount = 10
 	}
 	f.eventBus = event.NewBus(workerCount)
-	f.eventBus.SetLogger(log.New(os.Stdout, "[EventBus] ", log.LstdFlags))
+	f.eventBus.SetLogger(f.rootLogger.Named("eventbus"))
 
 	// Initialize plugin manager
 	f.pluginMgr = plugin.NewManager()
-	f.pluginMgr.SetLogger(log.New(os.Stdout, "[PluginMgr] ", log.LstdFlags))
+	f.pluginMgr.SetLogger(f.rootLogger.Named("plugins"))
 
 	// Register internal event handlers
 	f.registerInternalHandlers()
@@ -145,7 +217,7 @@ func (f *IoTFramework) Initialize(config Config) error {
 	f.state = LifecycleInitialized
 	f.stateMutex.Unlock()
 
-	f.logger.Println("Framework initialized successfully")
+	f.logger.Info("framework initialized")
 	return nil
 }
 
@@ -159,7 +231,7 @@ func (f *IoTFramework) Start() error {
 	f.state = LifecycleStarting
 	f.stateMutex.Unlock()
 
-	f.logger.Println("Starting framework...")
+	f.logger.Info("starting framework")
 
 	// Start event bus
 	if err := f.eventBus.Start(); err != nil {
@@ -186,7 +258,7 @@ func (f *IoTFramework) Start() error {
 
 	for _, device := range devices {
 		if err := device.OnInitialize(f.ctx); err != nil {
-			f.logger.Printf("Failed to initialize device %v: %v", device.GetDeviceInfo().DeviceName, err)
+			f.logger.Error("failed to initialize device", "device", device.GetDeviceInfo().DeviceName, "error", err)
 		}
 	}
 
@@ -197,7 +269,7 @@ func (f *IoTFramework) Start() error {
 	f.state = LifecycleStarted
 	f.stateMutex.Unlock()
 
-	f.logger.Println("Framework started successfully")
+	f.logger.Info("framework started")
 	return nil
 }
 
@@ -211,7 +283,7 @@ func (f *IoTFramework) Stop() error {
 	f.state = LifecycleStopping
 	f.stateMutex.Unlock()
 
-	f.logger.Println("Stopping framework...")
+	f.logger.Info("stopping framework")
 
 	// Destroy all devices
 	f.devicesMutex.RLock()
@@ -223,18 +295,18 @@ func (f *IoTFramework) Stop() error {
 
 	for _, device := range devices {
 		if err := device.OnDestroy(f.ctx); err != nil {
-			f.logger.Printf("Failed to destroy device %v: %v", device.GetDeviceInfo().DeviceName, err)
+			f.logger.Error("failed to destroy device", "device", device.GetDeviceInfo().DeviceName, "error", err)
 		}
 	}
 
 	// Stop all plugins
 	if err := f.pluginMgr.StopAll(); err != nil {
-		f.logger.Printf("Error stopping plugins: %v", err)
+		f.logger.Error("failed to stop plugins", "error", err)
 	}
 
 	// Stop event bus
 	if err := f.eventBus.Stop(); err != nil {
-		f.logger.Printf("Error stopping event bus: %v", err)
+		f.logger.Error("failed to stop event bus", "error", err)
 	}
 
 	// Cancel context
@@ -247,15 +319,19 @@ func (f *IoTFramework) Stop() error {
 	f.state = LifecycleStopped
 	f.stateMutex.Unlock()
 
-	f.logger.Println("Framework stopped")
+	f.logger.Info("framework stopped")
+	if f.logCloser != nil {
+		f.logCloser.Close()
+		f.logCloser = nil
+	}
 	return nil
 }
 
 // WaitForShutdown waits for shutdown signal
 func (f *IoTFramework) WaitForShutdown() {
-	f.logger.Println("Waiting for shutdown signal... (Press Ctrl+C to exit)")
+	f.logger.Info("waiting for shutdown signal (press Ctrl+C to exit)")
 	sig := <-f.shutdownCh
-	f.logger.Printf("Shutdown signal received: %v, initiating graceful shutdown...", sig)
+	f.logger.Info("shutdown signal received, shutting down gracefully", "signal", sig.String())
 
 	// Start shutdown in a separate goroutine with timeout
 	done := make(chan error, 1)
@@ -267,12 +343,12 @@ func (f *IoTFramework) WaitForShutdown() {
 	select {
 	case err := <-done:
 		if err != nil {
-			f.logger.Printf("Error during graceful shutdown: %v", err)
+			f.logger.Error("graceful shutdown failed", "error", err)
 		} else {
-			f.logger.Println("Graceful shutdown completed")
+			f.logger.Info("graceful shutdown completed")
 		}
 	case <-time.After(10 * time.Second):
-		f.logger.Println("Shutdown timeout reached, forcing exit")
+		f.logger.Error("shutdown timeout reached, forcing exit")
 		os.Exit(1)
 	}
 }
@@ -294,7 +370,7 @@ func (f *IoTFramework) RegisterDevice(device Device) error {
 	}
 
 	f.devices[deviceID] = device
-	f.logger.Printf("Registered device: %s", deviceID)
+	f.logger.Info("registered device", "device", deviceID)
 
 	// Emit device registered event
 	evt := event.NewEvent("device.registered", "framework", map[string]interface{}{
@@ -307,7 +383,7 @@ func (f *IoTFramework) RegisterDevice(device Device) error {
 	if f.GetState() == LifecycleStarted {
 		go func() {
 			if err := device.OnInitialize(f.ctx); err != nil {
-				f.logger.Printf("Failed to initialize device %s: %v", deviceID, err)
+				f.logger.Error("failed to initialize device", "device", deviceID, "error", err)
 			}
 		}()
 	}
@@ -327,11 +403,11 @@ func (f *IoTFramework) UnregisterDevice(deviceID string) error {
 
 	// Call destroy callback
 	if err := device.OnDestroy(f.ctx); err != nil {
-		f.logger.Printf("Error destroying device %s: %v", deviceID, err)
+		f.logger.Error("failed to destroy device", "device", deviceID, "error", err)
 	}
 
 	delete(f.devices, deviceID)
-	f.logger.Printf("Unregistered device: %s", deviceID)
+	f.logger.Info("unregistered device", "device", deviceID)
 
 	// Emit device unregistered event
 	evt := event.NewEvent("device.unregistered", "framework", map[string]interface{}{
@@ -396,7 +472,7 @@ func (f *IoTFramework) RegisterProperty(name string, getter func() interface{},
 		mode:   mode,
 	}
 
-	f.logger.Printf("Registered property: %s (mode: %s)", name, mode)
+	f.logger.Info("registered property", "property", name, "mode", mode)
 	return nil
 }
 
@@ -429,7 +505,7 @@ func (f *IoTFramework) RegisterService(name string, handler func(params map[stri
 	defer f.servicesMutex.Unlock()
 
 	f.services[name] = handler
-	f.logger.Printf("Registered service: %s", name)
+	f.logger.Info("registered service", "service", name)
 	return nil
 }
 
@@ -505,7 +581,7 @@ func (f *IoTFramework) registerInternalHandlers() {
 
 			if exists && handler.setter != nil {
 				if err := handler.setter(value); err != nil {
-					f.logger.Printf("Error setting property %s: %v", name, err)
+					f.logger.Error("failed to set property", "property", name, "error", err)
 				}
 			}
 
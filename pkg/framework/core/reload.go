@@ -0,0 +1,362 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/framework/event"
+	"github.com/iot-go-sdk/pkg/logging"
+)
+
+// ConfigChange describes an applied configuration reload. It is the data
+// of event.EventConfigChanged.
+type ConfigChange struct {
+	// Keys are the changed configuration keys, e.g. "logging.level" or
+	// "plugins.ota.check_interval"
+	Keys []string
+	// Plugins are the plugins whose Configure was called
+	Plugins []string
+	// Reconnected is set when the MQTT plugin accepted new connection
+	// settings, reconnecting when it was started
+	Reconnected bool
+	Previous    Config
+	Current     Config
+}
+
+// ReloadError reports changes a running framework cannot apply. Nothing
+// from the new configuration is applied when it is returned.
+type ReloadError struct {
+	// Restart lists keys that only take effect after a restart
+	Restart []string
+	// Reconnect lists keys that need an MQTT reconnect, which was not allowed
+	Reconnect []string
+}
+
+func (e *ReloadError) Error() string {
+	var reasons []string
+	if len(e.Restart) > 0 {
+		reasons = append(reasons, "restart required for "+strings.Join(e.Restart, ", "))
+	}
+	if len(e.Reconnect) > 0 {
+		reasons = append(reasons, "reconnect required for "+strings.Join(e.Reconnect, ", "))
+	}
+	return "config reload refused: " + strings.Join(reasons, "; ")
+}
+
+// WatchOptions configures WatchConfig.
+type WatchOptions struct {
+	// Files are polled for changes; SIGHUP always triggers a reload
+	Files []string
+	// PollInterval defaults to 2 seconds
+	PollInterval time.Duration
+	// AllowReconnect applies changes that need an MQTT reconnect
+	AllowReconnect bool
+}
+
+// reloadClass tells how a changed key is applied.
+type reloadClass int
+
+const (
+	reloadLive reloadClass = iota
+	reloadReconnect
+	reloadRestart
+)
+
+func classifyKey(key string) reloadClass {
+	switch key {
+	case "device.deviceSecret", "mqtt.host", "mqtt.port", "mqtt.useTLS", "mqtt.keepAlive", "mqtt.cleanSession":
+		return reloadReconnect
+	case "logging.format", "logging.output", "logging.maxSize", "logging.maxBackups", "logging.maxAge",
+		"advanced.workerCount", "advanced.eventBufferSize":
+		return reloadRestart
+	}
+	// The device identity is baked into topics and plugins are loaded at
+	// startup
+	if strings.HasPrefix(key, "device.") || strings.HasPrefix(key, "features.") {
+		return reloadRestart
+	}
+	return reloadLive
+}
+
+// Config returns the running configuration
+func (f *IoTFramework) Config() Config {
+	f.configMutex.RLock()
+	defer f.configMutex.RUnlock()
+	return f.config
+}
+
+// ApplyConfig applies next to the running framework. Log level, redaction,
+// timeouts and plugin settings change in place; plugins whose section of
+// Config.Plugins changed are reconfigured. Connection settings are applied
+// by reconnecting the MQTT plugin when allowReconnect is set. Any other
+// change, or a refused reconnect, fails with a *ReloadError and nothing is
+// applied. When a plugin fails to apply next, the plugins that did are
+// configured with the running settings again and the running
+// configuration is kept. A successful change emits
+// event.EventConfigChanged; it returns nil when next equals the running
+// configuration.
+func (f *IoTFramework) ApplyConfig(next Config, allowReconnect bool) (*ConfigChange, error) {
+	if f.GetState() == LifecycleUninitialized {
+		return nil, fmt.Errorf("framework must be initialized before reloading config")
+	}
+	if err := next.Validate(); err != nil {
+		return nil, f.reportReload(fmt.Errorf("invalid configuration: %w", err))
+	}
+
+	f.reloadMutex.Lock()
+	defer f.reloadMutex.Unlock()
+
+	previous := f.Config()
+	keys := diffConfig(previous, next)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	refused := &ReloadError{}
+	reconnect := false
+	for _, key := range keys {
+		switch classifyKey(key) {
+		case reloadRestart:
+			refused.Restart = append(refused.Restart, key)
+		case reloadReconnect:
+			reconnect = true
+			if !allowReconnect {
+				refused.Reconnect = append(refused.Reconnect, key)
+			}
+		}
+	}
+	if len(refused.Restart) > 0 || len(refused.Reconnect) > 0 {
+		return nil, f.reportReload(refused)
+	}
+
+	f.applyLogging(previous.Logging, next.Logging)
+
+	change := &ConfigChange{Keys: keys, Previous: previous, Current: next}
+	var errs []error
+	for name, settings := range pluginSettings(keys, next, reconnect) {
+		p, err := f.pluginMgr.Get(name)
+		if err != nil {
+			continue
+		}
+		if err := p.Configure(settings); err != nil {
+			errs = append(errs, fmt.Errorf("failed to configure plugin %s: %w", name, err))
+			continue
+		}
+		change.Plugins = append(change.Plugins, name)
+		change.Reconnected = change.Reconnected || (reconnect && name == "mqtt")
+	}
+	if err := errors.Join(errs...); err != nil {
+		f.rollbackConfig(keys, previous, next, reconnect, change.Plugins)
+		return nil, f.reportReload(err)
+	}
+	sort.Strings(change.Plugins)
+
+	f.configMutex.Lock()
+	f.config = next
+	f.configMutex.Unlock()
+
+	f.logger.Info("configuration reloaded", "keys", strings.Join(keys, ","), "plugins", strings.Join(change.Plugins, ","))
+	f.Emit(event.NewEvent(event.EventConfigChanged, "framework", *change))
+	return change, nil
+}
+
+// rollbackConfig restores the running logging settings and configures the
+// plugins that accepted next with their running settings again.
+func (f *IoTFramework) rollbackConfig(keys []string, previous, next Config, reconnect bool, plugins []string) {
+	f.applyLogging(next.Logging, previous.Logging)
+	settings := pluginSettings(keys, previous, reconnect)
+	for _, name := range plugins {
+		p, err := f.pluginMgr.Get(name)
+		if err != nil {
+			continue
+		}
+		if err := p.Configure(settings[name]); err != nil {
+			f.logger.Error("failed to restore plugin settings", "plugin", name, "error", err)
+		}
+	}
+}
+
+// reportReload logs a failed reload and emits it as event.EventError.
+func (f *IoTFramework) reportReload(err error) error {
+	f.logger.Error("config reload failed", "error", err)
+	f.Emit(event.NewEvent(event.EventError, "framework", err))
+	return err
+}
+
+// applyLogging applies the live logging settings.
+func (f *IoTFramework) applyLogging(previous, next LoggingConfig) {
+	if previous.Level != next.Level {
+		if f.logLevel == nil {
+			f.logger.Warn("log level not changed: the framework uses the application's logger")
+		} else {
+			level, _ := logging.ParseLevel(next.Level)
+			f.logLevel.Set(slog.Level(level))
+		}
+	}
+	if previous.UnsafeDebug != next.UnsafeDebug || !reflect.DeepEqual(previous.RedactFields, next.RedactFields) {
+		logging.SetRedaction(logging.RedactionConfig{
+			PayloadFields: next.RedactFields,
+			UnsafeDebug:   next.UnsafeDebug,
+		})
+	}
+}
+
+// pluginSettings returns the Configure argument of every affected plugin.
+// The MQTT plugin also receives the "device" and "mqtt" sections when it
+// has to reconnect.
+func pluginSettings(keys []string, next Config, reconnect bool) map[string]map[string]interface{} {
+	settings := make(map[string]map[string]interface{})
+	section := func(name string) map[string]interface{} {
+		if s, ok := settings[name]; ok {
+			return s
+		}
+		s := make(map[string]interface{}, len(next.Plugins[name]))
+		for k, v := range next.Plugins[name] {
+			s[k] = v
+		}
+		settings[name] = s
+		return s
+	}
+
+	for _, key := range keys {
+		if rest, ok := strings.CutPrefix(key, "plugins."); ok {
+			name, _, _ := strings.Cut(rest, ".")
+			section(name)
+		}
+	}
+	if reconnect {
+		s := section("mqtt")
+		s["device"] = next.Device
+		s["mqtt"] = next.MQTT
+	}
+	return settings
+}
+
+// WatchConfig reloads the configuration with load whenever one of
+// opts.Files changes or the process receives SIGHUP, and applies it with
+// ApplyConfig until the framework stops. Failed loads and refused changes
+// are logged and emitted as event.EventError.
+func (f *IoTFramework) WatchConfig(load func() (Config, error), opts WatchOptions) error {
+	if f.GetState() == LifecycleUninitialized {
+		return fmt.Errorf("framework must be initialized before watching config")
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	stamps := statFiles(opts.Files)
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer signal.Stop(hup)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-f.ctx.Done():
+				return
+			case <-hup:
+				f.logger.Info("SIGHUP received, reloading configuration")
+			case <-ticker.C:
+				if reflect.DeepEqual(statFiles(opts.Files), stamps) {
+					continue
+				}
+				f.logger.Info("configuration file changed, reloading")
+			}
+			stamps = statFiles(opts.Files)
+
+			next, err := load()
+			if err != nil {
+				f.reportReload(fmt.Errorf("failed to load configuration: %w", err))
+				continue
+			}
+			f.ApplyConfig(next, opts.AllowReconnect)
+		}
+	}()
+	return nil
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFiles records the modification time and size of files; missing
+// files get a zero stamp.
+func statFiles(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{info.ModTime(), info.Size()}
+		} else {
+			stamps[path] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+// diffConfig returns the sorted keys, named after the JSON tags, whose
+// values differ between a and b.
+func diffConfig(a, b Config) []string {
+	var keys []string
+	diffValue("", reflect.ValueOf(a), reflect.ValueOf(b), &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+func diffValue(key string, a, b reflect.Value, keys *[]string) {
+	switch a.Kind() {
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("json"), ",")
+			diffValue(joinKey(key, name), a.Field(i), b.Field(i), keys)
+		}
+		return
+	case reflect.Map:
+		names := make(map[string]bool)
+		for _, m := range []reflect.Value{a, b} {
+			for _, k := range m.MapKeys() {
+				names[k.String()] = true
+			}
+		}
+		zero := reflect.Zero(a.Type().Elem())
+		for name := range names {
+			av, bv := a.MapIndex(reflect.ValueOf(name)), b.MapIndex(reflect.ValueOf(name))
+			if !av.IsValid() {
+				av = zero
+			}
+			if !bv.IsValid() {
+				bv = zero
+			}
+			diffValue(joinKey(key, name), av, bv, keys)
+		}
+		return
+	case reflect.Slice:
+		if a.Len() == 0 && b.Len() == 0 {
+			return
+		}
+	}
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		*keys = append(*keys, key)
+	}
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
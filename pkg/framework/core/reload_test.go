@@ -0,0 +1,214 @@
+package core
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/framework/event"
+	"github.com/iot-go-sdk/pkg/framework/plugin"
+)
+
+// configurePlugin records the settings passed to Configure and fails
+// with err.
+type configurePlugin struct {
+	*plugin.BasePlugin
+	settings []map[string]interface{}
+	err      error
+}
+
+func (p *configurePlugin) Configure(settings map[string]interface{}) error {
+	p.settings = append(p.settings, settings)
+	return p.err
+}
+
+func newReloadFramework(t *testing.T) (*IoTFramework, Config) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Logging.Output = "stderr"
+	cfg.Plugins = map[string]map[string]interface{}{"ota": {"check_interval": "5m"}}
+
+	f := New(cfg).(*IoTFramework)
+	if err := f.Initialize(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return f, cfg
+}
+
+func TestApplyConfigLiveChanges(t *testing.T) {
+	f, cfg := newReloadFramework(t)
+	ota := &configurePlugin{BasePlugin: plugin.NewBasePlugin("ota", "1.0.0", "")}
+	if err := f.LoadPlugin(ota); err != nil {
+		t.Fatal(err)
+	}
+	var changes []ConfigChange
+	f.On(event.EventConfigChanged, func(evt *event.Event) error {
+		changes = append(changes, evt.Data.(ConfigChange))
+		return nil
+	})
+
+	next := cfg
+	next.Logging.Level = "debug"
+	next.Plugins = map[string]map[string]interface{}{"ota": {"check_interval": "1m"}}
+	change, err := f.ApplyConfig(next, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"logging.level", "plugins.ota.check_interval"}
+	if !reflect.DeepEqual(change.Keys, want) {
+		t.Fatalf("keys = %v, want %v", change.Keys, want)
+	}
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0].Plugins, []string{"ota"}) {
+		t.Fatalf("config.changed events = %+v", changes)
+	}
+	if len(ota.settings) != 1 || ota.settings[0]["check_interval"] != "1m" {
+		t.Fatalf("ota configured with %v", ota.settings)
+	}
+	if f.logLevel.Level() != slog.LevelDebug {
+		t.Fatalf("log level = %v", f.logLevel.Level())
+	}
+	if f.Config().Logging.Level != "debug" {
+		t.Fatal("running config not updated")
+	}
+
+	if change, err := f.ApplyConfig(next, false); change != nil || err != nil {
+		t.Fatalf("unchanged config: change = %v, err = %v", change, err)
+	}
+}
+
+func TestApplyConfigRefusesUnsafeChanges(t *testing.T) {
+	f, cfg := newReloadFramework(t)
+	mqtt := &configurePlugin{BasePlugin: plugin.NewBasePlugin("mqtt", "1.0.0", "")}
+	if err := f.LoadPlugin(mqtt); err != nil {
+		t.Fatal(err)
+	}
+
+	next := cfg
+	next.MQTT.Host = "other.example.com"
+	next.Advanced.WorkerCount = 20
+	_, err := f.ApplyConfig(next, true)
+	var refused *ReloadError
+	if !errors.As(err, &refused) || !reflect.DeepEqual(refused.Restart, []string{"advanced.workerCount"}) {
+		t.Fatalf("err = %v, want restart refusal", err)
+	}
+
+	next = cfg
+	next.MQTT.Host = "other.example.com"
+	if _, err := f.ApplyConfig(next, false); !errors.As(err, &refused) || !reflect.DeepEqual(refused.Reconnect, []string{"mqtt.host"}) {
+		t.Fatalf("err = %v, want reconnect refusal", err)
+	}
+	if f.Config().MQTT.Host != cfg.MQTT.Host {
+		t.Fatal("refused change was applied")
+	}
+
+	change, err := f.ApplyConfig(next, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !change.Reconnected || f.Config().MQTT.Host != "other.example.com" {
+		t.Fatalf("allowed reconnect change not applied: %+v", change)
+	}
+	if len(mqtt.settings) != 1 || mqtt.settings[0]["mqtt"].(MQTTConfig).Host != "other.example.com" {
+		t.Fatalf("mqtt configured with %v", mqtt.settings)
+	}
+}
+
+func TestApplyConfigRollsBackFailedChanges(t *testing.T) {
+	f, cfg := newReloadFramework(t)
+	mqtt := &configurePlugin{BasePlugin: plugin.NewBasePlugin("mqtt", "1.0.0", ""), err: errors.New("broker unreachable")}
+	ota := &configurePlugin{BasePlugin: plugin.NewBasePlugin("ota", "1.0.0", "")}
+	for _, p := range []*configurePlugin{mqtt, ota} {
+		if err := f.LoadPlugin(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changed := false
+	f.On(event.EventConfigChanged, func(evt *event.Event) error {
+		changed = true
+		return nil
+	})
+
+	next := cfg
+	next.Logging.Level = "debug"
+	next.MQTT.Host = "other.example.com"
+	next.Plugins = map[string]map[string]interface{}{"ota": {"check_interval": "1m"}}
+	if change, err := f.ApplyConfig(next, true); change != nil || err == nil {
+		t.Fatalf("change = %+v, err = %v", change, err)
+	}
+
+	if changed || !reflect.DeepEqual(f.Config(), cfg) {
+		t.Fatalf("failed change applied: running config = %+v", f.Config())
+	}
+	if f.logLevel.Level() != slog.LevelInfo {
+		t.Fatalf("log level = %v", f.logLevel.Level())
+	}
+	if len(ota.settings) != 2 || ota.settings[1]["check_interval"] != "5m" {
+		t.Fatalf("ota configured with %v", ota.settings)
+	}
+}
+
+func TestWatchConfigReloadsOnFileChangeAndSIGHUP(t *testing.T) {
+	f, cfg := newReloadFramework(t)
+	t.Cleanup(func() {
+		f.cancel()
+		f.wg.Wait()
+	})
+	changes := make(chan ConfigChange, 2)
+	f.On(event.EventConfigChanged, func(evt *event.Event) error {
+		changes <- evt.Data.(ConfigChange)
+		return nil
+	})
+
+	// The watched file holds the log level unless override is set
+	path := filepath.Join(t.TempDir(), "level")
+	if err := os.WriteFile(path, []byte("info"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var override atomic.Value
+	override.Store("")
+	load := func() (Config, error) {
+		next := cfg
+		data, err := os.ReadFile(path)
+		next.Logging.Level = string(data)
+		if level := override.Load().(string); level != "" {
+			next.Logging.Level = level
+		}
+		return next, err
+	}
+	if err := f.WatchConfig(load, WatchOptions{Files: []string{path}, PollInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	wait := func(want string) {
+		t.Helper()
+		select {
+		case change := <-changes:
+			if change.Current.Logging.Level != want {
+				t.Fatalf("reloaded level %s, want %s", change.Current.Logging.Level, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no reload to level %s", want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("debug"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wait("debug")
+
+	// SIGHUP reloads although no watched file changed
+	override.Store("warn")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	wait("warn")
+	if f.Config().Logging.Level != "warn" {
+		t.Fatalf("running level = %s", f.Config().Logging.Level)
+	}
+}
@@ -106,6 +106,9 @@ type Config struct {
 	Features FeatureConfig  `json:"features"`
 	Logging  LoggingConfig  `json:"logging"`
 	Advanced AdvancedConfig `json:"advanced"`
+	// Plugins holds per-plugin settings passed to plugin.Plugin.Configure,
+	// keyed by plugin name
+	Plugins map[string]map[string]interface{} `json:"plugins,omitempty"`
 }
 
 // DeviceConfig contains device configuration
@@ -145,6 +148,11 @@ type LoggingConfig struct {
 	MaxSize    int    `json:"maxSize"`
 	MaxBackups int    `json:"maxBackups"`
 	MaxAge     int    `json:"maxAge"`
+	// RedactFields are payload JSON fields masked in logs in addition to
+	// the built-in secret fields
+	RedactFields []string `json:"redactFields,omitempty"`
+	// UnsafeDebug logs secrets and payloads unredacted. Never enable in production
+	UnsafeDebug bool `json:"unsafeDebug,omitempty"`
 }
 
 // AdvancedConfig contains advanced configuration
@@ -3,10 +3,11 @@ package event
 import (
 	"context"
 	"fmt"
-	"log"
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/iot-go-sdk/pkg/logging"
 )
 
 // Bus implements an event bus for publishing and subscribing to events
@@ -15,7 +16,7 @@ type Bus struct {
 	mutex       sync.RWMutex
 	workerPool  chan func()
 	workerCount int
-	logger      *log.Logger
+	logger      logging.Logger
 	ctx         context.Context
 	cancel      context.CancelFunc
 	wg          sync.WaitGroup
@@ -28,15 +29,15 @@ func NewBus(workerCount int) *Bus {
 		subscribers: make(map[EventType][]*HandlerInfo),
 		workerPool:  make(chan func(), workerCount*10),
 		workerCount: workerCount,
-		logger:      log.Default(),
+		logger:      logging.Default().Named("eventbus"),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 }
 
 // SetLogger sets the logger for the event bus
-func (b *Bus) SetLogger(logger *log.Logger) {
-	b.logger = logger
+func (b *Bus) SetLogger(logger logging.Logger) {
+	b.logger = logging.Redacting(logger)
 }
 
 // Subscribe adds a handler for a specific event type
@@ -71,7 +72,7 @@ func (b *Bus) SubscribeWithPriority(eventType EventType, handler Handler, priori
 		return b.subscribers[eventType][i].Priority > b.subscribers[eventType][j].Priority
 	})
 
-	b.logger.Printf("Subscribed handler to event type: %s (priority: %d, async: %v)", eventType, priority, async)
+	b.logger.Debug("subscribed handler", "eventType", eventType, "priority", priority, "async", async)
 	return nil
 }
 
@@ -90,7 +91,7 @@ func (b *Bus) Unsubscribe(eventType EventType, handler Handler) error {
 		// Compare function pointers
 		if fmt.Sprintf("%p", h.Handler) == fmt.Sprintf("%p", handler) {
 			b.subscribers[eventType] = append(handlers[:i], handlers[i+1:]...)
-			b.logger.Printf("Unsubscribed handler from event type: %s", eventType)
+			b.logger.Debug("unsubscribed handler", "eventType", eventType)
 			return nil
 		}
 	}
@@ -109,7 +110,7 @@ func (b *Bus) Publish(event *Event) error {
 	b.mutex.RUnlock()
 
 	if !exists || len(handlers) == 0 {
-		b.logger.Printf("No subscribers for event type: %s", event.Type)
+		b.logger.Debug("no subscribers for event", "eventType", event.Type)
 		return nil
 	}
 
@@ -117,7 +118,7 @@ func (b *Bus) Publish(event *Event) error {
 	handlersCopy := make([]*HandlerInfo, len(handlers))
 	copy(handlersCopy, handlers)
 
-	b.logger.Printf("Publishing event: %s to %d subscribers", event.Type, len(handlersCopy))
+	b.logger.Debug("publishing event", "eventType", event.Type, "subscribers", len(handlersCopy))
 
 	var wg sync.WaitGroup
 	errors := make([]error, 0)
@@ -157,7 +158,7 @@ func (b *Bus) Publish(event *Event) error {
 func (b *Bus) PublishAsync(event *Event) {
 	go func() {
 		if err := b.Publish(event); err != nil {
-			b.logger.Printf("Error publishing event asynchronously: %v", err)
+			b.logger.Error("failed to publish event asynchronously", "eventType", event.Type, "error", err)
 		}
 	}()
 }
@@ -167,7 +168,7 @@ func (b *Bus) executeHandler(handler Handler, event *Event) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("handler panic: %v", r)
-			b.logger.Printf("Handler panic for event %s: %v", event.Type, r)
+			b.logger.Error("handler panic", "eventType", event.Type, "panic", r)
 		}
 	}()
 
@@ -181,14 +182,14 @@ func (b *Bus) submitWork(work func()) {
 		// Work submitted successfully
 	case <-time.After(5 * time.Second):
 		// Timeout - execute directly
-		b.logger.Println("Worker pool full, executing work directly")
+		b.logger.Warn("worker pool full, executing work directly")
 		go work()
 	}
 }
 
 // Start starts the event bus workers
 func (b *Bus) Start() error {
-	b.logger.Printf("Starting event bus with %d workers", b.workerCount)
+	b.logger.Info("starting event bus", "workers", b.workerCount)
 
 	// Start worker goroutines
 	for i := 0; i < b.workerCount; i++ {
@@ -201,7 +202,7 @@ func (b *Bus) Start() error {
 
 // Stop stops the event bus
 func (b *Bus) Stop() error {
-	b.logger.Println("Stopping event bus...")
+	b.logger.Info("stopping event bus")
 	
 	// Signal cancellation
 	b.cancel()
@@ -217,25 +218,25 @@ func (b *Bus) Stop() error {
 	b.subscribers = make(map[EventType][]*HandlerInfo)
 	b.mutex.Unlock()
 	
-	b.logger.Println("Event bus stopped")
+	b.logger.Info("event bus stopped")
 	return nil
 }
 
 // worker processes work from the worker pool
 func (b *Bus) worker(id int) {
 	defer b.wg.Done()
-	b.logger.Printf("Worker %d started", id)
+	b.logger.Debug("worker started", "worker", id)
 
 	for {
 		select {
 		case work, ok := <-b.workerPool:
 			if !ok {
-				b.logger.Printf("Worker %d stopping", id)
+				b.logger.Debug("worker stopping", "worker", id)
 				return
 			}
 			work()
 		case <-b.ctx.Done():
-			b.logger.Printf("Worker %d stopped by context", id)
+			b.logger.Debug("worker stopped by context", "worker", id)
 			return
 		}
 	}
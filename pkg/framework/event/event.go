@@ -15,6 +15,18 @@ const (
 	EventDisconnected EventType = "system.disconnected"
 	EventError        EventType = "system.error"
 	EventReady        EventType = "system.ready"
+	// EventResubscribed is emitted after subscriptions were restored on reconnect
+	EventResubscribed EventType = "system.resubscribed"
+	// EventClockSkew is emitted when time sync finds the local clock off by
+	// more than the threshold; its data is a timesync.Skew
+	EventClockSkew EventType = "system.clock_skew"
+	// EventOfflineQueue reports the offline publish backlog when draining
+	// starts after a reconnect and when it stops; its data is a
+	// mqtt.QueueStats
+	EventOfflineQueue EventType = "system.offline_queue"
+	// EventConfigChanged is emitted after a live configuration reload; its
+	// data is a core.ConfigChange
+	EventConfigChanged EventType = "config.changed"
 )
 
 // Property events
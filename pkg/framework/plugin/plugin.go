@@ -3,8 +3,9 @@ package plugin
 import (
 	"context"
 	"fmt"
-	"log"
 	"sync"
+
+	"github.com/iot-go-sdk/pkg/logging"
 )
 
 // Plugin interface defines the contract for framework plugins
@@ -96,7 +97,7 @@ type Manager struct {
 	plugins      map[string]Plugin
 	pluginsMutex sync.RWMutex
 	started      map[string]bool
-	logger       *log.Logger
+	logger       logging.Logger
 }
 
 // NewManager creates a new plugin manager
@@ -104,13 +105,13 @@ func NewManager() *Manager {
 	return &Manager{
 		plugins: make(map[string]Plugin),
 		started: make(map[string]bool),
-		logger:  log.Default(),
+		logger:  logging.Default().Named("plugins"),
 	}
 }
 
 // SetLogger sets the logger for the plugin manager
-func (m *Manager) SetLogger(logger *log.Logger) {
-	m.logger = logger
+func (m *Manager) SetLogger(logger logging.Logger) {
+	m.logger = logging.Redacting(logger)
 }
 
 // Register registers a plugin
@@ -141,7 +142,7 @@ func (m *Manager) Register(plugin Plugin) error {
 	m.plugins[name] = plugin
 	m.started[name] = false
 	
-	m.logger.Printf("Registered plugin: %s v%s", name, plugin.Version())
+	m.logger.Info("registered plugin", "plugin", name, "version", plugin.Version())
 	return nil
 }
 
@@ -158,7 +159,7 @@ func (m *Manager) Unregister(name string) error {
 	// Check if plugin is running
 	if m.started[name] {
 		if err := plugin.Stop(); err != nil {
-			m.logger.Printf("Error stopping plugin %s: %v", name, err)
+			m.logger.Error("failed to stop plugin", "plugin", name, "error", err)
 		}
 	}
 	
@@ -177,7 +178,7 @@ func (m *Manager) Unregister(name string) error {
 	delete(m.plugins, name)
 	delete(m.started, name)
 	
-	m.logger.Printf("Unregistered plugin: %s", name)
+	m.logger.Info("unregistered plugin", "plugin", name)
 	return nil
 }
 
@@ -233,7 +234,7 @@ func (m *Manager) InitAll(ctx context.Context, framework interface{}) error {
 			}
 			
 			if canInit {
-				m.logger.Printf("Initializing plugin: %s", name)
+				m.logger.Info("initializing plugin", "plugin", name)
 				if err := plugin.Init(ctx, framework); err != nil {
 					return fmt.Errorf("failed to initialize plugin %s: %w", name, err)
 				}
@@ -276,7 +277,7 @@ func (m *Manager) StartAll() error {
 			}
 			
 			if canStart {
-				m.logger.Printf("Starting plugin: %s", name)
+				m.logger.Info("starting plugin", "plugin", name)
 				if err := plugin.Start(); err != nil {
 					return fmt.Errorf("failed to start plugin %s: %w", name, err)
 				}
@@ -329,7 +330,7 @@ func (m *Manager) StopAll() error {
 			}
 			
 			if canStop {
-				m.logger.Printf("Stopping plugin: %s", name)
+				m.logger.Info("stopping plugin", "plugin", name)
 				if err := plugin.Stop(); err != nil {
 					errors = append(errors, fmt.Errorf("failed to stop plugin %s: %w", name, err))
 				}
@@ -343,7 +344,7 @@ func (m *Manager) StopAll() error {
 			// Force stop remaining plugins
 			for name, plugin := range m.plugins {
 				if !stopped[name] && m.started[name] {
-					m.logger.Printf("Force stopping plugin: %s", name)
+					m.logger.Warn("force stopping plugin", "plugin", name)
 					if err := plugin.Stop(); err != nil {
 						errors = append(errors, fmt.Errorf("failed to stop plugin %s: %w", name, err))
 					}
@@ -4,28 +4,45 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/iot-go-sdk/pkg/clock"
 	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/dynreg"
 	"github.com/iot-go-sdk/pkg/framework/core"
 	"github.com/iot-go-sdk/pkg/framework/event"
 	"github.com/iot-go-sdk/pkg/framework/plugin"
+	"github.com/iot-go-sdk/pkg/logging"
 	"github.com/iot-go-sdk/pkg/mqtt"
 	"github.com/iot-go-sdk/pkg/rrpc"
+	"github.com/iot-go-sdk/pkg/timesync"
+	tlsutil "github.com/iot-go-sdk/pkg/tls"
 )
 
+// publishTimeout bounds publishes made from event handlers so a stalled
+// broker cannot block the event bus
+const publishTimeout = 10 * time.Second
+
 // MQTTPlugin provides MQTT connectivity for the framework
 type MQTTPlugin struct {
 	plugin.BasePlugin
 
 	client     *mqtt.Client
 	rrpcClient *rrpc.RRPCClient
+	timeSync   *timesync.Service
+	provision  *dynreg.ProvisionOptions
 	config     *config.Config
 	framework  core.Framework
-	logger     *log.Logger
+	logger     logging.Logger
+	loggerSet  bool
+	// running is set between a successful Start and Stop
+	running bool
+	// clock is corrected to server time when time sync is enabled
+	clock *clock.Corrected
 
 	// Topic mappings
 	propertySetTopic         string
@@ -47,16 +64,20 @@ func NewMQTTPlugin(cfg *config.Config) *MQTTPlugin {
 			"MQTT connectivity plugin for IoT framework",
 		),
 		config: cfg,
-		logger: log.Default(),
+		logger: logging.Default().Named("mqtt"),
 	}
 }
 
 // Init initializes the plugin
 func (p *MQTTPlugin) Init(ctx context.Context, framework interface{}) error {
 	p.framework = framework.(core.Framework)
+	if !p.loggerSet {
+		p.logger = p.framework.Logger().Named("mqtt")
+	}
 
 	// Create MQTT client with the existing SDK implementation
 	p.client = mqtt.NewClient(p.config)
+	p.client.SetLogger(p.logger.Named("client"))
 
 	// Setup topic names using Thing Model topics with $ prefix
 	pk := p.config.Device.ProductKey
@@ -79,37 +100,71 @@ func (p *MQTTPlugin) Init(ctx context.Context, framework interface{}) error {
 	// Register event handlers
 	p.registerEventHandlers()
 
-	p.logger.Printf("[MQTT Plugin] Initialized for device %s.%s", pk, dn)
+	p.logger.Info("initialized", "productKey", pk, "deviceName", dn)
 	return nil
 }
 
 // Start starts the plugin
 func (p *MQTTPlugin) Start() error {
-	p.logger.Println("[MQTT Plugin] Starting...")
+	p.logger.Info("starting")
+
+	p.client.SetResubscribeHandler(p.handleResubscribed)
+	p.client.SetQueueHandler(p.handleQueue)
+
+	if p.provision != nil {
+		if err := p.provisionIdentity(); err != nil {
+			return err
+		}
+	}
+
+	if p.config.TimeSync.Enabled && p.clock == nil {
+		p.clock = clock.NewCorrected()
+		p.client.SetClock(p.clock)
+	}
+	if p.clock != nil {
+		// The first plugin to sync also corrects the process wide clock
+		clock.InstallDefault(p.clock)
+	}
 
 	// Connect to MQTT broker
 	if err := p.client.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+		// A wrong clock makes the platform refuse the signed timestamp
+		// before time sync over MQTT can run
+		if !p.config.TimeSync.Enabled || !p.recoverClock() {
+			return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+		}
+		if err := p.client.Connect(); err != nil {
+			return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+		}
 	}
 
-	p.logger.Printf("[MQTT Plugin] Connected to MQTT broker at %s:%d", p.config.MQTT.Host, p.config.MQTT.Port)
+	p.logger.Info("connected to MQTT broker", "host", p.config.MQTT.Host, "port", p.config.MQTT.Port)
+	p.running = true
+
+	if p.config.TimeSync.Enabled {
+		p.startTimeSync()
+	}
 
 	// Initialize and start RRPC client
 	p.rrpcClient = rrpc.NewRRPCClient(p.client, p.config.Device.ProductKey, p.config.Device.DeviceName)
-	p.rrpcClient.SetLogger(p.logger)
+	p.rrpcClient.SetLogger(p.logger.Named("rrpc"))
+	p.rrpcClient.SetResponseFormat(rrpc.ResponseFormat(p.config.RRPC.ResponseFormat))
+	p.rrpcClient.SetWorkers(p.config.RRPC.Workers, p.config.RRPC.QueueSize)
+	p.rrpcClient.SetTimeout(p.config.RRPC.Timeout)
 
 	// Register RRPC handlers from framework
 	p.registerRRPCHandlers()
 
 	if err := p.rrpcClient.Start(); err != nil {
-		p.logger.Printf("[MQTT Plugin] Warning: Failed to start RRPC client: %v", err)
+		p.logger.Warn("failed to start RRPC client", "error", err)
 		// Continue without RRPC support
 	} else {
-		p.logger.Println("[MQTT Plugin] RRPC client started successfully")
+		p.logger.Info("RRPC client started")
 	}
 
 	// Subscribe to topics
 	if err := p.subscribeToTopics(); err != nil {
+		p.running = false
 		p.client.Disconnect()
 		return fmt.Errorf("failed to subscribe to topics: %w", err)
 	}
@@ -122,26 +177,135 @@ func (p *MQTTPlugin) Start() error {
 
 // Stop stops the plugin
 func (p *MQTTPlugin) Stop() error {
-	p.logger.Println("[MQTT Plugin] Stopping...")
+	p.logger.Info("stopping")
 
 	// Stop RRPC client
 	if p.rrpcClient != nil {
 		p.rrpcClient.Stop()
-		p.logger.Println("[MQTT Plugin] RRPC client stopped")
+		p.logger.Info("RRPC client stopped")
+	}
+
+	if p.timeSync != nil {
+		p.timeSync.Stop()
+		p.timeSync = nil
+	}
+	if p.clock != nil {
+		clock.RemoveDefault(p.clock)
 	}
 
 	// Emit disconnected event
 	p.framework.Emit(event.NewEvent(event.EventDisconnected, "mqtt", nil))
 
 	// Disconnect from MQTT broker
+	p.running = false
 	if p.client != nil {
 		p.client.Disconnect()
 	}
 
-	p.logger.Println("[MQTT Plugin] Stopped")
+	p.logger.Info("stopped")
+	return nil
+}
+
+// EnableProvisioning makes Start obtain the device identity with
+// dynreg.Provision when the config has no device secret: from the
+// credential store or, on first start, by dynamic registration.
+func (p *MQTTPlugin) EnableProvisioning(opts dynreg.ProvisionOptions) {
+	p.provision = &opts
+}
+
+// provisionIdentity fills the device identity into the shared config
+func (p *MQTTPlugin) provisionIdentity() error {
+	opts := *p.provision
+	if opts.Logger == nil {
+		opts.Logger = p.logger.Named("dynreg")
+	}
+
+	provisioned, err := dynreg.Provision(context.Background(), p.config, opts)
+	if provisioned == nil {
+		return fmt.Errorf("device provisioning failed: %w", err)
+	}
+	if err != nil {
+		// Registered, but the identity could not be saved
+		p.logger.Warn("device identity not persisted", "error", err)
+	}
+	*p.config = *provisioned
 	return nil
 }
 
+// recoverClock measures the clock offset from the Date header of the
+// registration endpoint after a failed connect. When the skew exceeds the
+// threshold it corrects the plugin clock, reports the skew and returns
+// true so the connect is retried.
+func (p *MQTTPlugin) recoverClock() bool {
+	endpoint := p.config.DynReg.URL(p.config.MQTT.Host)
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" {
+		p.logger.Warn("cannot check clock: registration endpoint is not HTTPS", "url", endpoint)
+		return false
+	}
+	// Only the server is verified; no device certificate is needed. Its
+	// validity period cannot be checked with the clock in doubt.
+	serverTLS := p.config.TLS
+	serverTLS.ClientCert, serverTLS.ClientKey, serverTLS.ClientPKCS12 = "", "", ""
+	tlsConfig, err := tlsutil.NewConfigWithOptions(&serverTLS, u.Hostname(), tlsutil.ConfigOptions{IgnoreValidity: true})
+	if err != nil {
+		p.logger.Warn("failed to check clock over HTTPS", "error", err)
+		return false
+	}
+	httpClient := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	skew, err := timesync.MeasureHTTP(ctx, httpClient, endpoint)
+	if err != nil {
+		p.logger.Warn("failed to check clock over HTTPS", "error", err)
+		return false
+	}
+	skew.Threshold = p.config.TimeSync.SkewThreshold
+	if skew.Threshold <= 0 {
+		skew.Threshold = timesync.DefaultSkewThreshold
+	}
+	if !skew.Exceeded() {
+		return false
+	}
+
+	p.clock.SetOffset(skew.Offset)
+	p.logger.Warn("local clock is off, retrying connect with server time", "offset", skew.Offset)
+	p.framework.Emit(event.NewEvent(event.EventClockSkew, "mqtt", skew))
+	return true
+}
+
+// startTimeSync corrects the plugin clock with server time and reports
+// excessive skew as EventClockSkew
+func (p *MQTTPlugin) startTimeSync() {
+	p.timeSync = timesync.NewService(p.client, p.config.Device.ProductKey, p.config.Device.DeviceName)
+	p.timeSync.SetClock(p.clock)
+	p.timeSync.SetLogger(p.logger.Named("timesync"))
+	p.timeSync.SetInterval(p.config.TimeSync.Interval)
+	p.timeSync.SetSkewThreshold(p.config.TimeSync.SkewThreshold)
+	p.timeSync.OnSkew(func(skew timesync.Skew) {
+		p.framework.Emit(event.NewEvent(event.EventClockSkew, "mqtt", skew))
+	})
+
+	if err := p.timeSync.Start(); err != nil {
+		p.logger.Warn("failed to start time sync", "error", err)
+		p.timeSync = nil
+		return
+	}
+	p.logger.Info("time sync started", "interval", p.config.TimeSync.Interval)
+}
+
+// now returns the time reported data is stamped with
+func (p *MQTTPlugin) now() time.Time {
+	if p.clock != nil {
+		return p.clock.Now()
+	}
+	return clock.Now()
+}
+
 // registerEventHandlers registers handlers for framework events
 func (p *MQTTPlugin) registerEventHandlers() {
 	// Handle property report events
@@ -193,36 +357,63 @@ func (p *MQTTPlugin) subscribeToTopics() error {
 
 	// Try subscribing to property set topic
 	if err := p.client.Subscribe(p.propertySetTopic, 0, p.handlePropertySet); err != nil {
-		p.logger.Printf("[MQTT Plugin] Warning: Could not subscribe to %s: %v", p.propertySetTopic, err)
+		p.logger.Warn("could not subscribe", "topic", p.propertySetTopic, "error", err)
 		// Try alternative format without $
 		altTopic := fmt.Sprintf("/sys/%s/%s/thing/service/property/set", p.config.Device.ProductKey, p.config.Device.DeviceName)
 		if err := p.client.Subscribe(altTopic, 0, p.handlePropertySet); err != nil {
-			p.logger.Printf("[MQTT Plugin] Warning: Could not subscribe to alternative topic: %v", err)
+			p.logger.Warn("could not subscribe to alternative topic", "topic", altTopic, "error", err)
 		} else {
-			p.logger.Printf("[MQTT Plugin] Subscribed to alternative topic: %s", altTopic)
+			p.logger.Info("subscribed to alternative topic", "topic", altTopic)
 		}
 	}
 
 	// Try subscribing to service call topics
 	if err := p.client.Subscribe(p.serviceCallTopic, 0, p.handleServiceCall); err != nil {
-		p.logger.Printf("[MQTT Plugin] Warning: Could not subscribe to %s: %v", p.serviceCallTopic, err)
+		p.logger.Warn("could not subscribe", "topic", p.serviceCallTopic, "error", err)
 		// Try alternative format
 		altTopic := fmt.Sprintf("/sys/%s/%s/thing/service/+", p.config.Device.ProductKey, p.config.Device.DeviceName)
 		if err := p.client.Subscribe(altTopic, 0, p.handleServiceCall); err != nil {
-			p.logger.Printf("[MQTT Plugin] Warning: Could not subscribe to alternative service topic: %v", err)
+			p.logger.Warn("could not subscribe to alternative service topic", "topic", altTopic, "error", err)
 		} else {
-			p.logger.Printf("[MQTT Plugin] Subscribed to alternative service topic: %s", altTopic)
+			p.logger.Info("subscribed to alternative service topic", "topic", altTopic)
 		}
 	}
 
 	// Skip reply topics for now as they may not be critical
-	p.logger.Printf("[MQTT Plugin] Topic subscription completed")
+	p.logger.Info("topic subscription completed")
 	return nil
 }
 
+// handleResubscribed surfaces the outcome of restoring subscriptions after a reconnect
+func (p *MQTTPlugin) handleResubscribed(results []mqtt.ResubscribeResult) {
+	failed := make(map[string]string)
+	for _, result := range results {
+		if result.Err != nil {
+			failed[result.Topic] = result.Err.Error()
+		}
+	}
+
+	if len(failed) > 0 {
+		p.logger.Warn("subscriptions could not be restored", "failed", len(failed), "total", len(results), "errors", failed)
+	}
+
+	p.framework.Emit(event.NewEvent(event.EventResubscribed, "mqtt", map[string]interface{}{
+		"results": results,
+		"failed":  failed,
+	}))
+}
+
+// handleQueue reports the offline publish backlog to the framework
+func (p *MQTTPlugin) handleQueue(stats mqtt.QueueStats) {
+	if stats.Depth > 0 {
+		p.logger.Info("offline queue backlog", "messages", stats.Depth, "bytes", stats.Bytes, "oldestAge", stats.OldestAge)
+	}
+	p.framework.Emit(event.NewEvent(event.EventOfflineQueue, "mqtt", stats))
+}
+
 // handlePropertySet handles property set messages from the cloud
 func (p *MQTTPlugin) handlePropertySet(topic string, payload []byte) {
-	p.logger.Printf("[MQTT Plugin] Property set message: %s", string(payload))
+	p.logger.Debug("property set message", "topic", topic, "payload", string(payload))
 
 	var msg struct {
 		ID     string                 `json:"id"`
@@ -230,7 +421,7 @@ func (p *MQTTPlugin) handlePropertySet(topic string, payload []byte) {
 	}
 
 	if err := json.Unmarshal(payload, &msg); err != nil {
-		p.logger.Printf("[MQTT Plugin] Failed to parse property set message: %v", err)
+		p.logger.Warn("failed to parse property set message", "error", err)
 		return
 	}
 
@@ -239,7 +430,7 @@ func (p *MQTTPlugin) handlePropertySet(topic string, payload []byte) {
 	evt.WithMetadata("messageId", msg.ID)
 
 	if err := p.framework.Emit(evt); err != nil {
-		p.logger.Printf("[MQTT Plugin] Failed to emit property set event: %v", err)
+		p.logger.Error("failed to emit property set event", "error", err)
 	}
 
 	// Send reply to property set
@@ -251,8 +442,8 @@ func (p *MQTTPlugin) handlePropertySet(topic string, payload []byte) {
 
 	replyData, _ := json.Marshal(reply)
 
-	if err := p.client.Publish(p.propertySetReplyTopic, replyData, 0, false); err != nil {
-		p.logger.Printf("[MQTT Plugin] Failed to send property set reply: %v", err)
+	if err := p.publish(p.propertySetReplyTopic, replyData); err != nil {
+		p.logger.Error("failed to send property set reply", "error", err)
 	}
 }
 
@@ -263,13 +454,13 @@ func (p *MQTTPlugin) handleServiceCall(topic string, payload []byte) {
 		return
 	}
 
-	p.logger.Printf("[MQTT Plugin] Service call message on topic %s: %s", topic, string(payload))
+	p.logger.Debug("service call message", "topic", topic, "payload", string(payload))
 
 	// Extract service name from topic
 	// Topic format: $SYS/{ProductKey}/{DeviceName}/service/{ServiceName}/invoke
 	parts := strings.Split(topic, "/")
 	if len(parts) < 6 {
-		p.logger.Printf("[MQTT Plugin] Invalid service topic: %s", topic)
+		p.logger.Warn("invalid service topic", "topic", topic)
 		return
 	}
 	serviceName := parts[4] // Service name is at index 4, not 5
@@ -280,7 +471,7 @@ func (p *MQTTPlugin) handleServiceCall(topic string, payload []byte) {
 	}
 
 	if err := json.Unmarshal(payload, &msg); err != nil {
-		p.logger.Printf("[MQTT Plugin] Failed to parse service call message: %v", err)
+		p.logger.Warn("failed to parse service call message", "topic", topic, "error", err)
 		return
 	}
 
@@ -296,14 +487,21 @@ func (p *MQTTPlugin) handleServiceCall(topic string, payload []byte) {
 	evt := event.NewEvent(event.EventServiceCall, "mqtt", request)
 
 	if err := p.framework.Emit(evt); err != nil {
-		p.logger.Printf("[MQTT Plugin] Failed to emit service call event: %v", err)
+		p.logger.Error("failed to emit service call event", "error", err)
 	}
 }
 
+// publish sends a QoS 0 message, giving up after publishTimeout
+func (p *MQTTPlugin) publish(topic string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	return p.client.PublishContext(ctx, topic, data, 0, false)
+}
+
 // reportProperties reports properties to the cloud
 func (p *MQTTPlugin) reportProperties(properties map[string]interface{}) error {
 	// Convert properties to Thing Model format with value and timestamp
-	timestamp := time.Now().Unix()
+	timestamp := p.now().Unix()
 	params := make(map[string]interface{})
 
 	for key, value := range properties {
@@ -326,11 +524,11 @@ func (p *MQTTPlugin) reportProperties(properties map[string]interface{}) error {
 	}
 
 	// Publish to property report topic
-	if err := p.client.Publish(p.propertyReportTopic, data, 0, false); err != nil {
+	if err := p.publish(p.propertyReportTopic, data); err != nil {
 		return fmt.Errorf("failed to publish property report: %w", err)
 	}
 
-	p.logger.Printf("[MQTT Plugin] Reported properties to %s: %s", p.propertyReportTopic, string(data))
+	p.logger.Debug("reported properties", "topic", p.propertyReportTopic, "payload", string(data))
 	return nil
 }
 
@@ -358,17 +556,17 @@ func (p *MQTTPlugin) sendServiceResponse(response core.ServiceResponse) error {
 		p.config.Device.ProductKey, p.config.Device.DeviceName)
 
 	// Publish to service reply topic
-	if err := p.client.Publish(replyTopic, data, 0, false); err != nil {
+	if err := p.publish(replyTopic, data); err != nil {
 		return fmt.Errorf("failed to publish service response: %w", err)
 	}
 
-	p.logger.Printf("[MQTT Plugin] Sent service response: %v", msg)
+	p.logger.Debug("sent service response", "topic", replyTopic, "payload", string(data))
 	return nil
 }
 
 // handlePropertyReportReply handles property report reply from cloud
 func (p *MQTTPlugin) handlePropertyReportReply(topic string, payload []byte) {
-	p.logger.Printf("[MQTT Plugin] Property report reply: %s", string(payload))
+	p.logger.Debug("property report reply", "payload", string(payload))
 
 	// Parse reply to check if cloud accepted the property report
 	var reply struct {
@@ -378,18 +576,18 @@ func (p *MQTTPlugin) handlePropertyReportReply(topic string, payload []byte) {
 	}
 
 	if err := json.Unmarshal(payload, &reply); err != nil {
-		p.logger.Printf("[MQTT Plugin] Failed to parse property report reply: %v", err)
+		p.logger.Warn("failed to parse property report reply", "error", err)
 		return
 	}
 
 	if reply.Code != 200 {
-		p.logger.Printf("[MQTT Plugin] Property report failed with code %d: %s", reply.Code, reply.Msg)
+		p.logger.Warn("property report failed", "code", reply.Code, "message", reply.Msg)
 	}
 }
 
 // handleEventReportReply handles event report reply from cloud
 func (p *MQTTPlugin) handleEventReportReply(topic string, payload []byte) {
-	p.logger.Printf("[MQTT Plugin] Event report reply: %s", string(payload))
+	p.logger.Debug("event report reply", "payload", string(payload))
 
 	// Parse reply to check if cloud accepted the event
 	var reply struct {
@@ -399,18 +597,18 @@ func (p *MQTTPlugin) handleEventReportReply(topic string, payload []byte) {
 	}
 
 	if err := json.Unmarshal(payload, &reply); err != nil {
-		p.logger.Printf("[MQTT Plugin] Failed to parse event report reply: %v", err)
+		p.logger.Warn("failed to parse event report reply", "error", err)
 		return
 	}
 
 	if reply.Code != 200 {
-		p.logger.Printf("[MQTT Plugin] Event report failed with code %d: %s", reply.Code, reply.Msg)
+		p.logger.Warn("event report failed", "code", reply.Code, "message", reply.Msg)
 	}
 }
 
 // reportEvent reports an event to the cloud
 func (p *MQTTPlugin) reportEvent(eventData map[string]interface{}) error {
-	msg, eventType, err := buildEventReportMessage(eventData, time.Now())
+	msg, eventType, err := buildEventReportMessage(eventData, p.now())
 	if err != nil {
 		return err
 	}
@@ -421,11 +619,11 @@ func (p *MQTTPlugin) reportEvent(eventData map[string]interface{}) error {
 	}
 
 	// Publish to event report topic
-	if err := p.client.Publish(p.eventReportTopic, data, 0, false); err != nil {
+	if err := p.publish(p.eventReportTopic, data); err != nil {
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
 
-	p.logger.Printf("[MQTT Plugin] Reported event %s to %s: %s", eventType, p.eventReportTopic, string(data))
+	p.logger.Debug("reported event", "event", eventType, "topic", p.eventReportTopic, "payload", string(data))
 	return nil
 }
 
@@ -515,6 +713,47 @@ func normalizeEpochMillis(timestamp int64, fallback time.Time) int64 {
 	}
 }
 
+// Configure applies settings from a live config reload. The framework
+// passes the "device" and "mqtt" sections when connection settings
+// changed; a started plugin then tears down its connection, even one that
+// is down and retrying, reconnects with them and restores its
+// subscriptions. A plugin that is not started uses them on Start.
+func (p *MQTTPlugin) Configure(settings map[string]interface{}) error {
+	device, hasDevice := settings["device"].(core.DeviceConfig)
+	mqttConfig, hasMQTT := settings["mqtt"].(core.MQTTConfig)
+	if err := p.BasePlugin.Configure(settings); err != nil {
+		return err
+	}
+	if !hasDevice || !hasMQTT {
+		return nil
+	}
+
+	if !p.running {
+		core.Config{Device: device, MQTT: mqttConfig}.ApplyTo(p.config)
+		return nil
+	}
+	p.logger.Info("reconnecting with new connection settings")
+	previousDevice, previousMQTT := p.config.Device, p.config.MQTT
+	p.client.Disconnect()
+	core.Config{Device: device, MQTT: mqttConfig}.ApplyTo(p.config)
+	if err := p.client.Connect(); err != nil {
+		// Go back to the settings that worked
+		p.config.Device, p.config.MQTT = previousDevice, previousMQTT
+		if err := p.client.Connect(); err != nil {
+			p.logger.Error("failed to reconnect with previous connection settings", "error", err)
+		}
+		return fmt.Errorf("failed to reconnect to MQTT broker: %w", err)
+	}
+	p.logger.Info("reconnected to MQTT broker", "host", p.config.MQTT.Host, "port", p.config.MQTT.Port)
+	return nil
+}
+
+// SetLogger overrides the logger derived from the framework in Init
+func (p *MQTTPlugin) SetLogger(logger logging.Logger) {
+	p.logger = logging.Redacting(logger)
+	p.loggerSet = true
+}
+
 // GetClient returns the underlying MQTT client (for advanced usage)
 func (p *MQTTPlugin) GetClient() *mqtt.Client {
 	return p.client
@@ -524,8 +763,25 @@ func (p *MQTTPlugin) GetClient() *mqtt.Client {
 func (p *MQTTPlugin) RegisterRRPCHandler(method string, handler func(requestId string, payload []byte) ([]byte, error)) {
 	if p.rrpcClient != nil {
 		p.rrpcClient.RegisterHandler(method, handler)
-		p.logger.Printf("[MQTT Plugin] Registered RRPC handler for method: %s", method)
+		p.logger.Info("registered RRPC handler", "method", method)
+	}
+}
+
+// RegisterRRPC registers a handler receiving the parsed RRPC request, see
+// rrpc.Handler
+func (p *MQTTPlugin) RegisterRRPC(method string, handler rrpc.Handler) {
+	if p.rrpcClient != nil {
+		p.rrpcClient.Register(method, handler)
+		p.logger.Info("registered RRPC handler", "method", method)
+	}
+}
+
+// QueueStats returns the offline publish backlog of the MQTT client
+func (p *MQTTPlugin) QueueStats() mqtt.QueueStats {
+	if p.client == nil {
+		return mqtt.QueueStats{}
 	}
+	return p.client.QueueStats()
 }
 
 // GetMQTTClient returns the underlying MQTT client for use by other components like OTA
@@ -576,7 +832,7 @@ func (p *MQTTPlugin) registerRRPCHandlers() {
 		// Collect all current property values
 		status := map[string]interface{}{
 			"status":    "online",
-			"timestamp": time.Now().Unix(),
+			"timestamp": p.now().Unix(),
 		}
 
 		return json.Marshal(status)
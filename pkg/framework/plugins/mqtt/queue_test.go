@@ -0,0 +1,50 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/framework/event"
+	"github.com/iot-go-sdk/pkg/mqtt"
+)
+
+func TestOfflineQueueBacklogEvents(t *testing.T) {
+	p := startPlatform(t)
+	cfg := p.Config("pk", "dn")
+	cfg.OfflineQueue.Dir = t.TempDir()
+
+	// A backlog left by an earlier run is drained after connecting
+	queue, err := mqtt.OpenOfflineQueue(cfg.OfflineQueue.Dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.Enqueue("/pk/dn/user/update", []byte("queued"), 1, false, mqtt.DropOldest)
+
+	f, plugin := newPlugin(t, cfg)
+	reports := make(chan mqtt.QueueStats, 2)
+	f.On(event.EventOfflineQueue, func(evt *event.Event) error {
+		reports <- evt.Data.(mqtt.QueueStats)
+		return nil
+	})
+	startPlugin(t, plugin)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, want := range []int{1, 0} {
+		select {
+		case stats := <-reports:
+			if stats.Depth != want {
+				t.Fatalf("backlog = %+v, want depth %d", stats, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("no backlog report with depth %d", want)
+		}
+	}
+	if m, err := p.WaitForMessage(ctx, "/pk/dn/user/update"); err != nil || string(m.Payload) != "queued" {
+		t.Fatalf("message = %+v, err = %v", m, err)
+	}
+	if stats := plugin.QueueStats(); stats.Depth != 0 {
+		t.Fatalf("QueueStats = %+v", stats)
+	}
+}
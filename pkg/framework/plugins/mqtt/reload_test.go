@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConfigureReconnectsWithNewSettings(t *testing.T) {
+	for _, dropped := range []bool{false, true} {
+		from, to := startPlatform(t), startPlatform(t)
+		f, plugin := newPlugin(t, from.Config("pk", "dn"))
+		startPlugin(t, plugin)
+
+		if dropped {
+			// The client keeps retrying the broker that went away; the
+			// reload must stop that and connect to the new one
+			from.Close()
+		}
+		_, port, _ := net.SplitHostPort(to.BrokerAddr())
+		next := f.Config()
+		next.MQTT.Port, _ = strconv.Atoi(port)
+		change, err := f.ApplyConfig(next, true)
+		if err != nil {
+			t.Fatalf("dropped %v: %v", dropped, err)
+		}
+		if !change.Reconnected || !to.Connected("pk", "dn") || from.Connected("pk", "dn") {
+			t.Fatalf("dropped %v: change = %+v, new platform connected %v", dropped, change, to.Connected("pk", "dn"))
+		}
+
+		// Nothing reconnects to the old platform afterwards
+		time.Sleep(50 * time.Millisecond)
+		if from.Connected("pk", "dn") || !plugin.GetClient().IsConnected() {
+			t.Fatalf("dropped %v: connection moved back", dropped)
+		}
+	}
+}
+
+func TestConfigureBeforeStartAppliesOnStart(t *testing.T) {
+	from, to := startPlatform(t), startPlatform(t)
+	f, plugin := newPlugin(t, from.Config("pk", "dn"))
+
+	_, port, _ := net.SplitHostPort(to.BrokerAddr())
+	next := f.Config()
+	next.MQTT.Port, _ = strconv.Atoi(port)
+	if _, err := f.ApplyConfig(next, true); err != nil {
+		t.Fatal(err)
+	}
+	if to.Connected("pk", "dn") {
+		t.Fatal("connected before Start")
+	}
+
+	startPlugin(t, plugin)
+	if !to.Connected("pk", "dn") || from.Connected("pk", "dn") {
+		t.Fatal("Start did not use the reloaded settings")
+	}
+}
+
+func TestConfigureKeepsWorkingSettingsOnFailure(t *testing.T) {
+	from := startPlatform(t)
+	f, plugin := newPlugin(t, from.Config("pk", "dn"))
+	startPlugin(t, plugin)
+
+	// Nothing listens on the new port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	previous := f.Config()
+	next := previous
+	next.MQTT.Port, _ = strconv.Atoi(port)
+	if _, err := f.ApplyConfig(next, true); err == nil {
+		t.Fatal("reload to an unreachable broker succeeded")
+	}
+	if f.Config().MQTT.Port != previous.MQTT.Port {
+		t.Fatalf("running port = %d, want %d", f.Config().MQTT.Port, previous.MQTT.Port)
+	}
+	if !from.Connected("pk", "dn") || !plugin.GetClient().IsConnected() {
+		t.Fatal("not reconnected with the previous settings")
+	}
+}
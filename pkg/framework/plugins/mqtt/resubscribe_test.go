@@ -0,0 +1,101 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/framework/core"
+	"github.com/iot-go-sdk/pkg/framework/event"
+	"github.com/iot-go-sdk/pkg/testplatform"
+)
+
+func startPlatform(t *testing.T) *testplatform.Platform {
+	t.Helper()
+	p, err := testplatform.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	p.AddDevice("pk", "dn", "secret")
+	return p
+}
+
+// newPlugin returns an initialized plugin for cfg, loaded into a framework
+// that is not started; events are delivered synchronously all the same.
+func newPlugin(t *testing.T, cfg *config.Config) (core.Framework, *MQTTPlugin) {
+	t.Helper()
+	frameworkConfig := core.FromSDKConfig(cfg)
+	frameworkConfig.Logging.Output = "stderr"
+	f := core.New(frameworkConfig)
+	if err := f.Initialize(frameworkConfig); err != nil {
+		t.Fatal(err)
+	}
+	plugin := NewMQTTPlugin(cfg)
+	if err := f.LoadPlugin(plugin); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugin.Init(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+	return f, plugin
+}
+
+func startPlugin(t *testing.T, plugin *MQTTPlugin) {
+	t.Helper()
+	if err := plugin.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { plugin.Stop() })
+}
+
+func TestResubscribedEventReportsFailures(t *testing.T) {
+	p := startPlatform(t)
+	f, plugin := newPlugin(t, p.Config("pk", "dn"))
+	failures := make(chan map[string]string, 4)
+	f.On(event.EventResubscribed, func(evt *event.Event) error {
+		failures <- evt.Data.(map[string]interface{})["failed"].(map[string]string)
+		return nil
+	})
+	propertySets := make(chan map[string]interface{}, 1)
+	f.On(event.EventPropertySet, func(evt *event.Event) error {
+		propertySets <- evt.Data.(map[string]interface{})
+		return nil
+	})
+	startPlugin(t, plugin)
+
+	serviceTopic := "$SYS/pk/dn/service/+/invoke"
+	p.RejectSubscriptions(serviceTopic)
+	p.DisconnectDevice("pk", "dn")
+
+	// Skip a report of the initial connect, which completes asynchronously
+	var failed map[string]string
+	timeout := time.After(10 * time.Second)
+	for failed[serviceTopic] == "" {
+		select {
+		case failed = <-failures:
+		case <-timeout:
+			t.Fatal("no resubscribe failure reported after reconnect")
+		}
+	}
+	if len(failed) != 1 {
+		t.Fatalf("failed = %v, want only %s", failed, serviceTopic)
+	}
+
+	// Restored subscriptions reach their handlers again
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.WaitForSubscriber(ctx, "$SYS/pk/dn/property/set"); err != nil {
+		t.Fatal(err)
+	}
+	p.Publish("$SYS/pk/dn/property/set", []byte(`{"id":"1","version":"1.0","params":{"LightSwitch":1}}`))
+	select {
+	case params := <-propertySets:
+		if params["LightSwitch"] != 1.0 {
+			t.Fatalf("params = %v", params)
+		}
+	case <-ctx.Done():
+		t.Fatal("property set not delivered after reconnect")
+	}
+}
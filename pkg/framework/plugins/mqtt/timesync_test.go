@@ -0,0 +1,107 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/clock"
+	"github.com/iot-go-sdk/pkg/framework/event"
+	"github.com/iot-go-sdk/pkg/timesync"
+)
+
+// rtcOffset is how far behind the simulated device clock is; the platform
+// certificate is not valid yet from the device's point of view.
+const rtcOffset = 5 * 365 * 24 * time.Hour
+
+func near(got, want time.Duration) bool {
+	return got > want-time.Minute && got < want+time.Minute
+}
+
+func TestStartRecoversFromRejectedClock(t *testing.T) {
+	p := startPlatform(t)
+	p.SetClockOffset(rtcOffset)
+	p.SetTimestampTolerance(time.Minute)
+
+	// Without time sync the signed timestamp is refused
+	_, plugin := newPlugin(t, p.Config("pk", "dn"))
+	if err := plugin.Start(); err == nil {
+		plugin.Stop()
+		t.Fatal("connected with a clock years behind")
+	}
+
+	// The time of a plain HTTP response is not trusted
+	cfg := p.Config("pk", "dn")
+	cfg.TimeSync.Enabled = true
+	cfg.DynReg.Scheme = "http"
+	_, plugin = newPlugin(t, cfg)
+	if err := plugin.Start(); err == nil {
+		plugin.Stop()
+		t.Fatal("clock corrected from plain HTTP")
+	}
+	plugin.Stop()
+
+	cfg = p.Config("pk", "dn")
+	cfg.TimeSync.Enabled = true
+	f, plugin := newPlugin(t, cfg)
+	skews := make(chan timesync.Skew, 2)
+	f.On(event.EventClockSkew, func(evt *event.Event) error {
+		skews <- evt.Data.(timesync.Skew)
+		return nil
+	})
+	startPlugin(t, plugin)
+
+	if !p.Connected("pk", "dn") {
+		t.Fatal("not connected after correcting the clock")
+	}
+	select {
+	case skew := <-skews:
+		if !near(skew.Offset, rtcOffset) {
+			t.Fatalf("offset = %v, want about %v", skew.Offset, rtcOffset)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no clock skew reported")
+	}
+}
+
+func TestPluginClocksAreIndependent(t *testing.T) {
+	skewed, accurate := startPlatform(t), startPlatform(t)
+	skewed.SetClockOffset(rtcOffset)
+
+	startWithTimeSync := func(p interface{ Connected(string, string) bool }, plugin *MQTTPlugin) {
+		t.Helper()
+		startPlugin(t, plugin)
+		if !p.Connected("pk", "dn") {
+			t.Fatal("not connected")
+		}
+	}
+	cfg := skewed.Config("pk", "dn")
+	cfg.TimeSync.Enabled = true
+	_, first := newPlugin(t, cfg)
+	startWithTimeSync(skewed, first)
+	cfg = accurate.Config("pk", "dn")
+	cfg.TimeSync.Enabled = true
+	_, second := newPlugin(t, cfg)
+	startWithTimeSync(accurate, second)
+
+	// Each plugin stamps data with its own server's time
+	deadline := time.Now().Add(5 * time.Second)
+	for !near(first.now().Sub(time.Now()), rtcOffset) {
+		if time.Now().After(deadline) {
+			t.Fatalf("first plugin offset = %v", first.now().Sub(time.Now()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if offset := second.now().Sub(time.Now()); !near(offset, 0) {
+		t.Fatalf("second plugin offset = %v", offset)
+	}
+
+	// Only the plugin that installed the default clock removes it
+	second.Stop()
+	if offset := clock.Now().Sub(time.Now()); !near(offset, rtcOffset) {
+		t.Fatalf("default clock offset after stopping the second plugin = %v", offset)
+	}
+	first.Stop()
+	if _, ok := clock.Default().(*clock.Corrected); ok {
+		t.Fatal("default clock still corrected after stopping the first plugin")
+	}
+}
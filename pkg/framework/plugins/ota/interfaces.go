@@ -2,6 +2,8 @@ package ota
 
 import (
 	"context"
+
+	"github.com/iot-go-sdk/pkg/logging"
 )
 
 // Status represents OTA update status
@@ -71,4 +73,5 @@ type Manager interface {
 	SetStatusCallback(callback StatusCallback)
 	SetAutoUpdate(enabled bool)
 	GetStatus() Status
+	SetLogger(logger logging.Logger)
 }
\ No newline at end of file
@@ -4,13 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/iot-go-sdk/pkg/logging"
 	"github.com/iot-go-sdk/pkg/mqtt"
 	"github.com/iot-go-sdk/pkg/ota"
 )
@@ -31,7 +31,7 @@ type ManagerImpl struct {
 	autoUpdate      bool
 	
 	mu              sync.RWMutex
-	logger          *log.Logger
+	logger          logging.Logger
 	stopCh          chan struct{}
 	wg              sync.WaitGroup
 }
@@ -45,12 +45,13 @@ func NewManager(mqttClient *mqtt.Client, productKey, deviceName string, versionP
 		versionProvider: versionProvider,
 		status:          StatusIdle,
 		autoUpdate:      true,
-		logger:          log.New(os.Stdout, fmt.Sprintf("[OTA-%s] ", deviceName), log.LstdFlags),
+		logger:          logging.Default().Named("ota").With("device", deviceName),
 		stopCh:          make(chan struct{}),
 	}
 	
 	// Create OTA client
 	manager.otaClient = ota.NewClient(mqttClient, productKey, deviceName)
+	manager.otaClient.SetLogger(manager.logger.Named("client"))
 	
 	// Get current version
 	manager.currentVersion = versionProvider.GetVersion()
@@ -62,9 +63,18 @@ func NewManager(mqttClient *mqtt.Client, productKey, deviceName string, versionP
 	return manager
 }
 
+// SetLogger sets the logger for the manager and its OTA client
+func (m *ManagerImpl) SetLogger(logger logging.Logger) {
+	m.logger = logging.Redacting(logger)
+	m.otaClient.SetLogger(m.logger.Named("client"))
+	if updater, ok := m.updater.(*BinaryUpdater); ok {
+		updater.logger = m.logger
+	}
+}
+
 // Start starts the OTA manager
 func (m *ManagerImpl) Start() error {
-	m.logger.Printf("Starting OTA manager, current version: %s", m.currentVersion)
+	m.logger.Info("starting OTA manager", "version", m.currentVersion)
 	
 	// Set up OTA handlers
 	m.setupHandlers()
@@ -86,7 +96,7 @@ func (m *ManagerImpl) Start() error {
 
 // Stop stops the OTA manager
 func (m *ManagerImpl) Stop() error {
-	m.logger.Println("Stopping OTA manager")
+	m.logger.Info("stopping OTA manager")
 	
 	// Signal stop
 	select {
@@ -107,13 +117,13 @@ func (m *ManagerImpl) Stop() error {
 	case <-done:
 		// All goroutines finished
 	case <-time.After(2 * time.Second):
-		m.logger.Println("Warning: Timeout waiting for OTA manager goroutines to stop")
+		m.logger.Warn("timeout waiting for OTA manager goroutines to stop")
 	}
 	
 	// Stop OTA client
 	if m.otaClient != nil {
 		if err := m.otaClient.Stop(); err != nil {
-			m.logger.Printf("Warning: Failed to stop OTA client: %v", err)
+			m.logger.Warn("failed to stop OTA client", "error", err)
 			// Don't return error, continue cleanup
 		}
 	}
@@ -130,7 +140,7 @@ func (m *ManagerImpl) GetCurrentVersion() string {
 
 // CheckUpdate checks for available updates
 func (m *ManagerImpl) CheckUpdate() (*UpdateInfo, error) {
-	m.logger.Println("Checking for updates...")
+	m.logger.Info("checking for updates")
 	
 	// First report current version to ensure platform knows our current state
 	m.reportVersion()
@@ -143,11 +153,11 @@ func (m *ManagerImpl) CheckUpdate() (*UpdateInfo, error) {
 	
 	// Then query for firmware updates with module parameter - this is the key step that was missing!
 	if err := m.otaClient.QueryFirmwareWithModule(module); err != nil {
-		m.logger.Printf("Failed to query firmware updates: %v", err)
+		m.logger.Error("failed to query firmware updates", "error", err)
 		return nil, err
 	}
 	
-	m.logger.Printf("Firmware update query sent for module '%s', waiting for platform response...", module)
+	m.logger.Info("firmware update query sent, waiting for platform response", "module", module)
 	
 	// Updates are handled asynchronously via callback
 	return nil, nil
@@ -216,14 +226,14 @@ func (m *ManagerImpl) PerformUpdate(info *UpdateInfo) (*UpdateResult, error) {
 	
 	// Update version in device properties
 	if err := m.versionProvider.SetVersion(info.Version); err != nil {
-		m.logger.Printf("Failed to save version to device: %v", err)
+		m.logger.Error("failed to save version to device", "error", err)
 	}
 	
 	// Also update the version.txt file directly to persist the new version
 	if err := m.updateVersionFile(info.Version); err != nil {
-		m.logger.Printf("Failed to update version.txt file: %v", err)
+		m.logger.Error("failed to update version file", "error", err)
 	} else {
-		m.logger.Printf("Successfully updated version.txt to version %s", info.Version)
+		m.logger.Info("updated version file", "version", info.Version)
 	}
 	
 	// Report progress with module info
@@ -244,7 +254,7 @@ func (m *ManagerImpl) PerformUpdate(info *UpdateInfo) (*UpdateResult, error) {
 		
 		// Try to rollback
 		if rollbackErr := m.updater.Rollback(); rollbackErr != nil {
-			m.logger.Printf("Rollback failed: %v", rollbackErr)
+			m.logger.Error("rollback failed", "error", rollbackErr)
 		}
 		
 		return &UpdateResult{
@@ -291,14 +301,14 @@ func (m *ManagerImpl) GetStatus() Status {
 func (m *ManagerImpl) setupHandlers() {
 	// Handle OTA message
 	m.otaClient.SetRecvHandler(func(client *ota.Client, recvType ota.RecvType, task *ota.TaskDesc) {
-		m.logger.Printf("=== OTA Update Available ===")
-		m.logger.Printf("  Current version: %s", m.currentVersion)
-		m.logger.Printf("  New version: %s", task.Version)
-		m.logger.Printf("  Size: %d bytes", task.Size)
+		m.logger.Info("OTA update available",
+			"currentVersion", m.currentVersion,
+			"newVersion", task.Version,
+			"size", task.Size)
 		
 		// Check if it's a new version
 		if task.Version == m.currentVersion {
-			m.logger.Printf("Already on version %s, skipping update", task.Version)
+			m.logger.Info("already on version, skipping update", "version", task.Version)
 			m.otaClient.ReportProgress("download", "Already on latest version", 100, "")
 			return
 		}
@@ -322,7 +332,7 @@ func (m *ManagerImpl) setupHandlers() {
 			go func() {
 				result, _ := m.PerformUpdate(info)
 				if !result.Success {
-					m.logger.Printf("Auto-update failed: %s", result.Message)
+					m.logger.Error("auto-update failed", "message", result.Message)
 				}
 			}()
 		}
@@ -335,7 +345,7 @@ func (m *ManagerImpl) reportVersion() {
 	if m.versionProvider != nil {
 		module = m.versionProvider.GetModule()
 	}
-	m.logger.Printf("Reporting version to platform: %s (module: %s)", m.currentVersion, module)
+	m.logger.Info("reporting version to platform", "version", m.currentVersion, "module", module)
 	m.otaClient.ReportVersionWithModule(m.currentVersion, module)
 }
 
@@ -350,13 +360,13 @@ func (m *ManagerImpl) updateCheckLoop() {
 		// Only check update if we're not being stopped
 		select {
 		case <-m.stopCh:
-			m.logger.Println("Update check loop stopped before initial check")
+			m.logger.Debug("update check loop stopped before initial check")
 			return
 		default:
 			m.CheckUpdate()
 		}
 	case <-m.stopCh:
-		m.logger.Println("Update check loop stopped during initial delay")
+		m.logger.Debug("update check loop stopped during initial delay")
 		return
 	}
 	
@@ -369,7 +379,7 @@ func (m *ManagerImpl) updateCheckLoop() {
 		case <-ticker.C:
 			m.CheckUpdate()
 		case <-m.stopCh:
-			m.logger.Println("Update check loop stopped")
+			m.logger.Debug("update check loop stopped")
 			return
 		}
 	}
@@ -404,7 +414,7 @@ func (m *ManagerImpl) notifyStatus(status Status, progress int32, message string
 	} else if status == StatusDownloading || status == StatusVerifying || status == StatusUpdating {
 		// Report progress as percentage (0-100)
 		m.otaClient.ReportProgress("download", message, int(progress), module)
-		m.logger.Printf("Reported progress: %d%% (%s) - %s", progress, module, message)
+		m.logger.Debug("reported progress", "progress", progress, "module", module, "message", message)
 	}
 }
 
@@ -559,7 +569,7 @@ func (m *ManagerImpl) updateVersionFile(newVersion string) error {
 	for _, path := range possiblePaths {
 		lastErr = os.WriteFile(path, data, 0644)
 		if lastErr == nil {
-			m.logger.Printf("Updated version file at %s with version %s (module: %s)", path, newVersion, module)
+			m.logger.Info("updated version file", "path", path, "version", newVersion, "module", module)
 			return nil
 		}
 	}
@@ -3,12 +3,13 @@ package ota
 import (
 	"context"
 	"fmt"
-	"log"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/iot-go-sdk/pkg/framework/core"
 	"github.com/iot-go-sdk/pkg/framework/event"
+	"github.com/iot-go-sdk/pkg/logging"
 	"github.com/iot-go-sdk/pkg/mqtt"
 )
 
@@ -33,7 +34,8 @@ type OTAPlugin struct {
 	managers           map[string]Manager
 	deviceWrappers     map[string]*DeviceWrapper
 	mu                 sync.RWMutex
-	logger             *log.Logger
+	logger             logging.Logger
+	loggerSet          bool
 	autoUpdate         bool
 	checkInterval      time.Duration
 	stopCh             chan struct{}
@@ -49,7 +51,7 @@ func NewOTAPlugin() *OTAPlugin {
 		status:         PluginStatusStopped,
 		managers:       make(map[string]Manager),
 		deviceWrappers: make(map[string]*DeviceWrapper),
-		logger:         log.New(log.Writer(), "[OTA Plugin] ", log.LstdFlags),
+		logger:         logging.Default().Named("ota"),
 		autoUpdate:     true,
 		checkInterval:  5 * time.Minute,
 		stopCh:         make(chan struct{}),
@@ -85,6 +87,12 @@ func (p *OTAPlugin) SetStatus(status PluginStatus) {
 	p.mu.Unlock()
 }
 
+// SetLogger overrides the logger derived from the framework in Init
+func (p *OTAPlugin) SetLogger(logger logging.Logger) {
+	p.logger = logging.Redacting(logger)
+	p.loggerSet = true
+}
+
 // Init initializes the OTA plugin
 func (p *OTAPlugin) Init(ctx context.Context, framework interface{}) error {
 	fw, ok := framework.(core.Framework)
@@ -92,7 +100,10 @@ func (p *OTAPlugin) Init(ctx context.Context, framework interface{}) error {
 		return fmt.Errorf("invalid framework type")
 	}
 	p.framework = fw
-	p.logger.Println("Initializing OTA plugin")
+	if !p.loggerSet {
+		p.logger = fw.Logger().Named("ota")
+	}
+	p.logger.Info("initializing")
 	
 	// Register event handlers
 	p.registerEventHandlers()
@@ -105,34 +116,49 @@ func (p *OTAPlugin) Dependencies() []string {
 	return []string{"mqtt"} // OTA plugin depends on MQTT plugin
 }
 
-// Configure configures the plugin
+// Configure configures the plugin. It is also called on live config
+// reloads, where values from configuration files arrive as strings; a new
+// check interval applies from the next check.
 func (p *OTAPlugin) Configure(config map[string]interface{}) error {
-	// Configure from map if needed
-	if autoUpdate, ok := config["auto_update"].(bool); ok {
-		p.autoUpdate = autoUpdate
+	switch v := config["auto_update"].(type) {
+	case bool:
+		p.SetAutoUpdate(v)
+	case string:
+		autoUpdate, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid auto_update: %q", v)
+		}
+		p.SetAutoUpdate(autoUpdate)
 	}
-	if checkInterval, ok := config["check_interval"].(time.Duration); ok {
-		p.checkInterval = checkInterval
+	switch v := config["check_interval"].(type) {
+	case time.Duration:
+		p.SetCheckInterval(v)
+	case string:
+		checkInterval, err := time.ParseDuration(v)
+		if err != nil || checkInterval <= 0 {
+			return fmt.Errorf("invalid check_interval: %q", v)
+		}
+		p.SetCheckInterval(checkInterval)
 	}
 	return nil
 }
 
 // Start starts the OTA plugin
 func (p *OTAPlugin) Start() error {
-	p.logger.Println("Starting OTA plugin")
+	p.logger.Info("starting")
 	
 	// Don't initialize MQTT client immediately - defer it until stable connection
-	p.logger.Println("OTA plugin will initialize MQTT client when needed")
+	p.logger.Debug("MQTT client will be resolved when needed")
 	
 	// Set plugin status to running
 	p.SetStatus(PluginStatusRunning)
-	p.logger.Println("OTA plugin started successfully")
+	p.logger.Info("started")
 	return nil
 }
 
 // Stop stops the OTA plugin
 func (p *OTAPlugin) Stop() error {
-	p.logger.Println("Stopping OTA plugin")
+	p.logger.Info("stopping")
 	
 	// Check if already stopped
 	if p.GetStatus() == PluginStatusStopped {
@@ -155,7 +181,7 @@ func (p *OTAPlugin) Stop() error {
 	for deviceID, manager := range p.managers {
 		if manager != nil {
 			if err := manager.Stop(); err != nil {
-				p.logger.Printf("Failed to stop OTA manager for device %s: %v", deviceID, err)
+				p.logger.Error("failed to stop OTA manager", "device", deviceID, "error", err)
 				managerStopErrors = append(managerStopErrors, err)
 			}
 		}
@@ -173,9 +199,9 @@ func (p *OTAPlugin) Stop() error {
 	
 	select {
 	case <-done:
-		p.logger.Println("All OTA plugin goroutines stopped successfully")
+		p.logger.Info("all goroutines stopped")
 	case <-time.After(2 * time.Second): // Reduced timeout
-		p.logger.Println("Warning: Timeout waiting for OTA plugin goroutines to stop")
+		p.logger.Warn("timeout waiting for goroutines to stop")
 		// Force continue to prevent hanging
 	}
 	
@@ -243,7 +269,7 @@ func (p *OTAPlugin) SetMQTTClient(client *mqtt.Client) error {
 	}
 	
 	p.mqttClient = client
-	p.logger.Println("MQTT client set directly for OTA plugin")
+	p.logger.Debug("MQTT client set directly")
 	return nil
 }
 
@@ -252,15 +278,15 @@ func (p *OTAPlugin) createManagerForDevice(dev core.Device) error {
 	// Create device wrapper
 	wrapper := NewDeviceWrapper(dev)
 	deviceID := wrapper.GetDeviceID()
-	p.logger.Printf("Creating OTA manager for device %s", deviceID)
+	p.logger.Info("creating OTA manager", "device", deviceID)
 	
 	// Get MQTT client first (without holding the main lock to avoid deadlock)
-	p.logger.Printf("Getting MQTT client for device %s...", deviceID)
+	p.logger.Debug("getting MQTT client", "device", deviceID)
 	mqttClient := p.getMQTTClient()
 	if mqttClient == nil {
 		return fmt.Errorf("MQTT client not available for device %s", deviceID)
 	}
-	p.logger.Printf("Got MQTT client for device %s", deviceID)
+	p.logger.Debug("got MQTT client", "device", deviceID)
 	
 	// Now acquire lock for the manager operations
 	p.mu.Lock()
@@ -268,7 +294,7 @@ func (p *OTAPlugin) createManagerForDevice(dev core.Device) error {
 	
 	// Check if manager already exists (double-check with lock)
 	if _, exists := p.managers[deviceID]; exists {
-		p.logger.Printf("OTA manager for device %s already exists", deviceID)
+		p.logger.Debug("OTA manager already exists", "device", deviceID)
 		return nil
 	}
 	
@@ -278,14 +304,15 @@ func (p *OTAPlugin) createManagerForDevice(dev core.Device) error {
 	// Get device credentials
 	productKey := wrapper.GetProductKey()
 	deviceName := wrapper.GetDeviceName()
-	p.logger.Printf("Device credentials: ProductKey=%s, DeviceName=%s", productKey, deviceName)
+	p.logger.Debug("device identity", "productKey", productKey, "deviceName", deviceName)
 	
 	// Create version provider wrapper
 	versionProvider := &deviceVersionProvider{wrapper: wrapper}
 	
 	// Create OTA manager
-	p.logger.Printf("Creating OTA manager instance for device %s", deviceID)
+	p.logger.Debug("creating OTA manager instance", "device", deviceID)
 	manager := NewManager(mqttClient, productKey, deviceName, versionProvider)
+	manager.SetLogger(p.logger.With("device", deviceID))
 	
 	// Set status callback to update device properties
 	manager.SetStatusCallback(func(status Status, progress int32, message string) {
@@ -301,13 +328,13 @@ func (p *OTAPlugin) createManagerForDevice(dev core.Device) error {
 	}
 	
 	p.managers[deviceID] = manager
-	p.logger.Printf("Created OTA manager for device %s", deviceID)
+	p.logger.Info("created OTA manager", "device", deviceID)
 	
 	// Start auto-update checker on first device registration
 	if len(p.managers) == 1 && p.autoUpdate && p.GetStatus() == PluginStatusRunning {
 		p.wg.Add(1)
 		go p.autoUpdateLoop()
-		p.logger.Println("Started auto-update checker")
+		p.logger.Info("started auto-update checker")
 	}
 	
 	return nil
@@ -348,18 +375,18 @@ func (p *OTAPlugin) getMQTTClient() *mqtt.Client {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	
-	p.logger.Printf("getMQTTClient called, cached client available: %v", p.mqttClient != nil)
+	p.logger.Debug("resolving MQTT client", "cached", p.mqttClient != nil)
 	
 	// Return cached client if available
 	if p.mqttClient != nil {
-		p.logger.Printf("Returning cached MQTT client")
+		p.logger.Debug("returning cached MQTT client")
 		return p.mqttClient
 	}
 	
 	// Try to get MQTT plugin directly without goroutine to avoid deadlock
 	mqttPlugin, err := p.framework.GetPlugin("mqtt")
 	if err != nil {
-		p.logger.Printf("Warning: MQTT plugin not found: %v", err)
+		p.logger.Warn("MQTT plugin not found", "error", err)
 		return nil
 	}
 	
@@ -370,7 +397,7 @@ func (p *OTAPlugin) getMQTTClient() *mqtt.Client {
 	
 	provider, ok := mqttPlugin.(mqttClientProvider)
 	if !ok {
-		p.logger.Printf("Warning: MQTT plugin does not provide GetMQTTClient method")
+		p.logger.Warn("MQTT plugin does not provide GetMQTTClient method")
 		return nil
 	}
 	
@@ -378,9 +405,9 @@ func (p *OTAPlugin) getMQTTClient() *mqtt.Client {
 	client := provider.GetMQTTClient()
 	if client != nil {
 		p.mqttClient = client
-		p.logger.Printf("Successfully retrieved MQTT client")
+		p.logger.Debug("retrieved MQTT client")
 	} else {
-		p.logger.Printf("MQTT client retrieval returned nil")
+		p.logger.Warn("MQTT client retrieval returned nil")
 	}
 	return client
 }
@@ -397,7 +424,7 @@ func (p *OTAPlugin) registerEventHandlers() {
 			
 			if data, ok := evt.Data.(map[string]interface{}); ok {
 				if deviceID, ok := data["device_id"].(string); ok {
-					p.logger.Printf("Processing device registration for %s (delayed)", deviceID)
+					p.logger.Debug("processing delayed device registration", "device", deviceID)
 					
 					// Try multiple times if framework is busy
 					maxRetries := 3
@@ -405,24 +432,24 @@ func (p *OTAPlugin) registerEventHandlers() {
 						dev, err := p.framework.GetDevice(deviceID)
 						if err == nil {
 							if err := p.RegisterDevice(dev); err != nil {
-								p.logger.Printf("Failed to register device %s for OTA (attempt %d): %v", deviceID, i+1, err)
+								p.logger.Warn("failed to register device for OTA", "device", deviceID, "attempt", i+1, "error", err)
 								if i < maxRetries-1 {
 									time.Sleep(1 * time.Second)
 									continue
 								}
 							} else {
-								p.logger.Printf("Successfully registered device %s for OTA", deviceID)
+								p.logger.Info("registered device for OTA", "device", deviceID)
 								return
 							}
 						} else {
-							p.logger.Printf("Failed to get device %s (attempt %d): %v", deviceID, i+1, err)
+							p.logger.Warn("failed to get device", "device", deviceID, "attempt", i+1, "error", err)
 							if i < maxRetries-1 {
 								time.Sleep(1 * time.Second)
 								continue
 							}
 						}
 					}
-					p.logger.Printf("Failed to register device %s for OTA after %d attempts", deviceID, maxRetries)
+					p.logger.Error("failed to register device for OTA", "device", deviceID, "attempts", maxRetries)
 				}
 			}
 		}()
@@ -436,7 +463,7 @@ func (p *OTAPlugin) registerEventHandlers() {
 			if data, ok := evt.Data.(map[string]interface{}); ok {
 				if deviceID, ok := data["device_id"].(string); ok {
 					if err := p.UnregisterDevice(deviceID); err != nil {
-						p.logger.Printf("Failed to unregister device %s from OTA: %v", deviceID, err)
+						p.logger.Error("failed to unregister device from OTA", "device", deviceID, "error", err)
 					}
 				}
 			}
@@ -451,7 +478,7 @@ func (p *OTAPlugin) registerEventHandlers() {
 				if manager := p.GetManager(deviceID); manager != nil {
 					go func() {
 						if info, err := manager.CheckUpdate(); err == nil && info != nil {
-							p.logger.Printf("Update available for device %s: %s", deviceID, info.Version)
+							p.logger.Info("update available", "device", deviceID, "version", info.Version)
 						}
 					}()
 				}
@@ -467,7 +494,7 @@ func (p *OTAPlugin) registerEventHandlers() {
 					if info, ok := data["update_info"].(*UpdateInfo); ok {
 						go func() {
 							result, _ := manager.PerformUpdate(info)
-							p.logger.Printf("Update result for device %s: %v", deviceID, result)
+							p.logger.Info("update result", "device", deviceID, "success", result.Success, "message", result.Message)
 						}()
 					}
 				}
@@ -487,19 +514,21 @@ func (p *OTAPlugin) autoUpdateLoop() {
 	case <-time.After(initialDelay):
 		p.checkAllDevices()
 	case <-p.stopCh:
-		p.logger.Println("Auto-update loop stopped during initial delay")
+		p.logger.Debug("auto-update loop stopped during initial delay")
 		return
 	}
 	
-	ticker := time.NewTicker(p.checkInterval)
-	defer ticker.Stop()
-	
 	for {
+		// Re-read the interval so reconfiguration takes effect
+		p.mu.RLock()
+		timer := time.NewTimer(p.checkInterval)
+		p.mu.RUnlock()
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			p.checkAllDevices()
 		case <-p.stopCh:
-			p.logger.Println("Auto-update loop stopped")
+			timer.Stop()
+			p.logger.Debug("auto-update loop stopped")
 			return
 		}
 	}
@@ -517,16 +546,16 @@ func (p *OTAPlugin) checkAllDevices() {
 	for deviceID, manager := range managers {
 		if manager.GetStatus() == StatusIdle {
 			if info, err := manager.CheckUpdate(); err == nil && info != nil {
-				p.logger.Printf("Auto-update available for device %s: %s", deviceID, info.Version)
+				p.logger.Info("auto-update available", "device", deviceID, "version", info.Version)
 				if p.autoUpdate {
-					go func(m Manager, i *UpdateInfo) {
+					go func(deviceID string, m Manager, i *UpdateInfo) {
 						result, _ := m.PerformUpdate(i)
 						if result.Success {
-							p.logger.Printf("Auto-update successful for device %s", deviceID)
+							p.logger.Info("auto-update successful", "device", deviceID)
 						} else {
-							p.logger.Printf("Auto-update failed for device %s: %s", deviceID, result.Message)
+							p.logger.Error("auto-update failed", "device", deviceID, "message", result.Message)
 						}
-					}(manager, info)
+					}(deviceID, manager, info)
 				}
 			}
 		}
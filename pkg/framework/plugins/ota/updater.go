@@ -2,12 +2,13 @@ package ota
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"syscall"
+
+	"github.com/iot-go-sdk/pkg/logging"
 )
 
 // BinaryUpdater implements binary file update with self-replacement
@@ -15,26 +16,26 @@ type BinaryUpdater struct {
 	executablePath string
 	backupPath     string
 	tempPath       string
-	logger         *log.Logger
+	logger         logging.Logger
 }
 
 // NewBinaryUpdater creates a new binary updater
-func NewBinaryUpdater(logger *log.Logger) Updater {
+func NewBinaryUpdater(logger logging.Logger) Updater {
+	if logger == nil {
+		logger = logging.Discard()
+	}
+
 	// Get the path of the current executable
 	execPath, err := os.Executable()
 	if err != nil {
-		if logger != nil {
-			logger.Printf("Warning: Failed to get executable path: %v", err)
-		}
+		logger.Warn("failed to get executable path", "error", err)
 		execPath = "./app"
 	}
 	
 	// Resolve symbolic links to get the real path
 	execPath, err = filepath.EvalSymlinks(execPath)
 	if err != nil {
-		if logger != nil {
-			logger.Printf("Warning: Failed to resolve executable path: %v", err)
-		}
+		logger.Warn("failed to resolve executable path", "error", err)
 	}
 	
 	return &BinaryUpdater{
@@ -53,9 +54,7 @@ func (u *BinaryUpdater) CanUpdate() bool {
 	// Try to create a test file
 	testFile := filepath.Join(dir, ".ota_test")
 	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
-		if u.logger != nil {
-			u.logger.Printf("Cannot update: no write permission in %s", dir)
-		}
+		u.logger.Warn("cannot update: no write permission", "dir", dir)
 		return false
 	}
 	os.Remove(testFile)
@@ -75,18 +74,14 @@ func (u *BinaryUpdater) PrepareUpdate(data []byte) error {
 		return fmt.Errorf("failed to write new executable: %v", err)
 	}
 	
-	if u.logger != nil {
-		u.logger.Printf("New firmware saved to %s (%d bytes)", u.tempPath, len(data))
-	}
+	u.logger.Info("new firmware saved", "path", u.tempPath, "bytes", len(data))
 	
 	return nil
 }
 
 // ExecuteUpdate executes the update and restarts the process
 func (u *BinaryUpdater) ExecuteUpdate() error {
-	if u.logger != nil {
-		u.logger.Println("=== EXECUTING UPDATE ===")
-	}
+	u.logger.Info("executing update")
 	
 	// Platform-specific update
 	if runtime.GOOS == "windows" {
@@ -119,9 +114,7 @@ func (u *BinaryUpdater) Rollback() error {
 		}
 	}
 	
-	if u.logger != nil {
-		u.logger.Println("Rolled back to previous version")
-	}
+	u.logger.Info("rolled back to previous version")
 	
 	return nil
 }
@@ -142,9 +135,7 @@ func (u *BinaryUpdater) backupCurrentExecutable() error {
 		return fmt.Errorf("failed to write backup: %v", err)
 	}
 	
-	if u.logger != nil {
-		u.logger.Printf("Backed up current executable to %s", u.backupPath)
-	}
+	u.logger.Info("backed up current executable", "path", u.backupPath)
 	
 	return nil
 }
@@ -153,9 +144,7 @@ func (u *BinaryUpdater) backupCurrentExecutable() error {
 func (u *BinaryUpdater) executeUpdateUnix() error {
 	// Remove current executable (Unix allows this while running)
 	if err := os.Remove(u.executablePath); err != nil {
-		if u.logger != nil {
-			u.logger.Printf("Warning: Failed to remove old executable: %v", err)
-		}
+		u.logger.Warn("failed to remove old executable", "error", err)
 	}
 	
 	// Move new executable to the correct location
@@ -176,9 +165,7 @@ func (u *BinaryUpdater) executeUpdateUnix() error {
 	// Ensure executable permissions
 	os.Chmod(u.executablePath, 0755)
 	
-	if u.logger != nil {
-		u.logger.Println("=== RESTARTING WITH NEW VERSION ===")
-	}
+	u.logger.Info("restarting with new version")
 	
 	// Use syscall.Exec to replace the current process
 	return syscall.Exec(u.executablePath, os.Args, os.Environ())
@@ -202,9 +189,7 @@ del "%%~f0"
 		return fmt.Errorf("failed to create update script: %v", err)
 	}
 	
-	if u.logger != nil {
-		u.logger.Println("Starting update script...")
-	}
+	u.logger.Info("starting update script")
 	
 	// Execute the batch script
 	cmd := exec.Command("cmd", "/c", scriptPath)
@@ -221,11 +206,14 @@ del "%%~f0"
 type ConfigUpdater struct {
 	configPath string
 	backupPath string
-	logger     *log.Logger
+	logger     logging.Logger
 }
 
 // NewConfigUpdater creates a new configuration updater
-func NewConfigUpdater(configPath string, logger *log.Logger) Updater {
+func NewConfigUpdater(configPath string, logger logging.Logger) Updater {
+	if logger == nil {
+		logger = logging.Discard()
+	}
 	return &ConfigUpdater{
 		configPath: configPath,
 		backupPath: configPath + ".backup",
@@ -266,9 +254,7 @@ func (u *ConfigUpdater) PrepareUpdate(data []byte) error {
 		return fmt.Errorf("failed to write new config: %v", err)
 	}
 	
-	if u.logger != nil {
-		u.logger.Printf("Configuration updated: %s", u.configPath)
-	}
+	u.logger.Info("configuration updated", "path", u.configPath)
 	
 	return nil
 }
@@ -277,9 +263,7 @@ func (u *ConfigUpdater) PrepareUpdate(data []byte) error {
 func (u *ConfigUpdater) ExecuteUpdate() error {
 	// Configuration is already updated in PrepareUpdate
 	// This could trigger a configuration reload if needed
-	if u.logger != nil {
-		u.logger.Println("Configuration update applied successfully")
-	}
+	u.logger.Info("configuration update applied")
 	return nil
 }
 
@@ -298,9 +282,7 @@ func (u *ConfigUpdater) Rollback() error {
 		return fmt.Errorf("failed to restore backup: %v", err)
 	}
 	
-	if u.logger != nil {
-		u.logger.Println("Configuration rolled back to previous version")
-	}
+	u.logger.Info("configuration rolled back to previous version")
 	
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config describes where and how log entries are written. It mirrors
+// core.LoggingConfig.
+type Config struct {
+	// Level is debug, info (default), warn or error
+	Level string
+	// Format is text (default) or json
+	Format string
+	// Output is stdout, stderr (default) or a file path
+	Output string
+	// MaxSize (MB), MaxBackups and MaxAge (days) control file rotation
+	MaxSize    int
+	MaxBackups int
+	MaxAge     int
+	// LevelVar, when set, is initialised from Level and controls the
+	// level of the returned logger, so it can be changed at runtime
+	LevelVar *slog.LevelVar
+}
+
+// New builds a slog-backed Logger from cfg. The returned io.Closer closes
+// the log file, if any, and must be called on shutdown.
+func New(cfg Config) (Logger, io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		out    io.Writer = os.Stderr
+		closer io.Closer = nopCloser{}
+	)
+	switch strings.ToLower(cfg.Output) {
+	case "", "stderr":
+	case "stdout":
+		out = os.Stdout
+	default:
+		file, err := OpenRotatingFile(cfg.Output, cfg.MaxSize, cfg.MaxBackups, cfg.MaxAge)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = file, file
+	}
+
+	var leveler slog.Leveler = slog.Level(level)
+	if cfg.LevelVar != nil {
+		cfg.LevelVar.Set(slog.Level(level))
+		leveler = cfg.LevelVar
+	}
+	opts := &slog.HandlerOptions{Level: leveler}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+
+	return Redacting(NewSlog(slog.New(handler))), closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
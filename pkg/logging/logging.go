@@ -0,0 +1,111 @@
+// Package logging defines the structured logger used throughout the SDK.
+//
+// Every package logs through the Logger interface with a message, key/value
+// fields and the name of the component that produced the entry. The default
+// implementation is backed by log/slog; applications can plug in their own
+// logger with SetDefault or the SetLogger methods of individual clients.
+//
+// Loggers handed to the SDK are wrapped so that secrets (passwords, product
+// and device secrets, signatures, tokens), the signed part of MQTT client IDs
+// and configured payload fields are masked before they reach the underlying
+// logger. RedactionConfig.UnsafeDebug turns this off for local debugging.
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the severity of a log entry. The values match log/slog.
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+// ParseLevel converts "debug", "info", "warn" or "error" to a Level. An
+// empty string is treated as info.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %s", s)
+	}
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// Logger is a leveled, structured logger. keysAndValues are alternating
+// keys and values, e.g. logger.Info("published", "topic", topic, "qos", 1).
+type Logger interface {
+	Debug(msg string, keysAndValues ...any)
+	Info(msg string, keysAndValues ...any)
+	Warn(msg string, keysAndValues ...any)
+	Error(msg string, keysAndValues ...any)
+
+	// With returns a Logger that adds the given fields to every entry.
+	With(keysAndValues ...any) Logger
+	// Named returns a Logger for a sub-component. Names are joined with
+	// dots and reported in the "component" field.
+	Named(component string) Logger
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+// Default returns the process wide logger used by SDK components that have
+// not been given one explicitly. Until SetDefault is called it writes
+// through slog.Default. Entries are redacted, see SetRedaction.
+func Default() Logger {
+	if l := defaultLogger.Load(); l != nil {
+		return Redacting(*l)
+	}
+	return Redacting(NewSlog(nil))
+}
+
+// SetDefault replaces the logger returned by Default. Components created
+// afterwards pick it up.
+func SetDefault(l Logger) {
+	if l == nil {
+		defaultLogger.Store(nil)
+		return
+	}
+	defaultLogger.Store(&l)
+}
+
+// Discard returns a Logger that drops every entry.
+func Discard() Logger {
+	return discard{}
+}
+
+type discard struct{}
+
+func (discard) Debug(string, ...any)  {}
+func (discard) Info(string, ...any)   {}
+func (discard) Warn(string, ...any)   {}
+func (discard) Error(string, ...any)  {}
+func (d discard) With(...any) Logger  { return d }
+func (d discard) Named(string) Logger { return d }
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogLoggerFieldsAndComponent(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Named("mqtt").Named("queue").With("device", "dn1").Info("queued", "topic", "a/b")
+	logger.Debug("dropped")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{"msg": "queued", "component": "mqtt.queue", "device": "dn1", "topic": "a/b", "level": "INFO"}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+}
+
+func TestNewHonoursConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sdk.log")
+	logger, closer, err := New(Config{Level: "warn", Format: "json", Output: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "code", 1)
+	closer.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hidden") || !strings.Contains(string(data), `"msg":"shown"`) {
+		t.Fatalf("log file = %q", data)
+	}
+
+	if _, _, err := New(Config{Format: "xml"}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, _, err := New(Config{Level: "loud"}); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sdk.log")
+
+	r, err := OpenRotatingFile(path, 1, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	r.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	chunk := bytes.Repeat([]byte("x"), 600*1024)
+	for i := 0; i < 5; i++ {
+		if _, err := r.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "sdk-*.log"))
+	if len(matches) != 2 {
+		t.Fatalf("backups = %v, want 2", matches)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(chunk)) {
+		t.Errorf("current file size = %d", info.Size())
+	}
+}
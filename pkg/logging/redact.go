@@ -0,0 +1,217 @@
+package logging
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
+
+// Mask replaces redacted values.
+const Mask = "******"
+
+// sensitiveKeyNames mark a field as secret when its key is one of them or
+// ends with one as a separate word, ignoring case: sign, productSecret,
+// accessToken and mqtt_password are masked, signMethod, design and
+// tokenCount are not.
+var sensitiveKeyNames = []string{"secret", "secretkey", "password", "passwd", "token", "sign", "signature",
+	"credential", "credentials", "privatekey"}
+
+// payloadKeys are field keys whose values are message payloads; JSON
+// payloads are logged with their sensitive fields masked.
+var payloadKeys = map[string]bool{"payload": true}
+
+// RedactionConfig controls the redaction applied to every entry logged by
+// the SDK.
+type RedactionConfig struct {
+	// PayloadFields are additional JSON field names masked inside logged
+	// payloads, e.g. customer data such as "phone" or "location".
+	PayloadFields []string
+	// UnsafeDebug disables redaction so secrets and payloads are logged
+	// verbatim. It must never be enabled in production.
+	UnsafeDebug bool
+}
+
+type redactor struct {
+	payloadFields map[string]bool
+	unsafe        bool
+}
+
+var (
+	activeRedactor atomic.Pointer[redactor]
+	unsafeWarning  sync.Once
+)
+
+func init() {
+	activeRedactor.Store(&redactor{payloadFields: map[string]bool{}})
+}
+
+// SetRedaction replaces the process wide redaction settings.
+func SetRedaction(cfg RedactionConfig) {
+	r := &redactor{
+		payloadFields: make(map[string]bool, len(cfg.PayloadFields)),
+		unsafe:        cfg.UnsafeDebug,
+	}
+	for _, field := range cfg.PayloadFields {
+		r.payloadFields[strings.ToLower(field)] = true
+	}
+	activeRedactor.Store(r)
+
+	if cfg.UnsafeDebug {
+		unsafeWarning.Do(func() {
+			Default().Warn("UNSAFE DEBUG LOGGING ENABLED: secrets and payloads are logged without redaction")
+		})
+	}
+}
+
+// IsSensitiveKey reports whether values logged under key are masked.
+func IsSensitiveKey(key string) bool {
+	for _, name := range sensitiveKeyNames {
+		i := len(key) - len(name)
+		if i < 0 || !strings.EqualFold(key[i:], name) {
+			continue
+		}
+		// The name must start a word: the key itself, a camelCase word or
+		// one after a separator
+		if i == 0 || unicode.IsUpper(rune(key[i])) || strings.ContainsRune("_-.", rune(key[i-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactClientID keeps the "productKey.deviceName" part of an MQTT client
+// ID and masks the signed parameters after the first "|".
+func RedactClientID(clientID string) string {
+	if i := strings.Index(clientID, "|"); i >= 0 {
+		return clientID[:i] + "|" + Mask
+	}
+	return clientID
+}
+
+// RedactPayload returns payload with sensitive and configured JSON fields
+// masked. Payloads that are not JSON objects or arrays are returned as is.
+func RedactPayload(payload []byte) string {
+	return activeRedactor.Load().payload(payload)
+}
+
+// Redacting wraps l so that sensitive fields are masked before they reach
+// it. Wrapping an already redacting logger returns it unchanged.
+func Redacting(l Logger) Logger {
+	if _, ok := l.(*redactingLogger); ok {
+		return l
+	}
+	return &redactingLogger{next: l}
+}
+
+func (r *redactor) value(key string, value any) any {
+	lower := strings.ToLower(key)
+	switch {
+	case lower == "clientid":
+		if s, ok := value.(string); ok {
+			return RedactClientID(s)
+		}
+		return value
+	case IsSensitiveKey(key):
+		if s, ok := value.(string); ok && s == "" {
+			return s
+		}
+		return Mask
+	case payloadKeys[lower]:
+		switch v := value.(type) {
+		case string:
+			return r.payload([]byte(v))
+		case []byte:
+			return r.payload(v)
+		}
+	}
+	return value
+}
+
+func (r *redactor) payload(payload []byte) string {
+	if r.unsafe {
+		return string(payload)
+	}
+
+	var decoded any
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return string(payload)
+	}
+	switch decoded.(type) {
+	case map[string]any, []any:
+	default:
+		return string(payload)
+	}
+
+	data, err := json.Marshal(r.redactJSON(decoded))
+	if err != nil {
+		return string(payload)
+	}
+	return string(data)
+}
+
+func (r *redactor) redactJSON(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if IsSensitiveKey(key) || r.payloadFields[strings.ToLower(key)] {
+				value[key] = Mask
+				continue
+			}
+			value[key] = r.redactJSON(field)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = r.redactJSON(item)
+		}
+	}
+	return v
+}
+
+func (r *redactor) fields(keysAndValues []any) []any {
+	if r.unsafe || len(keysAndValues) == 0 {
+		return keysAndValues
+	}
+
+	redacted := make([]any, len(keysAndValues))
+	copy(redacted, keysAndValues)
+	for i := 0; i+1 < len(redacted); i += 2 {
+		if key, ok := redacted[i].(string); ok {
+			redacted[i+1] = r.value(key, redacted[i+1])
+		}
+	}
+	return redacted
+}
+
+type redactingLogger struct {
+	next Logger
+}
+
+func (l *redactingLogger) fields(keysAndValues []any) []any {
+	return activeRedactor.Load().fields(keysAndValues)
+}
+
+func (l *redactingLogger) Debug(msg string, keysAndValues ...any) {
+	l.next.Debug(msg, l.fields(keysAndValues)...)
+}
+
+func (l *redactingLogger) Info(msg string, keysAndValues ...any) {
+	l.next.Info(msg, l.fields(keysAndValues)...)
+}
+
+func (l *redactingLogger) Warn(msg string, keysAndValues ...any) {
+	l.next.Warn(msg, l.fields(keysAndValues)...)
+}
+
+func (l *redactingLogger) Error(msg string, keysAndValues ...any) {
+	l.next.Error(msg, l.fields(keysAndValues)...)
+}
+
+func (l *redactingLogger) With(keysAndValues ...any) Logger {
+	return &redactingLogger{next: l.next.With(l.fields(keysAndValues)...)}
+}
+
+func (l *redactingLogger) Named(component string) Logger {
+	return &redactingLogger{next: l.next.Named(component)}
+}
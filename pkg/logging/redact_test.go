@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newJSONLogger(buf *bytes.Buffer) Logger {
+	return Redacting(NewSlog(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+}
+
+func TestRedactingMasksSecrets(t *testing.T) {
+	defer SetRedaction(RedactionConfig{})
+
+	var buf bytes.Buffer
+	logger := newJSONLogger(&buf)
+
+	logger.With("deviceSecret", "ds-123").Named("dynreg").Debug("credentials",
+		"productSecret", "ps-456",
+		"password", "ABCDEF",
+		"clientId", "pk.dn|timestamp=1,_ss=1,securemode=2,signmethod=hmacsha256|",
+		"accessToken", "tok",
+		"username", "dn&pk",
+		"emptySecret", "")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"ds-123", "ps-456", "ABCDEF", "tok", "timestamp"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("log entry leaks %q: %s", secret, buf.String())
+		}
+	}
+	if entry["clientId"] != "pk.dn|"+Mask {
+		t.Errorf("clientId = %v", entry["clientId"])
+	}
+	if entry["username"] != "dn&pk" || entry["emptySecret"] != "" {
+		t.Errorf("non-secret fields changed: %v", entry)
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	tests := map[string]bool{
+		"sign":          true,
+		"Signature":     true,
+		"deviceSecret":  true,
+		"accessToken":   true,
+		"refresh_token": true,
+		"mqtt.password": true,
+		"PASSWORD":      true,
+		"privateKey":    true,
+		"signMethod":    false,
+		"design":        false,
+		"tokenCount":    false,
+		"assign":        false,
+		"username":      false,
+	}
+	for key, want := range tests {
+		if got := IsSensitiveKey(key); got != want {
+			t.Errorf("IsSensitiveKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRedactingPayloadFields(t *testing.T) {
+	SetRedaction(RedactionConfig{PayloadFields: []string{"Phone"}})
+	defer SetRedaction(RedactionConfig{})
+
+	var buf bytes.Buffer
+	logger := newJSONLogger(&buf)
+
+	logger.Debug("report", "payload", `{"id":"1","params":{"phone":"555","temp":21,"items":[{"token":"t1"}]}}`)
+	logger.Debug("raw", "payload", []byte("not json secret=1"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("entries = %q", buf.String())
+	}
+	if strings.Contains(lines[0], "555") || strings.Contains(lines[0], "t1") || !strings.Contains(lines[0], "21") {
+		t.Errorf("payload not redacted: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "not json secret=1") {
+		t.Errorf("non-JSON payload changed: %s", lines[1])
+	}
+}
+
+func TestUnsafeDebugDisablesRedaction(t *testing.T) {
+	SetDefault(Discard())
+	defer SetDefault(nil)
+	SetRedaction(RedactionConfig{UnsafeDebug: true, PayloadFields: []string{"phone"}})
+	defer SetRedaction(RedactionConfig{})
+
+	var buf bytes.Buffer
+	newJSONLogger(&buf).Debug("credentials", "password", "ABCDEF", "payload", `{"phone":"555"}`)
+
+	if !strings.Contains(buf.String(), "ABCDEF") || !strings.Contains(buf.String(), "555") {
+		t.Errorf("unsafe debug still redacts: %s", buf.String())
+	}
+}
+
+func TestRedactingIsIdempotent(t *testing.T) {
+	logger := Redacting(Discard())
+	if Redacting(logger) != logger {
+		t.Error("wrapping a redacting logger should return it unchanged")
+	}
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ComponentKey is the field holding the component name.
+const ComponentKey = "component"
+
+type slogLogger struct {
+	logger    *slog.Logger
+	component string
+}
+
+// NewSlog adapts a *slog.Logger to Logger. A nil logger uses slog.Default
+// at the time each entry is written.
+func NewSlog(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) slog() *slog.Logger {
+	if l.logger == nil {
+		return slog.Default()
+	}
+	return l.logger
+}
+
+func (l *slogLogger) log(level slog.Level, msg string, keysAndValues []any) {
+	logger := l.slog()
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	if l.component != "" {
+		keysAndValues = append([]any{ComponentKey, l.component}, keysAndValues...)
+	}
+	logger.Log(ctx, level, msg, keysAndValues...)
+}
+
+func (l *slogLogger) Debug(msg string, keysAndValues ...any) {
+	l.log(slog.LevelDebug, msg, keysAndValues)
+}
+
+func (l *slogLogger) Info(msg string, keysAndValues ...any) {
+	l.log(slog.LevelInfo, msg, keysAndValues)
+}
+
+func (l *slogLogger) Warn(msg string, keysAndValues ...any) {
+	l.log(slog.LevelWarn, msg, keysAndValues)
+}
+
+func (l *slogLogger) Error(msg string, keysAndValues ...any) {
+	l.log(slog.LevelError, msg, keysAndValues)
+}
+
+func (l *slogLogger) With(keysAndValues ...any) Logger {
+	return &slogLogger{logger: l.slog().With(keysAndValues...), component: l.component}
+}
+
+func (l *slogLogger) Named(component string) Logger {
+	if l.component != "" {
+		component = l.component + "." + component
+	}
+	return &slogLogger{logger: l.logger, component: component}
+}
@@ -1,40 +1,139 @@
 package mqtt
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/eclipse/paho.mqtt.golang"
 	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/clock"
 	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/logging"
 	tlsutil "github.com/iot-go-sdk/pkg/tls"
 )
 
 type MessageHandler func(topic string, payload []byte)
 
+// MessageHandlerV5 receives the full message including MQTT 5 properties.
+type MessageHandlerV5 func(msg *Message)
+
+// ResubscribeResult reports the outcome of restoring one subscription
+// after the client reconnected.
+type ResubscribeResult struct {
+	Topic string
+	QoS   byte
+	Err   error
+}
+
+// ResubscribeHandler is called after every reconnect with the result of
+// restoring each tracked subscription.
+type ResubscribeHandler func(results []ResubscribeResult)
+
+// QueueHandler is called with the offline queue backlog when the client
+// starts draining a backlog after a connect, and when draining stops
+// because the queue is empty or the connection dropped.
+type QueueHandler func(stats QueueStats)
+
+const (
+	// drainPublishTimeout bounds each publish of a queued message
+	drainPublishTimeout = 30 * time.Second
+	// maxDrainBackoff caps the delay between retries of a queued message
+	maxDrainBackoff = 30 * time.Second
+	// maxDrainAttempts is how often a queued message is tried while
+	// connected before it is dropped
+	maxDrainAttempts = 5
+)
+
+type subscription struct {
+	qos     byte
+	handler MessageHandlerV5
+}
+
 type Client struct {
-	config     *config.Config
-	mqttClient mqtt.Client
-	connected  bool
-	mutex      sync.RWMutex
-	handlers   map[string]MessageHandler
-	logger     *log.Logger
+	config    *config.Config
+	transport transport
+	connected bool
+	mutex     sync.RWMutex
+	handlers  map[string]*subscription
+	filters   []string
+	logger    logging.Logger
+
+	dispatchMode       DispatchMode
+	resubscribeHandler ResubscribeHandler
+
+	credentialProvider CredentialProvider
+	clock              clock.Clock
+
+	offlineQueue *OfflineQueue
+	dropPolicies map[string]DropPolicy
+	queueMutex   sync.Mutex
+	queueHandler QueueHandler
+	draining     bool
+	// drainBackoff is the delay before retrying a queued message that
+	// failed to publish; it doubles on every further failure
+	drainBackoff time.Duration
 }
 
 func NewClient(cfg *config.Config) *Client {
 	return &Client{
-		config:   cfg,
-		handlers: make(map[string]MessageHandler),
-		logger:   log.Default(),
+		config:       cfg,
+		handlers:     make(map[string]*subscription),
+		logger:       logging.Default().Named("mqtt"),
+		drainBackoff: time.Second,
 	}
 }
 
-func (c *Client) SetLogger(logger *log.Logger) {
-	c.logger = logger
+func (c *Client) SetLogger(logger logging.Logger) {
+	c.logger = logging.Redacting(logger)
+}
+
+// SetCredentialProvider replaces the source of CONNECT credentials. By
+// default they are signed with Config.Device.DeviceSecret.
+func (c *Client) SetCredentialProvider(provider CredentialProvider) {
+	c.mutex.Lock()
+	c.credentialProvider = provider
+	c.mutex.Unlock()
+}
+
+// SetClock sets the clock that timestamps the credentials signed from the
+// config and that the broker certificate must be valid at. nil, the
+// default, uses clock.Default().
+func (c *Client) SetClock(clk clock.Clock) {
+	c.mutex.Lock()
+	c.clock = clk
+	c.mutex.Unlock()
+}
+
+// SetOfflineQueue installs a queue that buffers publishes while the client
+// is disconnected. It overrides any queue configured via OfflineQueueConfig.
+func (c *Client) SetOfflineQueue(queue *OfflineQueue) {
+	c.queueMutex.Lock()
+	c.offlineQueue = queue
+	c.queueMutex.Unlock()
+}
+
+// SetQueueHandler registers a callback that receives the offline queue
+// backlog when draining starts and stops.
+func (c *Client) SetQueueHandler(handler QueueHandler) {
+	c.queueMutex.Lock()
+	c.queueHandler = handler
+	c.queueMutex.Unlock()
+}
+
+// QueueStats returns the offline queue backlog, or zero stats when no
+// queue is configured.
+func (c *Client) QueueStats() QueueStats {
+	c.queueMutex.Lock()
+	queue := c.offlineQueue
+	c.queueMutex.Unlock()
+
+	if queue == nil {
+		return QueueStats{}
+	}
+	return queue.Stats()
 }
 
 func (c *Client) Connect() error {
@@ -42,144 +141,196 @@ func (c *Client) Connect() error {
 		return fmt.Errorf("config validation failed: %w", err)
 	}
 
-	secureMode := c.config.GetSecureMode()
-	credentials := auth.GenerateMQTTCredentials(
-		c.config.Device.ProductKey,
-		c.config.Device.DeviceName,
-		c.config.Device.DeviceSecret,
-		secureMode,
-	)
+	if err := c.openOfflineQueue(); err != nil {
+		return err
+	}
 
-	// 打印生成的ClientID用于调试
-	c.logger.Printf("生成的Client ID: %s", credentials.ClientID)
+	credentials, err := c.credentials()
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
 
-	opts := mqtt.NewClientOptions()
+	// 打印生成的ClientID用于调试
+	c.logger.Debug("generated client ID", "clientId", credentials.ClientID)
 
-	broker := fmt.Sprintf("tcp://%s:%d", c.config.MQTT.Host, c.config.MQTT.Port)
+	var tlsConfig *tls.Config
 	if c.config.MQTT.UseTLS {
-		broker = fmt.Sprintf("ssl://%s:%d", c.config.MQTT.Host, c.config.MQTT.Port)
-
-		tlsConfig := &tls.Config{
-			InsecureSkipVerify: c.config.TLS.SkipVerify,
-			ServerName:         c.config.TLS.ServerName,
-		}
-
-		// If ServerName is set but SkipVerify is false, we still want to verify the certificate
-		// but ignore hostname mismatch (since we're connecting by IP)
-		if c.config.TLS.ServerName != "" && !c.config.TLS.SkipVerify {
-			tlsConfig.InsecureSkipVerify = true
-			// We'll manually verify the certificate chain using our custom CA
-		}
-
-		certPool, err := tlsutil.LoadCACert(c.config.TLS.CACert)
+		c.mutex.RLock()
+		clk := c.clock
+		c.mutex.RUnlock()
+		tlsConfig, err = tlsutil.NewConfigWithOptions(&c.config.TLS, c.config.MQTT.Host, tlsutil.ConfigOptions{Clock: clk})
 		if err != nil {
-			return fmt.Errorf("failed to load CA certificate: %w", err)
+			return err
 		}
-		tlsConfig.RootCAs = certPool
-
-		opts.SetTLSConfig(tlsConfig)
 	}
 
-	opts.AddBroker(broker)
-	opts.SetClientID(credentials.ClientID)
-	opts.SetUsername(credentials.Username)
-	opts.SetPassword(credentials.Password)
-	opts.SetKeepAlive(c.config.MQTT.KeepAlive)
-	opts.SetCleanSession(c.config.MQTT.CleanSession)
-	opts.SetAutoReconnect(true)
-	opts.SetMaxReconnectInterval(30 * time.Second)
-
-	opts.SetDefaultPublishHandler(c.defaultMessageHandler)
-	opts.SetConnectionLostHandler(c.connectionLostHandler)
-	opts.SetOnConnectHandler(c.onConnectHandler)
-	opts.SetReconnectingHandler(c.reconnectingHandler)
+	params, err := newConnectParams(c.config, tlsConfig)
+	if err != nil {
+		return err
+	}
+	params.clientID = credentials.ClientID
+	params.username = credentials.Username
+	params.password = credentials.Password
+	params.refreshCredentials = c.refreshCredentials
+	params.keepAlive = c.config.MQTT.KeepAlive
+	params.cleanSession = c.config.MQTT.CleanSession
+	params.sessionExpiry = c.config.MQTT.SessionExpiry
+	params.topicAliasMax = c.config.MQTT.TopicAliasMaximum
+
+	transport, err := newTransport(c.config.MQTT.ProtocolVersion, transportHandlers{
+		onMessage:        c.dispatchMessage,
+		onConnect:        c.onConnectHandler,
+		onConnectionLost: c.connectionLostHandler,
+		onReconnecting:   c.reconnectingHandler,
+		onConnectFailed:  c.connectFailedHandler,
+	})
+	if err != nil {
+		return err
+	}
 
-	c.mqttClient = mqtt.NewClient(opts)
+	c.mutex.Lock()
+	c.transport = transport
+	c.mutex.Unlock()
 
-	token := c.mqttClient.Connect()
-	if token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to connect: %w", token.Error())
+	if err := transport.connect(params); err != nil {
+		c.connectFailedHandler(err)
+		return fmt.Errorf("failed to connect: %w", err)
 	}
 
 	c.mutex.Lock()
 	c.connected = true
 	c.mutex.Unlock()
 
-	c.logger.Printf("Connected to MQTT broker: %s", broker)
+	c.logger.Info("connected to MQTT broker", "broker", params.brokerURL("tcp", "ssl").String(), "protocolVersion", c.config.MQTT.ProtocolVersion)
 	return nil
 }
 
+// Disconnect closes the connection. It also stops a transport that lost
+// its connection and is still trying to reconnect.
 func (c *Client) Disconnect() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if c.mqttClient != nil && c.connected {
-		c.mqttClient.Disconnect(250)
+	if c.transport == nil {
+		return
+	}
+	c.transport.disconnect()
+	if c.connected {
 		c.connected = false
-		c.logger.Println("Disconnected from MQTT broker")
+		c.logger.Info("disconnected from MQTT broker")
 	}
 }
 
 func (c *Client) IsConnected() bool {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	return c.connected && c.mqttClient.IsConnected()
+	return c.connected && c.transport.isConnected()
 }
 
+// Publish publishes a message and waits for it to be delivered. It blocks
+// until the broker acknowledges QoS 1 messages; use PublishContext to bound
+// the wait.
 func (c *Client) Publish(topic string, payload []byte, qos byte, retained bool) error {
+	return c.PublishContext(context.Background(), topic, payload, qos, retained)
+}
+
+// PublishContext is like Publish but gives up when ctx is cancelled or its
+// deadline passes. A message that was already handed to the broker may
+// still be delivered after ctx is done.
+func (c *Client) PublishContext(ctx context.Context, topic string, payload []byte, qos byte, retained bool) error {
+	_, err := c.publish(ctx, topic, payload, qos, retained, nil)
+	return err
+}
+
+// PublishWithProperties publishes a message with MQTT 5 properties such as
+// user properties, response topic and correlation data, or message expiry.
+// Properties are dropped when the client uses MQTT 3.1.1.
+func (c *Client) PublishWithProperties(topic string, payload []byte, qos byte, retained bool, props *PublishProperties) error {
+	_, err := c.publish(context.Background(), topic, payload, qos, retained, props)
+	return err
+}
+
+// PublishAsync starts publishing a message and returns immediately. The
+// returned Delivery completes once the broker acknowledges the message,
+// the message is stored in the offline queue, or publishing fails.
+func (c *Client) PublishAsync(ctx context.Context, topic string, payload []byte, qos byte, retained bool) *Delivery {
+	d := newDelivery(topic)
+	go func() {
+		start := time.Now()
+		queued, err := c.publish(ctx, topic, payload, qos, retained, nil)
+		d.complete(time.Since(start), queued, err)
+	}()
+	return d
+}
+
+// publish sends or queues a message. queued reports whether the message
+// went to the offline queue instead of the broker.
+func (c *Client) publish(ctx context.Context, topic string, payload []byte, qos byte, retained bool, props *PublishProperties) (queued bool, err error) {
+	c.queueMutex.Lock()
+	queue := c.offlineQueue
+	if queue != nil && (!c.IsConnected() || c.draining || queue.Len() > 0) {
+		// Keep publish order: while a backlog exists new messages go behind it
+		policy := c.dropPolicyFor(topic)
+		if policy != NeverQueue {
+			err := queue.EnqueueWithProperties(topic, payload, qos, retained, props, policy)
+			c.queueMutex.Unlock()
+			if err != nil {
+				return false, fmt.Errorf("failed to queue message: %w", err)
+			}
+			c.logger.Debug("queued message while offline", "topic", topic, "bytes", len(payload))
+			return true, nil
+		}
+	}
+	c.queueMutex.Unlock()
+
 	if !c.IsConnected() {
-		return fmt.Errorf("client is not connected")
+		return false, fmt.Errorf("client is not connected")
 	}
 
-	token := c.mqttClient.Publish(topic, qos, retained, payload)
-	if token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to publish message: %w", token.Error())
+	if err := c.transport.publish(ctx, topic, qos, retained, payload, props); err != nil {
+		return false, fmt.Errorf("failed to publish message: %w", err)
 	}
 
-	c.logger.Printf("Published message to topic: %s", topic)
-	return nil
+	c.logger.Debug("published message", "topic", topic, "qos", qos, "bytes", len(payload))
+	return false, nil
 }
 
 func (c *Client) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	return c.SubscribeV5(topic, qos, func(msg *Message) {
+		handler(msg.Topic, msg.Payload)
+	})
+}
+
+// SubscribeV5 subscribes with a handler that also receives the MQTT 5
+// properties of each message, e.g. to answer on its response topic.
+func (c *Client) SubscribeV5(topic string, qos byte, handler MessageHandlerV5) error {
 	if !c.IsConnected() {
 		return fmt.Errorf("client is not connected")
 	}
 
+	if err := ValidateTopicFilter(topic); err != nil {
+		return err
+	}
+
 	c.mutex.Lock()
-	c.handlers[topic] = handler
+	_, replaced := c.handlers[topic]
+	c.handlers[topic] = &subscription{qos: qos, handler: handler}
+	if !replaced {
+		c.filters = append(c.filters, topic)
+		sortFiltersBySpecificity(c.filters)
+	}
 	c.mutex.Unlock()
 
-	token := c.mqttClient.Subscribe(topic, qos, func(client mqtt.Client, msg mqtt.Message) {
-		c.mutex.RLock()
-		// First try exact match
-		if h, exists := c.handlers[msg.Topic()]; exists {
-			c.mutex.RUnlock()
-			h(msg.Topic(), msg.Payload())
-			return
+	if err := c.transport.subscribe(topic, qos); err != nil {
+		if !replaced {
+			c.mutex.Lock()
+			c.removeHandlerLocked(topic)
+			c.mutex.Unlock()
 		}
-
-		// Then try wildcard match for subscribed topics
-		for subscribedTopic, handler := range c.handlers {
-			if c.topicMatches(subscribedTopic, msg.Topic()) {
-				c.mutex.RUnlock()
-				handler(msg.Topic(), msg.Payload())
-				return
-			}
-		}
-		c.mutex.RUnlock()
-
-		// Log unhandled message
-		c.logger.Printf("No handler found for topic: %s, message: %s", msg.Topic(), string(msg.Payload()))
-	})
-
-	if token.Wait() && token.Error() != nil {
-		c.mutex.Lock()
-		delete(c.handlers, topic)
-		c.mutex.Unlock()
-		return fmt.Errorf("failed to subscribe to topic: %w", token.Error())
+		return fmt.Errorf("failed to subscribe to topic: %w", err)
 	}
 
-	c.logger.Printf("Subscribed to topic: %s", topic)
+	c.logger.Info("subscribed", "topic", topic, "qos", qos)
 	return nil
 }
 
@@ -188,60 +339,334 @@ func (c *Client) Unsubscribe(topic string) error {
 		return fmt.Errorf("client is not connected")
 	}
 
-	token := c.mqttClient.Unsubscribe(topic)
-	if token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to unsubscribe from topic: %w", token.Error())
+	if err := c.transport.unsubscribe(topic); err != nil {
+		return fmt.Errorf("failed to unsubscribe from topic: %w", err)
 	}
 
 	c.mutex.Lock()
-	delete(c.handlers, topic)
+	c.removeHandlerLocked(topic)
 	c.mutex.Unlock()
 
-	c.logger.Printf("Unsubscribed from topic: %s", topic)
+	c.logger.Info("unsubscribed", "topic", topic)
 	return nil
 }
 
-func (c *Client) topicMatches(pattern, topic string) bool {
-	// Simple MQTT topic matching for + wildcard
-	// Convert pattern with + to regex-like matching
-	if !strings.Contains(pattern, "+") {
-		return pattern == topic
-	}
+// SetDispatchMode selects whether a message is delivered to every matching
+// subscription (the default) or only to the most specific one.
+func (c *Client) SetDispatchMode(mode DispatchMode) {
+	c.mutex.Lock()
+	c.dispatchMode = mode
+	c.mutex.Unlock()
+}
 
-	patternParts := strings.Split(pattern, "/")
-	topicParts := strings.Split(topic, "/")
+// SetResubscribeHandler registers a callback that receives the per-topic
+// results of restoring subscriptions after a reconnect.
+func (c *Client) SetResubscribeHandler(handler ResubscribeHandler) {
+	c.mutex.Lock()
+	c.resubscribeHandler = handler
+	c.mutex.Unlock()
+}
 
-	if len(patternParts) != len(topicParts) {
-		return false
+// Subscriptions returns the tracked subscription filters, most specific first.
+func (c *Client) Subscriptions() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	filters := make([]string, len(c.filters))
+	copy(filters, c.filters)
+	return filters
+}
+
+func (c *Client) removeHandlerLocked(topic string) {
+	delete(c.handlers, topic)
+	for i, filter := range c.filters {
+		if filter == topic {
+			c.filters = append(c.filters[:i], c.filters[i+1:]...)
+			break
+		}
 	}
+}
+
+// matchingHandlers returns the handlers whose filter matches topic, most
+// specific filter first.
+func (c *Client) matchingHandlers(topic string) []MessageHandlerV5 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
-	for i, part := range patternParts {
-		if part != "+" && part != topicParts[i] {
-			return false
+	var matched []MessageHandlerV5
+	for _, filter := range c.filters {
+		if TopicMatches(filter, topic) {
+			matched = append(matched, c.handlers[filter].handler)
+			if c.dispatchMode == DispatchFirstMatch {
+				break
+			}
 		}
 	}
+	return matched
+}
 
-	return true
+func (c *Client) dispatchMessage(msg *Message) {
+	handlers := c.matchingHandlers(msg.Topic)
+	if len(handlers) == 0 {
+		c.defaultMessageHandler(msg)
+		return
+	}
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
 }
 
-func (c *Client) defaultMessageHandler(client mqtt.Client, msg mqtt.Message) {
-	c.logger.Printf("DEFAULT HANDLER - Received message on topic %s: %s", msg.Topic(), string(msg.Payload()))
+func (c *Client) defaultMessageHandler(msg *Message) {
+	c.logger.Warn("no handler found for topic", "topic", msg.Topic, "bytes", len(msg.Payload))
 }
 
-func (c *Client) connectionLostHandler(client mqtt.Client, err error) {
+func (c *Client) connectionLostHandler(err error) {
 	c.mutex.Lock()
 	c.connected = false
 	c.mutex.Unlock()
-	c.logger.Printf("Connection lost: %v", err)
+	c.logger.Warn("connection lost", "error", err)
 }
 
-func (c *Client) onConnectHandler(client mqtt.Client) {
+func (c *Client) onConnectHandler() {
 	c.mutex.Lock()
 	c.connected = true
 	c.mutex.Unlock()
-	c.logger.Println("Connected to MQTT broker")
+	c.logger.Info("connection established")
+	c.credentialsAccepted()
+
+	// With a clean session the broker has forgotten our subscriptions;
+	// restore them before draining queued messages that may expect replies
+	c.restoreSubscriptions()
+
+	c.queueMutex.Lock()
+	if c.offlineQueue != nil && !c.draining {
+		c.draining = true
+		go c.drainOfflineQueue(c.offlineQueue)
+	}
+	c.queueMutex.Unlock()
+}
+
+// restoreSubscriptions re-issues every tracked subscription and reports
+// the per-topic outcome to the resubscribe handler.
+func (c *Client) restoreSubscriptions() {
+	c.mutex.RLock()
+	transport := c.transport
+	filters := make([]string, len(c.filters))
+	copy(filters, c.filters)
+	qos := make(map[string]byte, len(filters))
+	for _, filter := range filters {
+		qos[filter] = c.handlers[filter].qos
+	}
+	handler := c.resubscribeHandler
+	c.mutex.RUnlock()
+
+	if len(filters) == 0 {
+		return
+	}
+
+	results := make([]ResubscribeResult, 0, len(filters))
+	failed := 0
+	for _, filter := range filters {
+		result := ResubscribeResult{Topic: filter, QoS: qos[filter]}
+
+		result.Err = transport.subscribe(filter, qos[filter])
+		if result.Err != nil {
+			failed++
+			c.logger.Error("failed to resubscribe", "topic", filter, "error", result.Err)
+		}
+		results = append(results, result)
+	}
+
+	c.logger.Info("restored subscriptions", "restored", len(filters)-failed, "total", len(filters))
+
+	if handler != nil {
+		handler(results)
+	}
+}
+
+// openOfflineQueue opens the queue described by OfflineQueueConfig, unless
+// one is already installed.
+func (c *Client) openOfflineQueue() error {
+	queueConfig := c.config.OfflineQueue
+
+	policies := make(map[string]DropPolicy, len(queueConfig.DropPolicies))
+	for filter, name := range queueConfig.DropPolicies {
+		policy, err := ParseDropPolicy(name)
+		if err != nil {
+			return fmt.Errorf("invalid offline queue policy for %s: %w", filter, err)
+		}
+		policies[filter] = policy
+	}
+
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+
+	c.dropPolicies = policies
+	if c.offlineQueue != nil || queueConfig.Dir == "" {
+		return nil
+	}
+
+	queue, err := OpenOfflineQueue(queueConfig.Dir, queueConfig.MaxBytes, queueConfig.MaxAge)
+	if err != nil {
+		return fmt.Errorf("failed to open offline queue: %w", err)
+	}
+	c.offlineQueue = queue
+
+	if stats := queue.Stats(); stats.Depth > 0 {
+		c.logger.Info("offline queue restored", "messages", stats.Depth, "bytes", stats.Bytes)
+	}
+	return nil
 }
 
-func (c *Client) reconnectingHandler(client mqtt.Client, opts *mqtt.ClientOptions) {
-	c.logger.Println("Attempting to reconnect to MQTT broker...")
+// dropPolicyFor returns the policy of the most specific filter matching
+// topic. Caller holds queueMutex.
+func (c *Client) dropPolicyFor(topic string) DropPolicy {
+	if policy, ok := c.dropPolicies[topic]; ok {
+		return policy
+	}
+
+	filters := make([]string, 0, len(c.dropPolicies))
+	for filter := range c.dropPolicies {
+		filters = append(filters, filter)
+	}
+	sortFiltersBySpecificity(filters)
+
+	for _, filter := range filters {
+		if TopicMatches(filter, topic) {
+			return c.dropPolicies[filter]
+		}
+	}
+	return DropOldest
+}
+
+// drainOfflineQueue publishes queued messages in order until the queue is
+// empty or the connection drops again. A message that fails to publish is
+// retried with exponential backoff while the client stays connected, so
+// one failure does not stall the queue until the next reconnect. A message
+// the broker refuses outright, or that still fails after maxDrainAttempts,
+// is dropped so it cannot hold up the messages behind it.
+func (c *Client) drainOfflineQueue(queue *OfflineQueue) {
+	backlog := queue.Len() > 0
+	if backlog {
+		c.reportQueue(queue)
+	}
+	delivered := 0
+	attempts := 0
+	backoff := c.drainBackoff
+	for {
+		c.queueMutex.Lock()
+		msg, err := queue.Peek()
+		if err != nil || msg == nil || !c.IsConnected() {
+			c.draining = false
+			c.queueMutex.Unlock()
+			if err != nil {
+				c.logger.Error("failed to read offline queue", "error", err)
+			}
+			if delivered > 0 {
+				c.logger.Info("drained offline queue", "messages", delivered, "remaining", queue.Len())
+			}
+			if backlog || delivered > 0 {
+				c.reportQueue(queue)
+			}
+			return
+		}
+		c.queueMutex.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), drainPublishTimeout)
+		err = c.transport.publish(ctx, msg.Topic, msg.QoS, msg.Retained, msg.Payload, msg.Properties)
+		cancel()
+		if err != nil && c.IsConnected() {
+			// Only failures while connected count towards dropping
+			if attempts++; attempts >= maxDrainAttempts || permanentPublishError(err) {
+				c.logger.Error("dropping queued message", "topic", msg.Topic, "attempts", attempts, "error", err)
+				queue.Drop(msg.Seq)
+				attempts = 0
+				backoff = c.drainBackoff
+				continue
+			}
+		}
+		if err != nil {
+			c.logger.Warn("failed to publish queued message, retrying", "topic", msg.Topic, "backoff", backoff, "error", err)
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxDrainBackoff {
+				backoff = maxDrainBackoff
+			}
+			continue
+		}
+
+		queue.Remove(msg.Seq)
+		delivered++
+		attempts = 0
+		backoff = c.drainBackoff
+	}
+}
+
+// permanentPublishError reports whether the broker refused a publish for a
+// reason that retrying cannot fix.
+func permanentPublishError(err error) bool {
+	var reasonErr *ReasonCodeError
+	if !errors.As(err, &reasonErr) {
+		return false
+	}
+	switch reasonErr.Code {
+	case 0x87, // not authorized
+		0x90, // topic name invalid
+		0x95, // packet too large
+		0x99: // payload format invalid
+		return true
+	}
+	return false
+}
+
+// reportQueue passes the backlog of queue to the queue handler.
+func (c *Client) reportQueue(queue *OfflineQueue) {
+	c.queueMutex.Lock()
+	handler := c.queueHandler
+	c.queueMutex.Unlock()
+	if handler != nil {
+		handler(queue.Stats())
+	}
+}
+
+func (c *Client) reconnectingHandler() {
+	c.logger.Info("reconnecting to MQTT broker")
+}
+
+func (c *Client) connectFailedHandler(err error) {
+	c.logger.Warn("connection attempt failed", "error", err)
+	if feedback, ok := c.currentCredentialProvider().(CredentialFeedback); ok {
+		feedback.CredentialsRejected(err)
+	}
+}
+
+func (c *Client) credentialsAccepted() {
+	if feedback, ok := c.currentCredentialProvider().(CredentialFeedback); ok {
+		feedback.CredentialsAccepted()
+	}
+}
+
+func (c *Client) currentCredentialProvider() CredentialProvider {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.credentialProvider == nil {
+		return newConfigCredentialProvider(c.config, c.clock)
+	}
+	return c.credentialProvider
+}
+
+func (c *Client) credentials() (*auth.Credentials, error) {
+	return c.currentCredentialProvider().Credentials()
+}
+
+// refreshCredentials is called by the transport before each reconnect. It
+// returns nil, keeping the previous credentials, when the provider fails.
+func (c *Client) refreshCredentials() *auth.Credentials {
+	credentials, err := c.credentials()
+	if err != nil {
+		c.logger.Error("failed to refresh credentials", "error", err)
+		return nil
+	}
+	return credentials
 }
@@ -0,0 +1,225 @@
+package mqtt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/clock"
+	"github.com/iot-go-sdk/pkg/config"
+)
+
+// CredentialProvider supplies the CONNECT credentials. The client asks it
+// for fresh credentials before the initial connect and before every
+// reconnect, so rotated secrets take effect without a restart.
+type CredentialProvider interface {
+	Credentials() (*auth.Credentials, error)
+}
+
+// CredentialFeedback is implemented by providers that want to know whether
+// the credentials they supplied were accepted by the broker. A failed
+// reconnect may also be caused by the network, so Rejected is a hint.
+type CredentialFeedback interface {
+	CredentialsAccepted()
+	CredentialsRejected(err error)
+}
+
+// CredentialProviderFunc adapts a function to CredentialProvider.
+type CredentialProviderFunc func() (*auth.Credentials, error)
+
+func (f CredentialProviderFunc) Credentials() (*auth.Credentials, error) {
+	return f()
+}
+
+// NewConfigCredentialProvider signs credentials with the device secret in
+// cfg, or with the key of DeviceConfig.DeviceKeyProvider, and returns X.509
+// credentials when the secure mode is x509. Without a device secret the
+// MQTT username and password issued by non-whitelist dynamic registration
+// are used as they are.
+func NewConfigCredentialProvider(cfg *config.Config) CredentialProvider {
+	return newConfigCredentialProvider(cfg, nil)
+}
+
+// newConfigCredentialProvider timestamps credentials with c, or with the
+// default clock when c is nil.
+func newConfigCredentialProvider(cfg *config.Config, c clock.Clock) CredentialProvider {
+	return CredentialProviderFunc(func() (*auth.Credentials, error) {
+		opts := cfg.MQTT.AuthOptions()
+		opts.Clock = c
+		secureMode := cfg.GetSecureMode()
+		if secureMode == config.SecureModeX509 {
+			// The client certificate identifies the device; no password to sign
+			now := clock.Now()
+			if c != nil {
+				now = c.Now()
+			}
+			return auth.GenerateX509CredentialsAt(cfg.Device.ProductKey, cfg.Device.DeviceName, secureMode, now), nil
+		}
+		key := cfg.Device.DeviceKeyProvider()
+		if key == nil {
+			if cfg.MQTT.Password != "" {
+				return &auth.Credentials{
+					ClientID: cfg.GenerateClientID(),
+					Username: cfg.MQTT.Username,
+					Password: cfg.MQTT.Password,
+				}, nil
+			}
+			return nil, fmt.Errorf("device secret is required")
+		}
+		return auth.GenerateCredentialsWithKey(cfg.Device.ProductKey, cfg.Device.DeviceName, secureMode, opts, key)
+	})
+}
+
+// FileSecretProvider signs credentials with a device secret read from a
+// file. The file is re-read whenever it changes, so a secret rotated on disk
+// is used from the next connect on. Until a new secret has been accepted
+// the previous one is kept (in memory and in path+".prev") as a fallback:
+// after a failed attempt the other secret is tried.
+type FileSecretProvider struct {
+	path       string
+	productKey string
+	deviceName string
+	secureMode string
+	options    auth.Options
+
+	mutex       sync.Mutex
+	modTime     time.Time
+	size        int64
+	current     string
+	previous    string
+	usePrevious bool
+}
+
+// NewFileSecretProvider reads the device secret from path.
+func NewFileSecretProvider(path, productKey, deviceName, secureMode string) (*FileSecretProvider, error) {
+	p := &FileSecretProvider{
+		path:       path,
+		productKey: productKey,
+		deviceName: deviceName,
+		secureMode: secureMode,
+	}
+
+	if err := p.reloadLocked(); err != nil {
+		return nil, err
+	}
+	if data, err := os.ReadFile(p.previousPath()); err == nil {
+		p.previous = strings.TrimSpace(string(data))
+	}
+	return p, nil
+}
+
+// Credentials signs credentials with the current secret, or with the
+// previous one after the current secret was rejected.
+func (p *FileSecretProvider) Credentials() (*auth.Credentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.reloadLocked(); err != nil {
+		return nil, err
+	}
+
+	secret := p.current
+	if p.usePrevious && p.previous != "" {
+		secret = p.previous
+	}
+	return auth.GenerateCredentials(p.productKey, p.deviceName, secret, p.secureMode, p.options)
+}
+
+// SetAuthOptions selects the sign method and credential layout, e.g.
+// from MQTTConfig.AuthOptions.
+func (p *FileSecretProvider) SetAuthOptions(opts auth.Options) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.options = opts
+}
+
+// CredentialsAccepted drops the fallback secret once the current one has
+// been accepted.
+func (p *FileSecretProvider) CredentialsAccepted() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.usePrevious {
+		// The platform has not switched yet; retry the new secret next time
+		p.usePrevious = false
+		return
+	}
+	if p.previous != "" {
+		p.previous = ""
+		os.Remove(p.previousPath())
+	}
+}
+
+// CredentialsRejected switches to the other secret for the next attempt.
+func (p *FileSecretProvider) CredentialsRejected(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.previous != "" {
+		p.usePrevious = !p.usePrevious
+	}
+}
+
+// Rotate persists a new secret atomically, keeping the current one as the
+// fallback until the new secret has been accepted.
+func (p *FileSecretProvider) Rotate(secret string) error {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return fmt.Errorf("device secret is empty")
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if secret == p.current {
+		return nil
+	}
+	if err := writeFileAtomic(p.previousPath(), []byte(p.current)); err != nil {
+		return fmt.Errorf("failed to save previous device secret: %w", err)
+	}
+	if err := writeFileAtomic(p.path, []byte(secret)); err != nil {
+		return fmt.Errorf("failed to save device secret: %w", err)
+	}
+
+	p.previous = p.current
+	p.usePrevious = false
+	return p.reloadLocked()
+}
+
+// reloadLocked re-reads the secret file when its size or modification time
+// changed. A secret replaced on disk becomes current and the old one the
+// fallback.
+func (p *FileSecretProvider) reloadLocked() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read device secret: %w", err)
+	}
+	if info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read device secret: %w", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return fmt.Errorf("device secret file %s is empty", p.path)
+	}
+
+	if p.current != "" && secret != p.current && secret != p.previous {
+		p.previous = p.current
+		p.usePrevious = false
+	}
+	p.current = secret
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	return nil
+}
+
+func (p *FileSecretProvider) previousPath() string {
+	return p.path + ".prev"
+}
@@ -0,0 +1,154 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/auth"
+)
+
+// signedWith reports whether the provider's next credentials are signed
+// with secret.
+func signedWith(t *testing.T, provider *FileSecretProvider, secret string) bool {
+	t.Helper()
+	credentials, err := provider.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature covers the millisecond timestamp in the client ID
+	timestamp := strings.SplitN(strings.SplitN(credentials.ClientID, "timestamp=", 2)[1], ",", 2)[0]
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := auth.GenerateMQTTCredentialsAt("pk", "dn", secret, "3", time.UnixMilli(ms))
+	return credentials.Password == expected.Password
+}
+
+func TestFileSecretProviderReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device.secret")
+	if err := os.WriteFile(path, []byte("old-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewFileSecretProvider(path, "pk", "dn", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signedWith(t, provider, "old-secret") {
+		t.Fatal("initial credentials not signed with the file secret")
+	}
+
+	if err := os.WriteFile(path, []byte("new-secret-from-ops"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !signedWith(t, provider, "new-secret-from-ops") {
+		t.Fatal("rotated file secret not picked up")
+	}
+
+	if _, err := NewFileSecretProvider(filepath.Join(t.TempDir(), "missing"), "pk", "dn", "3"); err == nil {
+		t.Fatal("expected error for missing secret file")
+	}
+}
+
+func TestFileSecretProviderRotateFallsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device.secret")
+	if err := os.WriteFile(path, []byte("old-secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewFileSecretProvider(path, "pk", "dn", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.Rotate("new-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new-secret" {
+		t.Fatalf("secret file = %q", data)
+	}
+	if data, _ := os.ReadFile(path + ".prev"); string(data) != "old-secret" {
+		t.Fatalf("previous secret file = %q", data)
+	}
+
+	// A restart keeps the fallback
+	provider, err = NewFileSecretProvider(path, "pk", "dn", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signedWith(t, provider, "new-secret") {
+		t.Fatal("rotated secret not used first")
+	}
+
+	provider.CredentialsRejected(errors.New("bad user name or password"))
+	if !signedWith(t, provider, "old-secret") {
+		t.Fatal("rejected secret did not fall back to the previous one")
+	}
+
+	// Accepting the old secret means the platform has not switched yet
+	provider.CredentialsAccepted()
+	if !signedWith(t, provider, "new-secret") {
+		t.Fatal("new secret not retried after the old one was accepted")
+	}
+
+	provider.CredentialsAccepted()
+	if _, err := os.Stat(path + ".prev"); !os.IsNotExist(err) {
+		t.Fatalf("previous secret kept after new one was accepted: %v", err)
+	}
+	provider.CredentialsRejected(errors.New("network down"))
+	if !signedWith(t, provider, "new-secret") {
+		t.Fatal("fell back after the previous secret was dropped")
+	}
+}
+
+// recordingTransport records publishes.
+type recordingTransport struct {
+	blockingTransport
+	published chan []byte
+}
+
+func (t *recordingTransport) publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte, props *PublishProperties) error {
+	t.published <- payload
+	return nil
+}
+
+func TestSecretRotationPersistsAndAcknowledges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device.secret")
+	if err := os.WriteFile(path, []byte("old-secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewFileSecretProvider(path, "pk", "dn", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &recordingTransport{published: make(chan []byte, 1)}
+	client := newTestClient(tr)
+	rotation := NewSecretRotation(client, provider, "pk", "dn")
+	if err := rotation.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.dispatchMessage(&Message{
+		Topic:   "/sys/pk/dn/thing/secret/rotate",
+		Payload: []byte(`{"id":"42","version":"1.0","params":{"deviceSecret":"new-secret"}}`),
+	})
+
+	var reply secretRotateReply
+	if err := json.Unmarshal(<-tr.published, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.ID != "42" || reply.Code != 200 {
+		t.Fatalf("reply = %+v", reply)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new-secret" {
+		t.Fatalf("secret file = %q", data)
+	}
+}
@@ -0,0 +1,78 @@
+package mqtt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Delivery tracks a message published with PublishAsync.
+type Delivery struct {
+	topic string
+	done  chan struct{}
+
+	mutex   sync.Mutex
+	err     error
+	latency time.Duration
+	queued  bool
+}
+
+func newDelivery(topic string) *Delivery {
+	return &Delivery{
+		topic: topic,
+		done:  make(chan struct{}),
+	}
+}
+
+func (d *Delivery) complete(latency time.Duration, queued bool, err error) {
+	d.mutex.Lock()
+	d.latency = latency
+	d.queued = queued
+	d.err = err
+	d.mutex.Unlock()
+	close(d.done)
+}
+
+// Topic returns the topic the message was published to.
+func (d *Delivery) Topic() string {
+	return d.topic
+}
+
+// Done is closed once the delivery has completed.
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Wait blocks until the delivery completes and returns its final error, or
+// returns ctx.Err() if ctx is done first.
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		return d.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err returns the final error, or nil while the delivery is pending.
+func (d *Delivery) Err() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.err
+}
+
+// Latency returns the time from publish until the broker acknowledged the
+// message (or it was written, for QoS 0). It is zero while pending.
+func (d *Delivery) Latency() time.Duration {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.latency
+}
+
+// Queued reports whether the message was stored in the offline queue
+// instead of being sent; it will be published after reconnecting.
+func (d *Delivery) Queued() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.queued
+}
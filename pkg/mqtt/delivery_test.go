@@ -0,0 +1,89 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingTransport acks publishes only when release is closed.
+type blockingTransport struct {
+	release chan struct{}
+}
+
+func (t *blockingTransport) connect(params *connectParams) error { return nil }
+func (t *blockingTransport) disconnect()                         {}
+func (t *blockingTransport) isConnected() bool                   { return true }
+func (t *blockingTransport) subscribe(topic string, qos byte) error {
+	return nil
+}
+func (t *blockingTransport) unsubscribe(topic string) error { return nil }
+
+func (t *blockingTransport) publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte, props *PublishProperties) error {
+	select {
+	case <-t.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newTestClient(tr transport) *Client {
+	client := NewClient(nil)
+	client.transport = tr
+	client.connected = true
+	return client
+}
+
+func TestPublishContextDeadline(t *testing.T) {
+	client := newTestClient(&blockingTransport{release: make(chan struct{})})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := client.PublishContext(ctx, "a/b", []byte("x"), 1, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PublishContext error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestPublishAsyncDelivery(t *testing.T) {
+	tr := &blockingTransport{release: make(chan struct{})}
+	client := newTestClient(tr)
+
+	d := client.PublishAsync(context.Background(), "a/b", []byte("x"), 1, false)
+	select {
+	case <-d.Done():
+		t.Fatal("delivery completed before ack")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(tr.release)
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if d.Latency() < 10*time.Millisecond {
+		t.Errorf("Latency = %v, want at least 10ms", d.Latency())
+	}
+	if d.Queued() {
+		t.Error("delivery reported as queued")
+	}
+}
+
+func TestPublishAsyncQueuedWhileOffline(t *testing.T) {
+	client := NewClient(nil)
+	queue, err := OpenOfflineQueue(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetOfflineQueue(queue)
+
+	d := client.PublishAsync(context.Background(), "a/b", []byte("x"), 1, false)
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if !d.Queued() || queue.Len() != 1 {
+		t.Fatalf("Queued = %v, queue length = %d", d.Queued(), queue.Len())
+	}
+}
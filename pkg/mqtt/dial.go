@@ -0,0 +1,138 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/proxy"
+)
+
+// newConnectParams fills the network side of connectParams (broker address,
+// TLS, WebSocket and proxy) from cfg.
+func newConnectParams(cfg *config.Config, tlsConfig *tls.Config) (*connectParams, error) {
+	params := &connectParams{
+		host:      cfg.MQTT.Host,
+		port:      cfg.MQTT.Port,
+		tlsConfig: tlsConfig,
+		websocket: cfg.MQTT.IsWebSocket(),
+	}
+
+	// Without TLS "wss" would silently connect over plain ws://
+	if cfg.MQTT.Transport == "wss" && tlsConfig == nil {
+		return nil, fmt.Errorf("MQTT transport wss requires TLS")
+	}
+	if params.websocket {
+		params.websocketPath = cfg.MQTT.WebSocketPath
+		if params.websocketPath == "" {
+			params.websocketPath = "/mqtt"
+		} else if !strings.HasPrefix(params.websocketPath, "/") {
+			params.websocketPath = "/" + params.websocketPath
+		}
+	}
+
+	dialer, err := proxy.New(cfg.MQTT.Proxy.URL, cfg.MQTT.Proxy.Username, cfg.MQTT.Proxy.Password)
+	if err != nil {
+		return nil, err
+	}
+	params.proxy = dialer
+
+	return params, nil
+}
+
+// brokerURL returns the broker URL. tcpScheme and tlsScheme differ between
+// the paho clients ("tcp"/"ssl" vs "mqtt"/"tls").
+func (p *connectParams) brokerURL(tcpScheme, tlsScheme string) *url.URL {
+	u := &url.URL{
+		Scheme: tcpScheme,
+		Host:   net.JoinHostPort(p.host, strconv.Itoa(p.port)),
+	}
+	if p.websocket {
+		u.Scheme = "ws"
+		u.Path = p.websocketPath
+	}
+	if p.tlsConfig != nil {
+		u.Scheme = tlsScheme
+		if p.websocket {
+			u.Scheme = "wss"
+		}
+	}
+	return u
+}
+
+// dialProxy opens the raw TCP (or TLS) connection to the broker through
+// the configured proxy. WebSocket connections use the proxy through the
+// websocket dialer instead.
+func (p *connectParams) dialProxy(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(p.host, strconv.Itoa(p.port))
+	conn, err := p.proxy.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if p.tlsConfig == nil {
+		return conn, nil
+	}
+
+	tlsConfig := p.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = p.host
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// websocketProxy returns the proxy function for websocket dialers.
+func (p *connectParams) websocketProxy() func(*http.Request) (*url.URL, error) {
+	if p.proxy == nil {
+		return nil
+	}
+	proxyURL := p.proxy.URL()
+	return func(*http.Request) (*url.URL, error) {
+		return proxyURL, nil
+	}
+}
+
+// applyTransportOptions sets broker URL, TLS, WebSocket and proxy options
+// on paho MQTT 3.1.1 client options and returns the broker URL.
+func (p *connectParams) applyTransportOptions(opts *mqtt.ClientOptions) string {
+	broker := p.brokerURL("tcp", "ssl").String()
+	opts.AddBroker(broker)
+
+	if p.tlsConfig != nil {
+		opts.SetTLSConfig(p.tlsConfig)
+	}
+
+	if p.websocket {
+		opts.SetWebsocketOptions(&mqtt.WebsocketOptions{Proxy: p.websocketProxy()})
+	} else if p.proxy != nil {
+		opts.SetCustomOpenConnectionFn(func(uri *url.URL, options mqtt.ClientOptions) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), options.ConnectTimeout)
+			defer cancel()
+			return p.dialProxy(ctx)
+		})
+	}
+	return broker
+}
+
+// ConfigureTransport applies the transport settings in cfg (TCP/SSL or
+// WebSocket, path, HTTP CONNECT or SOCKS5 proxy) to paho MQTT 3.1.1 client
+// options. It is used by clients such as dynamic registration that build
+// their own options. It returns the broker URL.
+func ConfigureTransport(opts *mqtt.ClientOptions, cfg *config.Config, tlsConfig *tls.Config) (string, error) {
+	params, err := newConnectParams(cfg, tlsConfig)
+	if err != nil {
+		return "", err
+	}
+	return params.applyTransportOptions(opts), nil
+}
@@ -0,0 +1,330 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DropPolicy decides what happens to an outbound message published while
+// the client is offline.
+type DropPolicy int
+
+const (
+	// DropOldest queues the message and evicts the oldest entries when the
+	// queue exceeds its byte budget.
+	DropOldest DropPolicy = iota
+	// DropNewest rejects the new message when the queue is full.
+	DropNewest
+	// NeverQueue skips the queue entirely; Publish fails while offline.
+	NeverQueue
+)
+
+// ParseDropPolicy converts the config representation of a drop policy.
+func ParseDropPolicy(s string) (DropPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "drop-oldest":
+		return DropOldest, nil
+	case "drop-newest":
+		return DropNewest, nil
+	case "never-queue":
+		return NeverQueue, nil
+	default:
+		return DropOldest, fmt.Errorf("unknown drop policy: %s", s)
+	}
+}
+
+func (p DropPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	case NeverQueue:
+		return "never-queue"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrQueueFull is returned when a DropNewest message does not fit.
+var ErrQueueFull = errors.New("offline queue is full")
+
+// QueuedMessage is a message persisted in the offline queue.
+type QueuedMessage struct {
+	Seq      uint64    `json:"seq"`
+	Topic    string    `json:"topic"`
+	Payload  []byte    `json:"payload"`
+	QoS      byte      `json:"qos"`
+	Retained bool      `json:"retained"`
+	Enqueued time.Time `json:"enqueued"`
+	// Properties are the MQTT 5 publish properties, if any
+	Properties *PublishProperties `json:"properties,omitempty"`
+}
+
+// QueueStats describes the current backlog of the offline queue.
+type QueueStats struct {
+	Depth     int
+	Bytes     int64
+	OldestAge time.Duration
+	Dropped   uint64
+}
+
+type queueEntry struct {
+	seq      uint64
+	size     int64
+	enqueued time.Time
+}
+
+// OfflineQueue is a disk-backed FIFO of outbound messages. Every message is
+// stored in its own file named after its sequence number, so the queue
+// survives process restarts and drains in publish order.
+type OfflineQueue struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	mutex    sync.Mutex
+	entries  []queueEntry
+	bytes    int64
+	nextSeq  uint64
+	dropped  uint64
+	now      func() time.Time
+}
+
+const queueFileSuffix = ".msg"
+
+// OpenOfflineQueue opens (or creates) the queue stored in dir. A zero
+// maxBytes or maxAge disables the corresponding bound.
+func OpenOfflineQueue(dir string, maxBytes int64, maxAge time.Duration) (*OfflineQueue, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("offline queue directory is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create offline queue directory: %w", err)
+	}
+
+	q := &OfflineQueue{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		nextSeq:  1,
+		now:      time.Now,
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offline queue directory: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, queueFileSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, queueFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		msg, size, err := q.readMessage(seq)
+		if err != nil {
+			// A torn write from a crash; drop it rather than block the queue
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		q.entries = append(q.entries, queueEntry{seq: seq, size: size, enqueued: msg.Enqueued})
+		q.bytes += size
+		if seq >= q.nextSeq {
+			q.nextSeq = seq + 1
+		}
+	}
+
+	sort.Slice(q.entries, func(i, j int) bool {
+		return q.entries[i].seq < q.entries[j].seq
+	})
+
+	q.mutex.Lock()
+	q.pruneExpiredLocked()
+	q.mutex.Unlock()
+
+	return q, nil
+}
+
+// Enqueue appends a message to the queue, applying the given drop policy
+// when the queue is over its byte budget.
+func (q *OfflineQueue) Enqueue(topic string, payload []byte, qos byte, retained bool, policy DropPolicy) error {
+	return q.EnqueueWithProperties(topic, payload, qos, retained, nil, policy)
+}
+
+// EnqueueWithProperties is like Enqueue but also persists MQTT 5 publish
+// properties so they are sent when the message is drained.
+func (q *OfflineQueue) EnqueueWithProperties(topic string, payload []byte, qos byte, retained bool, props *PublishProperties, policy DropPolicy) error {
+	if policy == NeverQueue {
+		return fmt.Errorf("topic %s is not queued while offline", topic)
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.pruneExpiredLocked()
+
+	msg := QueuedMessage{
+		Seq:        q.nextSeq,
+		Topic:      topic,
+		Payload:    payload,
+		QoS:        qos,
+		Retained:   retained,
+		Enqueued:   q.now(),
+		Properties: props,
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued message: %w", err)
+	}
+	size := int64(len(data))
+
+	if q.maxBytes > 0 {
+		if size > q.maxBytes {
+			q.dropped++
+			return ErrQueueFull
+		}
+		if policy == DropNewest && q.bytes+size > q.maxBytes {
+			q.dropped++
+			return ErrQueueFull
+		}
+		for q.bytes+size > q.maxBytes && len(q.entries) > 0 {
+			q.removeLocked(0)
+			q.dropped++
+		}
+	}
+
+	if err := writeFileAtomic(q.path(msg.Seq), data); err != nil {
+		return fmt.Errorf("failed to persist queued message: %w", err)
+	}
+
+	q.entries = append(q.entries, queueEntry{seq: msg.Seq, size: size, enqueued: msg.Enqueued})
+	q.bytes += size
+	q.nextSeq++
+	return nil
+}
+
+// Peek returns the oldest unexpired message without removing it, or nil if
+// the queue is empty.
+func (q *OfflineQueue) Peek() (*QueuedMessage, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.pruneExpiredLocked()
+
+	for len(q.entries) > 0 {
+		msg, _, err := q.readMessage(q.entries[0].seq)
+		if err == nil {
+			return msg, nil
+		}
+		// Unreadable entry, skip it so it can't wedge the queue
+		q.removeLocked(0)
+		q.dropped++
+	}
+	return nil, nil
+}
+
+// Remove deletes a message after it has been delivered.
+func (q *OfflineQueue) Remove(seq uint64) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, entry := range q.entries {
+		if entry.seq == seq {
+			q.removeLocked(i)
+			return nil
+		}
+	}
+	return nil
+}
+
+// Drop deletes a message that cannot be delivered and counts it as
+// dropped.
+func (q *OfflineQueue) Drop(seq uint64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, entry := range q.entries {
+		if entry.seq == seq {
+			q.removeLocked(i)
+			q.dropped++
+			return
+		}
+	}
+}
+
+// Len returns the number of queued messages.
+func (q *OfflineQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.entries)
+}
+
+// Stats returns depth, size and age information for the queue.
+func (q *OfflineQueue) Stats() QueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	stats := QueueStats{
+		Depth:   len(q.entries),
+		Bytes:   q.bytes,
+		Dropped: q.dropped,
+	}
+	if len(q.entries) > 0 {
+		stats.OldestAge = q.now().Sub(q.entries[0].enqueued)
+	}
+	return stats
+}
+
+func (q *OfflineQueue) pruneExpiredLocked() {
+	if q.maxAge <= 0 {
+		return
+	}
+	cutoff := q.now().Add(-q.maxAge)
+	for len(q.entries) > 0 && q.entries[0].enqueued.Before(cutoff) {
+		q.removeLocked(0)
+		q.dropped++
+	}
+}
+
+func (q *OfflineQueue) removeLocked(i int) {
+	entry := q.entries[i]
+	os.Remove(q.path(entry.seq))
+	q.bytes -= entry.size
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+}
+
+func (q *OfflineQueue) readMessage(seq uint64) (*QueuedMessage, int64, error) {
+	data, err := os.ReadFile(q.path(seq))
+	if err != nil {
+		return nil, 0, err
+	}
+	var msg QueuedMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, 0, err
+	}
+	return &msg, int64(len(data)), nil
+}
+
+func (q *OfflineQueue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, queueFileSuffix))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,228 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOfflineQueueSurvivesReopenInOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	queue, err := OpenOfflineQueue(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	for _, topic := range []string{"a", "b", "c"} {
+		if err := queue.Enqueue(topic, []byte(topic), 1, false, DropOldest); err != nil {
+			t.Fatalf("enqueue %s: %v", topic, err)
+		}
+	}
+
+	reopened, err := OpenOfflineQueue(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("reopen queue: %v", err)
+	}
+	if reopened.Len() != 3 {
+		t.Fatalf("len = %d", reopened.Len())
+	}
+
+	for _, want := range []string{"a", "b", "c"} {
+		msg, err := reopened.Peek()
+		if err != nil || msg == nil {
+			t.Fatalf("peek: %v, %v", msg, err)
+		}
+		if msg.Topic != want || string(msg.Payload) != want || msg.QoS != 1 {
+			t.Fatalf("msg = %+v, want topic %s", msg, want)
+		}
+		reopened.Remove(msg.Seq)
+	}
+
+	// New sequence numbers must continue after the restored ones
+	if err := reopened.Enqueue("d", nil, 0, false, DropOldest); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	msg, _ := reopened.Peek()
+	if msg.Seq != 4 {
+		t.Fatalf("seq = %d", msg.Seq)
+	}
+}
+
+func TestOfflineQueueDropPolicies(t *testing.T) {
+	queue, err := OpenOfflineQueue(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	if err := queue.Enqueue("first", make([]byte, 64), 0, false, DropOldest); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	queue.maxBytes = queue.Stats().Bytes + 16
+
+	if err := queue.Enqueue("second", make([]byte, 64), 0, false, DropNewest); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("drop-newest err = %v", err)
+	}
+	if err := queue.Enqueue("second", make([]byte, 64), 0, false, DropOldest); err != nil {
+		t.Fatalf("drop-oldest err = %v", err)
+	}
+	if err := queue.Enqueue("third", nil, 0, false, NeverQueue); err == nil {
+		t.Fatal("never-queue accepted message")
+	}
+
+	stats := queue.Stats()
+	if stats.Depth != 1 || stats.Dropped != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	msg, _ := queue.Peek()
+	if msg.Topic != "second" {
+		t.Fatalf("topic = %s", msg.Topic)
+	}
+}
+
+func TestOfflineQueueExpiresOldMessages(t *testing.T) {
+	queue, err := OpenOfflineQueue(t.TempDir(), 0, time.Minute)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	queue.now = func() time.Time { return now }
+
+	queue.Enqueue("old", nil, 0, false, DropOldest)
+	now = now.Add(30 * time.Second)
+	queue.Enqueue("new", nil, 0, false, DropOldest)
+
+	if age := queue.Stats().OldestAge; age != 30*time.Second {
+		t.Fatalf("oldest age = %v", age)
+	}
+
+	now = now.Add(45 * time.Second)
+	msg, _ := queue.Peek()
+	if msg == nil || msg.Topic != "new" {
+		t.Fatalf("msg = %+v", msg)
+	}
+	if queue.Stats().Dropped != 1 {
+		t.Fatalf("dropped = %d", queue.Stats().Dropped)
+	}
+}
+
+// flakyTransport fails as many publishes as failures says, then records
+// the topics it publishes.
+type flakyTransport struct {
+	blockingTransport
+	failures  int
+	published []string
+}
+
+func (t *flakyTransport) publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte, props *PublishProperties) error {
+	if t.failures > 0 {
+		t.failures--
+		return errors.New("publish timed out")
+	}
+	t.published = append(t.published, topic)
+	return nil
+}
+
+func TestDrainRetriesFailedPublish(t *testing.T) {
+	queue, err := OpenOfflineQueue(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	for _, topic := range []string{"a", "b", "c"} {
+		queue.Enqueue(topic, nil, 1, false, DropOldest)
+	}
+	tr := &flakyTransport{failures: 2}
+	client := newTestClient(tr)
+	client.SetOfflineQueue(queue)
+	client.drainBackoff = time.Millisecond
+	var backlog []int
+	client.SetQueueHandler(func(stats QueueStats) {
+		backlog = append(backlog, stats.Depth)
+	})
+
+	client.draining = true
+	client.drainOfflineQueue(queue)
+
+	if len(tr.published) != 3 || tr.published[0] != "a" || tr.published[2] != "c" {
+		t.Fatalf("published = %v", tr.published)
+	}
+	if queue.Len() != 0 || client.draining {
+		t.Fatalf("len = %d, draining = %v", queue.Len(), client.draining)
+	}
+	if len(backlog) != 2 || backlog[0] != 3 || backlog[1] != 0 {
+		t.Fatalf("reported backlog = %v", backlog)
+	}
+
+	// With the backlog gone publishes go straight to the broker again
+	queued, err := client.publish(context.Background(), "d", nil, 1, false, nil)
+	if queued || err != nil || len(tr.published) != 4 {
+		t.Fatalf("queued = %v, err = %v, published = %v", queued, err, tr.published)
+	}
+}
+
+// rejectingTransport refuses publishes to topics in rejected with the
+// given error and records the topics it publishes.
+type rejectingTransport struct {
+	blockingTransport
+	rejected  map[string]error
+	attempts  map[string]int
+	published []string
+}
+
+func (t *rejectingTransport) publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte, props *PublishProperties) error {
+	t.attempts[topic]++
+	if err := t.rejected[topic]; err != nil {
+		return err
+	}
+	t.published = append(t.published, topic)
+	return nil
+}
+
+func TestDrainDropsUndeliverableMessages(t *testing.T) {
+	queue, err := OpenOfflineQueue(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	for _, topic := range []string{"a", "denied", "b", "timeout", "c"} {
+		queue.Enqueue(topic, nil, 1, false, DropOldest)
+	}
+	tr := &rejectingTransport{
+		rejected: map[string]error{
+			"denied":  &ReasonCodeError{Op: "publish", Code: 0x87},
+			"timeout": errors.New("publish timed out"),
+		},
+		attempts: map[string]int{},
+	}
+	client := newTestClient(tr)
+	client.SetOfflineQueue(queue)
+	client.drainBackoff = time.Millisecond
+
+	client.draining = true
+	client.drainOfflineQueue(queue)
+
+	if len(tr.published) != 3 || tr.published[0] != "a" || tr.published[1] != "b" || tr.published[2] != "c" {
+		t.Fatalf("published = %v", tr.published)
+	}
+	if tr.attempts["denied"] != 1 || tr.attempts["timeout"] != maxDrainAttempts {
+		t.Fatalf("attempts = %v", tr.attempts)
+	}
+	if stats := queue.Stats(); stats.Depth != 0 || stats.Dropped != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestParseDropPolicy(t *testing.T) {
+	for input, want := range map[string]DropPolicy{
+		"":            DropOldest,
+		"drop-oldest": DropOldest,
+		"Drop-Newest": DropNewest,
+		"never-queue": NeverQueue,
+	} {
+		got, err := ParseDropPolicy(input)
+		if err != nil || got != want {
+			t.Fatalf("ParseDropPolicy(%q) = %v, %v", input, got, err)
+		}
+	}
+	if _, err := ParseDropPolicy("bogus"); err == nil {
+		t.Fatal("missing error")
+	}
+}
@@ -0,0 +1,78 @@
+package mqtt_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/mqtt"
+	"github.com/iot-go-sdk/pkg/testplatform"
+)
+
+func TestReconnectRestoresSubscriptions(t *testing.T) {
+	p, err := testplatform.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.AddDevice("pk", "dn", "secret")
+
+	client := mqtt.NewClient(p.Config("pk", "dn"))
+	restored := make(chan []mqtt.ResubscribeResult, 4)
+	client.SetResubscribeHandler(func(results []mqtt.ResubscribeResult) {
+		restored <- results
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	received := make(chan string, 2)
+	for _, topic := range []string{"/pk/dn/user/get", "/pk/dn/user/denied"} {
+		if err := client.Subscribe(topic, 1, func(topic string, payload []byte) {
+			received <- string(payload)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.RejectSubscriptions("/pk/dn/user/denied")
+	p.DisconnectDevice("pk", "dn")
+
+	// The handler may also report the initial connect, which completes
+	// asynchronously; wait for the restore after the reconnect
+	failed := map[string]bool{}
+	timeout := time.After(10 * time.Second)
+	for !failed["/pk/dn/user/denied"] {
+		select {
+		case results := <-restored:
+			if len(results) != 2 {
+				continue
+			}
+			for _, result := range results {
+				failed[result.Topic] = result.Err != nil
+			}
+		case <-timeout:
+			t.Fatal("subscriptions not restored after reconnect")
+		}
+	}
+	if failed["/pk/dn/user/get"] {
+		t.Fatal("restoring /pk/dn/user/get failed")
+	}
+
+	// The restored subscription still delivers to its handler
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.WaitForSubscriber(ctx, "/pk/dn/user/get"); err != nil {
+		t.Fatal(err)
+	}
+	p.Publish("/pk/dn/user/get", []byte("after reconnect"))
+	select {
+	case payload := <-received:
+		if payload != "after reconnect" {
+			t.Fatalf("payload = %q", payload)
+		}
+	case <-ctx.Done():
+		t.Fatal("restored handler not called")
+	}
+}
@@ -0,0 +1,99 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/logging"
+)
+
+// rotationReplyTimeout bounds how long acknowledging a rotation may take.
+const rotationReplyTimeout = 30 * time.Second
+
+// SecretRotation applies device secrets pushed by the platform. Each new
+// secret is persisted through the FileSecretProvider, acknowledged on the
+// reply topic and used from the next connection on; the old secret remains
+// the fallback until the broker accepts the new one.
+type SecretRotation struct {
+	client     *Client
+	provider   *FileSecretProvider
+	productKey string
+	deviceName string
+	logger     logging.Logger
+}
+
+type secretRotateRequest struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	Params  struct {
+		DeviceSecret string `json:"deviceSecret"`
+	} `json:"params"`
+}
+
+type secretRotateReply struct {
+	ID      string                 `json:"id"`
+	Code    int                    `json:"code"`
+	Data    map[string]interface{} `json:"data"`
+	Message string                 `json:"message,omitempty"`
+}
+
+func NewSecretRotation(client *Client, provider *FileSecretProvider, productKey, deviceName string) *SecretRotation {
+	return &SecretRotation{
+		client:     client,
+		provider:   provider,
+		productKey: productKey,
+		deviceName: deviceName,
+		logger:     logging.Default().Named("rotation"),
+	}
+}
+
+func (r *SecretRotation) SetLogger(logger logging.Logger) {
+	r.logger = logging.Redacting(logger)
+}
+
+// Start subscribes to the secret rotation topic.
+func (r *SecretRotation) Start() error {
+	return r.client.Subscribe(r.requestTopic(), 1, r.handleRequest)
+}
+
+func (r *SecretRotation) Stop() error {
+	return r.client.Unsubscribe(r.requestTopic())
+}
+
+func (r *SecretRotation) requestTopic() string {
+	return fmt.Sprintf("/sys/%s/%s/thing/secret/rotate", r.productKey, r.deviceName)
+}
+
+func (r *SecretRotation) handleRequest(topic string, payload []byte) {
+	var request secretRotateRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		r.logger.Warn("invalid secret rotation request", "error", err)
+		return
+	}
+
+	reply := secretRotateReply{ID: request.ID, Code: 200, Data: map[string]interface{}{}, Message: "success"}
+	if err := r.provider.Rotate(request.Params.DeviceSecret); err != nil {
+		r.logger.Error("failed to apply rotated device secret", "error", err)
+		reply.Code = 500
+		reply.Message = err.Error()
+	} else {
+		r.logger.Info("device secret rotated; used from the next connection", "id", request.ID)
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		r.logger.Error("failed to marshal secret rotation reply", "error", err)
+		return
+	}
+	// Waiting for the PUBACK here would block the delivery of every later
+	// message, including the acknowledgement itself
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), rotationReplyTimeout)
+		defer cancel()
+		if err := r.client.PublishContext(ctx, topic+"_reply", data, 1, false); err != nil {
+			r.logger.Error("failed to acknowledge secret rotation", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,84 @@
+package mqtt_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/mqtt"
+	"github.com/iot-go-sdk/pkg/testplatform"
+)
+
+func TestSecretRotationDoesNotBlockDelivery(t *testing.T) {
+	p, err := testplatform.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.AddDevice("pk", "dn", "secret")
+
+	path := filepath.Join(t.TempDir(), "device.secret")
+	if err := os.WriteFile(path, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := mqtt.NewFileSecretProvider(path, "pk", "dn", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := mqtt.NewClient(p.Config("pk", "dn"))
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	rotation := mqtt.NewSecretRotation(client, provider, "pk", "dn")
+	if err := rotation.Start(); err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 5)
+	if err := client.Subscribe("/pk/dn/user/get", 1, func(topic string, payload []byte) {
+		received <- string(payload)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rotateTopic := "/sys/pk/dn/thing/secret/rotate"
+	if err := p.WaitForSubscriber(ctx, rotateTopic); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WaitForSubscriber(ctx, "/pk/dn/user/get"); err != nil {
+		t.Fatal(err)
+	}
+	// The handler replies while later messages are already arriving
+	p.Publish(rotateTopic, []byte(`{"id":"42","version":"1.0","params":{"deviceSecret":"new-secret"}}`))
+	for i := 0; i < 5; i++ {
+		p.Publish("/pk/dn/user/get", []byte("after rotation"))
+	}
+
+	m, err := p.WaitForMessage(ctx, rotateTopic+"_reply")
+	if err != nil {
+		t.Fatal("secret rotation not acknowledged")
+	}
+	var reply struct {
+		ID   string `json:"id"`
+		Code int    `json:"code"`
+	}
+	if err := json.Unmarshal(m.Payload, &reply); err != nil || reply.ID != "42" || reply.Code != 200 {
+		t.Fatalf("reply = %s, err = %v", m.Payload, err)
+	}
+
+	// Messages after the rotation request are still delivered
+	select {
+	case payload := <-received:
+		if payload != "after rotation" {
+			t.Fatalf("payload = %q", payload)
+		}
+	case <-ctx.Done():
+		t.Fatal("message after rotation request not delivered")
+	}
+}
@@ -0,0 +1,117 @@
+package mqtt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DispatchMode controls how an incoming message is delivered when several
+// subscriptions match its topic.
+type DispatchMode int
+
+const (
+	// DispatchAll delivers the message to every matching subscription,
+	// most specific filter first.
+	DispatchAll DispatchMode = iota
+	// DispatchFirstMatch delivers the message only to the most specific
+	// matching subscription.
+	DispatchFirstMatch
+)
+
+// ValidateTopicFilter checks that filter is a well-formed MQTT topic filter:
+// "+" and "#" must occupy a whole level and "#" must be the last level.
+func ValidateTopicFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("topic filter cannot be empty")
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") {
+			if level != "#" || i != len(levels)-1 {
+				return fmt.Errorf("invalid topic filter %q: '#' must be the last level", filter)
+			}
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("invalid topic filter %q: '+' must occupy a whole level", filter)
+		}
+	}
+	return nil
+}
+
+// TopicMatches reports whether topic matches the MQTT topic filter.
+//
+// "+" matches exactly one level and "#" matches the parent level and any
+// number of child levels. Topics starting with "$" are not matched by
+// filters whose first level is a wildcard, as required by the MQTT spec.
+func TopicMatches(filter, topic string) bool {
+	if filter == topic {
+		return true
+	}
+
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			// "a/#" also matches "a"
+			return i == len(filterLevels)-1
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
+
+// sortFiltersBySpecificity orders filters most specific first: filters
+// without wildcards come first, then levels are compared left to right
+// (literal before "+" before "#"), then longer filters win. Ties are broken
+// lexically so the order is always deterministic.
+func sortFiltersBySpecificity(filters []string) {
+	sort.Slice(filters, func(i, j int) bool {
+		return moreSpecific(filters[i], filters[j])
+	})
+}
+
+func moreSpecific(a, b string) bool {
+	aWild := strings.ContainsAny(a, "+#")
+	bWild := strings.ContainsAny(b, "+#")
+	if aWild != bWild {
+		return !aWild
+	}
+
+	aLevels := strings.Split(a, "/")
+	bLevels := strings.Split(b, "/")
+	for i := 0; i < len(aLevels) && i < len(bLevels); i++ {
+		aRank, bRank := levelRank(aLevels[i]), levelRank(bLevels[i])
+		if aRank != bRank {
+			return aRank < bRank
+		}
+	}
+
+	if len(aLevels) != len(bLevels) {
+		return len(aLevels) > len(bLevels)
+	}
+	return a < b
+}
+
+func levelRank(level string) int {
+	switch level {
+	case "#":
+		return 2
+	case "+":
+		return 1
+	default:
+		return 0
+	}
+}
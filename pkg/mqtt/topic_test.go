@@ -0,0 +1,99 @@
+package mqtt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{"a/b/c", "a/b/c", true},
+		{"a/b/c", "a/b", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/+/c", "a/b/d", false},
+		{"a/+", "a/", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"a/#", "ab/c", false},
+		{"#", "a/b", true},
+		{"+/+", "/a", true},
+		{"#", "$SYS/pk/dn/property/set", false},
+		{"+/pk/dn/property/set", "$SYS/pk/dn/property/set", false},
+		{"$SYS/#", "$SYS/pk/dn/property/set", true},
+		{"$SYS/pk/dn/service/+/invoke", "$SYS/pk/dn/service/start/invoke", true},
+		{"/sys/pk/dn/#", "/sys/pk/dn/rrpc/request/1", true},
+	}
+
+	for _, tc := range cases {
+		if got := TopicMatches(tc.filter, tc.topic); got != tc.want {
+			t.Errorf("TopicMatches(%q, %q) = %v, want %v", tc.filter, tc.topic, got, tc.want)
+		}
+	}
+}
+
+func TestValidateTopicFilter(t *testing.T) {
+	for _, filter := range []string{"a/b", "a/+/c", "a/#", "#", "+", "$SYS/+/#"} {
+		if err := ValidateTopicFilter(filter); err != nil {
+			t.Errorf("ValidateTopicFilter(%q) = %v", filter, err)
+		}
+	}
+	for _, filter := range []string{"", "a/#/c", "a/b#", "a/b+/c", "a/++"} {
+		if err := ValidateTopicFilter(filter); err == nil {
+			t.Errorf("ValidateTopicFilter(%q) accepted invalid filter", filter)
+		}
+	}
+}
+
+func TestSortFiltersBySpecificity(t *testing.T) {
+	filters := []string{
+		"/sys/pk/dn/#",
+		"/sys/pk/+/rrpc/request/+",
+		"/sys/pk/dn/rrpc/request/+",
+		"#",
+		"/sys/pk/dn/rrpc/request/42",
+		"/sys/pk/dn/rrpc/#",
+	}
+	sortFiltersBySpecificity(filters)
+
+	want := []string{
+		"/sys/pk/dn/rrpc/request/42",
+		"/sys/pk/dn/rrpc/request/+",
+		"/sys/pk/dn/rrpc/#",
+		"/sys/pk/dn/#",
+		"/sys/pk/+/rrpc/request/+",
+		"#",
+	}
+	if !reflect.DeepEqual(filters, want) {
+		t.Fatalf("order = %v", filters)
+	}
+}
+
+func TestMatchingHandlersDeliversToEveryOverlappingSubscription(t *testing.T) {
+	client := NewClient(nil)
+	var calls []string
+	for _, filter := range []string{"/sys/pk/dn/#", "/sys/pk/dn/rrpc/request/+", "/ota/#"} {
+		filter := filter
+		client.handlers[filter] = &subscription{handler: func(msg *Message) {
+			calls = append(calls, filter)
+		}}
+		client.filters = append(client.filters, filter)
+	}
+	sortFiltersBySpecificity(client.filters)
+
+	for _, handler := range client.matchingHandlers("/sys/pk/dn/rrpc/request/7") {
+		handler(&Message{})
+	}
+	if !reflect.DeepEqual(calls, []string{"/sys/pk/dn/rrpc/request/+", "/sys/pk/dn/#"}) {
+		t.Fatalf("calls = %v", calls)
+	}
+
+	client.SetDispatchMode(DispatchFirstMatch)
+	if handlers := client.matchingHandlers("/sys/pk/dn/rrpc/request/7"); len(handlers) != 1 {
+		t.Fatalf("first-match handlers = %d", len(handlers))
+	}
+}
@@ -0,0 +1,110 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/proxy"
+)
+
+// Protocol versions accepted in config.MQTTConfig.ProtocolVersion.
+const (
+	ProtocolV311 = 4
+	ProtocolV5   = 5
+)
+
+// UserProperty is an MQTT 5 user property. Keys may repeat.
+type UserProperty struct {
+	Key   string
+	Value string
+}
+
+// PublishProperties carries MQTT 5 publish properties. They are ignored by
+// the MQTT 3.1.1 transport.
+type PublishProperties struct {
+	UserProperties  []UserProperty
+	ResponseTopic   string
+	CorrelationData []byte
+	ContentType     string
+	// MessageExpiry is rounded down to whole seconds; zero means no expiry.
+	MessageExpiry time.Duration
+}
+
+// Message is an inbound message together with its MQTT 5 properties.
+// Properties is nil for messages received over MQTT 3.1.1.
+type Message struct {
+	Topic      string
+	Payload    []byte
+	QoS        byte
+	Retained   bool
+	Properties *PublishProperties
+}
+
+// ReasonCodeError reports a failure signalled by an MQTT reason code, such
+// as a rejected CONNECT, a failed SUBSCRIBE or a server DISCONNECT.
+type ReasonCodeError struct {
+	Op     string
+	Code   byte
+	Reason string
+}
+
+func (e *ReasonCodeError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s failed with reason code 0x%02x: %s", e.Op, e.Code, e.Reason)
+	}
+	return fmt.Sprintf("%s failed with reason code 0x%02x", e.Op, e.Code)
+}
+
+// connectParams is the protocol independent description of a connection.
+type connectParams struct {
+	host          string
+	port          int
+	tlsConfig     *tls.Config
+	websocket     bool
+	websocketPath string
+	proxy         *proxy.Dialer
+	clientID      string
+	username      string
+	password      string
+	keepAlive     time.Duration
+	cleanSession  bool
+	sessionExpiry time.Duration
+	topicAliasMax uint16
+	// refreshCredentials returns the credentials for a reconnect, or nil
+	// to reuse the previous ones.
+	refreshCredentials func() *auth.Credentials
+}
+
+// transportHandlers are the Client callbacks a transport invokes.
+type transportHandlers struct {
+	onMessage        func(msg *Message)
+	onConnect        func()
+	onConnectionLost func(err error)
+	onReconnecting   func()
+	// onConnectFailed reports a failed reconnect attempt.
+	onConnectFailed func(err error)
+}
+
+// transport is the protocol specific connection behind a Client.
+type transport interface {
+	connect(params *connectParams) error
+	disconnect()
+	isConnected() bool
+	publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte, props *PublishProperties) error
+	subscribe(topic string, qos byte) error
+	unsubscribe(topic string) error
+}
+
+func newTransport(protocolVersion int, handlers transportHandlers) (transport, error) {
+	switch protocolVersion {
+	case 0, 3, ProtocolV311:
+		return &v3Transport{handlers: handlers}, nil
+	case ProtocolV5:
+		return &v5Transport{handlers: handlers}, nil
+	default:
+		return nil, fmt.Errorf("unsupported MQTT protocol version: %d", protocolVersion)
+	}
+}
@@ -0,0 +1,112 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/iot-go-sdk/pkg/config"
+)
+
+func TestNewTransportSelectsProtocol(t *testing.T) {
+	for _, version := range []int{0, 3, ProtocolV311} {
+		tr, err := newTransport(version, transportHandlers{})
+		if err != nil {
+			t.Fatalf("newTransport(%d): %v", version, err)
+		}
+		if _, ok := tr.(*v3Transport); !ok {
+			t.Errorf("newTransport(%d) = %T, want *v3Transport", version, tr)
+		}
+	}
+
+	tr, err := newTransport(ProtocolV5, transportHandlers{})
+	if err != nil {
+		t.Fatalf("newTransport(5): %v", err)
+	}
+	if _, ok := tr.(*v5Transport); !ok {
+		t.Errorf("newTransport(5) = %T, want *v5Transport", tr)
+	}
+
+	if _, err := newTransport(6, transportHandlers{}); err == nil {
+		t.Error("newTransport(6) accepted unsupported version")
+	}
+}
+
+func TestPublishPropertiesRoundTrip(t *testing.T) {
+	props := &PublishProperties{
+		UserProperties:  []UserProperty{{Key: "trace", Value: "1"}, {Key: "trace", Value: "2"}},
+		ResponseTopic:   "/sys/pk/dn/reply",
+		CorrelationData: []byte("req-1"),
+		ContentType:     "application/json",
+		MessageExpiry:   90 * time.Second,
+	}
+
+	v5 := propertiesToV5(props)
+	if v5.MessageExpiry == nil || *v5.MessageExpiry != 90 {
+		t.Fatalf("MessageExpiry = %v", v5.MessageExpiry)
+	}
+
+	got := messageFromV5(&paho.Publish{Topic: "a/b", Payload: []byte("x"), Properties: v5})
+	if !reflect.DeepEqual(got.Properties, props) {
+		t.Fatalf("properties = %+v, want %+v", got.Properties, props)
+	}
+}
+
+func TestTopicAliasAllocation(t *testing.T) {
+	tr := &v5Transport{
+		aliasMax:  2,
+		aliases:   make(map[string]uint16),
+		confirmed: make(map[string]bool),
+	}
+
+	if alias, confirmed := tr.topicAlias("a"); alias != 1 || confirmed {
+		t.Fatalf("first alias = %d, %v", alias, confirmed)
+	}
+	tr.confirmed["a"] = true
+	if alias, confirmed := tr.topicAlias("a"); alias != 1 || !confirmed {
+		t.Fatalf("reused alias = %d, %v", alias, confirmed)
+	}
+	if alias, _ := tr.topicAlias("b"); alias != 2 {
+		t.Fatalf("second alias = %d", alias)
+	}
+	if alias, _ := tr.topicAlias("c"); alias != 0 {
+		t.Fatalf("alias beyond server limit = %d", alias)
+	}
+}
+
+func TestBrokerURL(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.MQTT.Host = "broker.example.com"
+	cfg.MQTT.Port = 443
+
+	params, err := newConnectParams(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := params.brokerURL("tcp", "ssl").String(); got != "tcp://broker.example.com:443" {
+		t.Errorf("tcp URL = %s", got)
+	}
+
+	cfg.MQTT.Transport = "websocket"
+	cfg.MQTT.WebSocketPath = "ws"
+	params, err = newConnectParams(cfg, &tls.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := params.brokerURL("mqtt", "tls").String(); got != "wss://broker.example.com:443/ws" {
+		t.Errorf("wss URL = %s", got)
+	}
+
+	cfg.MQTT.Transport = "wss"
+	if _, err := newConnectParams(cfg, nil); err == nil {
+		t.Error("expected error for wss without TLS")
+	}
+
+	cfg.MQTT.Transport = "websocket"
+	cfg.MQTT.Proxy.URL = "gopher://proxy"
+	if _, err := newConnectParams(cfg, nil); err == nil {
+		t.Error("expected error for unsupported proxy scheme")
+	}
+}
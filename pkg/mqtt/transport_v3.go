@@ -0,0 +1,119 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang"
+)
+
+// errReconnectFailed is reported for failed MQTT 3.1.1 reconnects; paho
+// does not expose the reason.
+var errReconnectFailed = errors.New("reconnect attempt failed")
+
+// v3Transport speaks MQTT 3.1.1 through paho.mqtt.golang.
+type v3Transport struct {
+	client   mqtt.Client
+	handlers transportHandlers
+
+	// reconnecting is set by each reconnect attempt and cleared once
+	// connected, so a repeated attempt means the previous one failed
+	reconnecting atomic.Bool
+}
+
+func (t *v3Transport) connect(params *connectParams) error {
+	opts := mqtt.NewClientOptions()
+	params.applyTransportOptions(opts)
+	opts.SetClientID(params.clientID)
+	opts.SetUsername(params.username)
+	opts.SetPassword(params.password)
+	opts.SetKeepAlive(params.keepAlive)
+	opts.SetCleanSession(params.cleanSession)
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(30 * time.Second)
+
+	opts.SetDefaultPublishHandler(func(client mqtt.Client, msg mqtt.Message) {
+		t.handlers.onMessage(&Message{
+			Topic:    msg.Topic(),
+			Payload:  msg.Payload(),
+			QoS:      msg.Qos(),
+			Retained: msg.Retained(),
+		})
+	})
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		t.handlers.onConnectionLost(err)
+	})
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		t.reconnecting.Store(false)
+		t.handlers.onConnect()
+	})
+	opts.SetReconnectingHandler(func(client mqtt.Client, opts *mqtt.ClientOptions) {
+		if t.reconnecting.Swap(true) {
+			t.handlers.onConnectFailed(errReconnectFailed)
+		}
+		t.handlers.onReconnecting()
+
+		// paho uses the options passed here for the next attempt
+		if params.refreshCredentials != nil {
+			if credentials := params.refreshCredentials(); credentials != nil {
+				opts.SetClientID(credentials.ClientID)
+				opts.SetUsername(credentials.Username)
+				opts.SetPassword(credentials.Password)
+			}
+		}
+	})
+
+	t.client = mqtt.NewClient(opts)
+
+	token := t.client.Connect()
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (t *v3Transport) disconnect() {
+	if t.client != nil {
+		t.client.Disconnect(250)
+	}
+}
+
+func (t *v3Transport) isConnected() bool {
+	return t.client != nil && t.client.IsConnected()
+}
+
+func (t *v3Transport) publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte, props *PublishProperties) error {
+	token := t.client.Publish(topic, qos, retained, payload)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *v3Transport) subscribe(topic string, qos byte) error {
+	// Messages are routed through the default publish handler rather than
+	// per-subscription paho callbacks, so overlapping subscriptions are
+	// delivered exactly once
+	token := t.client.Subscribe(topic, qos, nil)
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	if subToken, ok := token.(*mqtt.SubscribeToken); ok {
+		if granted, ok := subToken.Result()[topic]; ok && granted == 0x80 {
+			return &ReasonCodeError{Op: "subscribe", Code: granted}
+		}
+	}
+	return nil
+}
+
+func (t *v3Transport) unsubscribe(topic string) error {
+	token := t.client.Unsubscribe(topic)
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/gorilla/websocket"
+)
+
+const v5ConnectTimeout = 30 * time.Second
+
+// v5Transport speaks MQTT 5.0 through paho.golang's autopaho connection
+// manager, which also takes care of reconnecting.
+type v5Transport struct {
+	handlers transportHandlers
+	manager  *autopaho.ConnectionManager
+	cancel   context.CancelFunc
+
+	connected   atomic.Bool
+	established atomic.Bool
+	connectErrs chan error
+
+	aliasMutex     sync.Mutex
+	aliasMax       uint16
+	clientAliasMax uint16
+	aliases        map[string]uint16
+	confirmed      map[string]bool
+}
+
+func (t *v5Transport) connect(params *connectParams) error {
+	serverURL := params.brokerURL("mqtt", "tls")
+
+	t.clientAliasMax = params.topicAliasMax
+	t.connectErrs = make(chan error, 1)
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{serverURL},
+		TlsCfg:                        params.tlsConfig,
+		KeepAlive:                     uint16(params.keepAlive / time.Second),
+		CleanStartOnInitialConnection: params.cleanSession,
+		SessionExpiryInterval:         uint32(params.sessionExpiry / time.Second),
+		ReconnectBackoff:              autopaho.NewExponentialBackoff(time.Second, 30*time.Second, 2*time.Second, 2),
+		ConnectTimeout:                v5ConnectTimeout,
+		ConnectUsername:               params.username,
+		ConnectPassword:               []byte(params.password),
+		ConnectPacketBuilder:          refreshConnectPacket(params),
+		OnConnectionUp:                t.onConnectionUp,
+		OnConnectError:                t.onConnectError,
+		WebSocketCfg: &autopaho.WebSocketConfig{
+			Dialer: func(u *url.URL, tlsCfg *tls.Config) *websocket.Dialer {
+				dialer := *websocket.DefaultDialer
+				dialer.TLSClientConfig = tlsCfg
+				dialer.Subprotocols = []string{"mqtt"}
+				if proxyFunc := params.websocketProxy(); proxyFunc != nil {
+					dialer.Proxy = proxyFunc
+				}
+				return &dialer
+			},
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: params.clientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(received paho.PublishReceived) (bool, error) {
+					t.handlers.onMessage(messageFromV5(received.Packet))
+					return true, nil
+				},
+			},
+			OnClientError: func(err error) {
+				t.lost(err)
+			},
+			OnServerDisconnect: func(disconnect *paho.Disconnect) {
+				reason := ""
+				if disconnect.Properties != nil {
+					reason = disconnect.Properties.ReasonString
+				}
+				t.lost(&ReasonCodeError{Op: "server disconnect", Code: disconnect.ReasonCode, Reason: reason})
+			},
+		},
+	}
+
+	if params.proxy != nil && !params.websocket {
+		cfg.AttemptConnection = func(ctx context.Context, _ autopaho.ClientConfig, _ *url.URL) (net.Conn, error) {
+			conn, err := params.dialProxy(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return packets.NewThreadSafeConn(conn), nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager, err := autopaho.NewConnection(ctx, cfg)
+	if err != nil {
+		cancel()
+		return err
+	}
+	t.manager = manager
+	t.cancel = cancel
+
+	awaitCtx, awaitCancel := context.WithTimeout(ctx, v5ConnectTimeout)
+	defer awaitCancel()
+
+	up := make(chan error, 1)
+	go func() {
+		up <- manager.AwaitConnection(awaitCtx)
+	}()
+
+	select {
+	case err := <-up:
+		if err == nil {
+			t.established.Store(true)
+			return nil
+		}
+		cancel()
+		return fmt.Errorf("timed out waiting for connection: %w", err)
+	case err := <-t.connectErrs:
+		// Fail fast on the first attempt instead of retrying forever with
+		// credentials the broker has already rejected
+		cancel()
+		return err
+	}
+}
+
+func (t *v5Transport) onConnectionUp(manager *autopaho.ConnectionManager, connack *paho.Connack) {
+	serverAliasMax := uint16(0)
+	if connack.Properties != nil && connack.Properties.TopicAliasMaximum != nil {
+		serverAliasMax = *connack.Properties.TopicAliasMaximum
+	}
+
+	// Aliases are scoped to a network connection and must be rebuilt
+	t.aliasMutex.Lock()
+	t.aliasMax = t.clientAliasMax
+	if serverAliasMax < t.aliasMax {
+		t.aliasMax = serverAliasMax
+	}
+	t.aliases = make(map[string]uint16)
+	t.confirmed = make(map[string]bool)
+	t.aliasMutex.Unlock()
+
+	t.connected.Store(true)
+	t.handlers.onConnect()
+}
+
+func (t *v5Transport) onConnectError(err error) {
+	var connackErr *autopaho.ConnackError
+	if errors.As(err, &connackErr) {
+		err = &ReasonCodeError{Op: "connect", Code: connackErr.ReasonCode, Reason: connackErr.Reason}
+	}
+
+	if !t.established.Load() {
+		select {
+		case t.connectErrs <- err:
+		default:
+		}
+		return
+	}
+	t.handlers.onConnectFailed(err)
+	t.handlers.onReconnecting()
+}
+
+// refreshConnectPacket returns a CONNECT builder that puts fresh
+// credentials into every connection attempt.
+func refreshConnectPacket(params *connectParams) func(*paho.Connect, *url.URL) (*paho.Connect, error) {
+	return func(connect *paho.Connect, _ *url.URL) (*paho.Connect, error) {
+		if params.refreshCredentials == nil {
+			return connect, nil
+		}
+		if credentials := params.refreshCredentials(); credentials != nil {
+			connect.ClientID = credentials.ClientID
+			connect.Username = credentials.Username
+			connect.UsernameFlag = credentials.Username != ""
+			connect.Password = []byte(credentials.Password)
+			connect.PasswordFlag = credentials.Password != ""
+		}
+		return connect, nil
+	}
+}
+
+func (t *v5Transport) lost(err error) {
+	if t.connected.Swap(false) {
+		t.handlers.onConnectionLost(err)
+	}
+}
+
+func (t *v5Transport) disconnect() {
+	if t.manager == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	t.manager.Disconnect(ctx)
+	t.connected.Store(false)
+	t.cancel()
+}
+
+func (t *v5Transport) isConnected() bool {
+	return t.connected.Load()
+}
+
+func (t *v5Transport) publish(ctx context.Context, topic string, qos byte, retained bool, payload []byte, props *PublishProperties) error {
+	pub := &paho.Publish{
+		Topic:      topic,
+		QoS:        qos,
+		Retain:     retained,
+		Payload:    payload,
+		Properties: propertiesToV5(props),
+	}
+
+	alias, confirmed := t.topicAlias(topic)
+	if alias != 0 {
+		if pub.Properties == nil {
+			pub.Properties = &paho.PublishProperties{}
+		}
+		pub.Properties.TopicAlias = &alias
+		if confirmed {
+			pub.Topic = ""
+		}
+	}
+
+	resp, err := t.manager.Publish(ctx, pub)
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.ReasonCode >= 0x80 {
+		reason := ""
+		if resp.Properties != nil {
+			reason = resp.Properties.ReasonString
+		}
+		return &ReasonCodeError{Op: "publish", Code: resp.ReasonCode, Reason: reason}
+	}
+
+	if alias != 0 && !confirmed {
+		t.aliasMutex.Lock()
+		if t.aliases[topic] == alias {
+			t.confirmed[topic] = true
+		}
+		t.aliasMutex.Unlock()
+	}
+	return nil
+}
+
+// topicAlias returns the alias for topic, allocating one while the server
+// limit allows. confirmed reports whether the broker has already seen the
+// topic/alias pair so the topic name can be omitted.
+func (t *v5Transport) topicAlias(topic string) (alias uint16, confirmed bool) {
+	t.aliasMutex.Lock()
+	defer t.aliasMutex.Unlock()
+
+	if alias, ok := t.aliases[topic]; ok {
+		return alias, t.confirmed[topic]
+	}
+	if t.aliasMax == 0 || len(t.aliases) >= int(t.aliasMax) {
+		return 0, false
+	}
+
+	alias = uint16(len(t.aliases) + 1)
+	t.aliases[topic] = alias
+	return alias, false
+}
+
+func (t *v5Transport) subscribe(topic string, qos byte) error {
+	suback, err := t.manager.Subscribe(context.Background(), &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: qos}},
+	})
+	if suback != nil && len(suback.Reasons) > 0 && suback.Reasons[0] >= 0x80 {
+		reason := ""
+		if suback.Properties != nil {
+			reason = suback.Properties.ReasonString
+		}
+		return &ReasonCodeError{Op: "subscribe", Code: suback.Reasons[0], Reason: reason}
+	}
+	return err
+}
+
+func (t *v5Transport) unsubscribe(topic string) error {
+	unsuback, err := t.manager.Unsubscribe(context.Background(), &paho.Unsubscribe{
+		Topics: []string{topic},
+	})
+	if unsuback != nil && len(unsuback.Reasons) > 0 && unsuback.Reasons[0] >= 0x80 {
+		reason := ""
+		if unsuback.Properties != nil {
+			reason = unsuback.Properties.ReasonString
+		}
+		return &ReasonCodeError{Op: "unsubscribe", Code: unsuback.Reasons[0], Reason: reason}
+	}
+	return err
+}
+
+func propertiesToV5(props *PublishProperties) *paho.PublishProperties {
+	if props == nil {
+		return nil
+	}
+
+	v5Props := &paho.PublishProperties{
+		ResponseTopic:   props.ResponseTopic,
+		CorrelationData: props.CorrelationData,
+		ContentType:     props.ContentType,
+	}
+	if props.MessageExpiry > 0 {
+		expiry := uint32(props.MessageExpiry / time.Second)
+		v5Props.MessageExpiry = &expiry
+	}
+	for _, prop := range props.UserProperties {
+		v5Props.User.Add(prop.Key, prop.Value)
+	}
+	return v5Props
+}
+
+func messageFromV5(pub *paho.Publish) *Message {
+	msg := &Message{
+		Topic:    pub.Topic,
+		Payload:  pub.Payload,
+		QoS:      pub.QoS,
+		Retained: pub.Retain,
+	}
+
+	msg.Properties = &PublishProperties{}
+	if pub.Properties != nil {
+		msg.Properties.ResponseTopic = pub.Properties.ResponseTopic
+		msg.Properties.CorrelationData = pub.Properties.CorrelationData
+		msg.Properties.ContentType = pub.Properties.ContentType
+		if pub.Properties.MessageExpiry != nil {
+			msg.Properties.MessageExpiry = time.Duration(*pub.Properties.MessageExpiry) * time.Second
+		}
+		for _, prop := range pub.Properties.User {
+			msg.Properties.UserProperties = append(msg.Properties.UserProperties, UserProperty{Key: prop.Key, Value: prop.Value})
+		}
+	}
+	return msg
+}
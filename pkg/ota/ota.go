@@ -8,12 +8,12 @@ import (
 	"fmt"
 	"hash"
 	"io"
-	"log"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/iot-go-sdk/pkg/logging"
 	"github.com/iot-go-sdk/pkg/mqtt"
 )
 
@@ -74,7 +74,7 @@ type Client struct {
 	currentVersion  string
 	recvHandler     RecvHandler
 	downloadHandler DownloadHandler
-	logger          *log.Logger
+	logger          logging.Logger
 	mutex           sync.RWMutex
 	downloadCtx     context.Context
 	downloadCancel  context.CancelFunc
@@ -86,13 +86,13 @@ func NewClient(mqttClient *mqtt.Client, productKey, deviceName string) *Client {
 		mqttClient: mqttClient,
 		productKey: productKey,
 		deviceName: deviceName,
-		logger:     log.Default(),
+		logger:     logging.Default().Named("ota"),
 	}
 }
 
 // SetLogger sets the logger for the OTA client
-func (c *Client) SetLogger(logger *log.Logger) {
-	c.logger = logger
+func (c *Client) SetLogger(logger logging.Logger) {
+	c.logger = logging.Redacting(logger)
 }
 
 // SetRecvHandler sets the OTA message receive handler
@@ -124,7 +124,7 @@ func (c *Client) Start() error {
 		return fmt.Errorf("failed to subscribe to firmware reply topic: %w", err)
 	}
 
-	c.logger.Printf("OTA client started, subscribed to FOTA topic: %s and firmware reply topic: %s", fotaTopic, firmwareReplyTopic)
+	c.logger.Info("OTA client started", "fotaTopic", fotaTopic, "firmwareReplyTopic", firmwareReplyTopic)
 	return nil
 }
 
@@ -182,11 +182,7 @@ func (c *Client) ReportVersionWithModule(version string, module string) error {
 		return fmt.Errorf("failed to publish version report: %w", err)
 	}
 
-	if module != "" {
-		c.logger.Printf("Reported version: %s (module: %s)", version, module)
-	} else {
-		c.logger.Printf("Reported version: %s", version)
-	}
+	c.logger.Info("reported version", "version", version, "module", module)
 	return nil
 }
 
@@ -251,21 +247,17 @@ func (c *Client) QueryFirmwareWithModule(module string) error {
 		return fmt.Errorf("failed to publish firmware query: %w", err)
 	}
 
-	if module != "" {
-		c.logger.Printf("Queried for firmware updates (module: %s)", module)
-	} else {
-		c.logger.Printf("Queried for firmware updates")
-	}
+	c.logger.Info("queried for firmware updates", "module", module)
 	return nil
 }
 
 // handleOTAMessage handles incoming OTA messages
 func (c *Client) handleOTAMessage(topic string, payload []byte) {
-	c.logger.Printf("Received OTA message on topic %s: %s", topic, string(payload))
+	c.logger.Debug("received OTA message", "topic", topic, "payload", string(payload))
 
 	var msg map[string]interface{}
 	if err := json.Unmarshal(payload, &msg); err != nil {
-		c.logger.Printf("Failed to unmarshal OTA message: %v", err)
+		c.logger.Warn("failed to unmarshal OTA message", "topic", topic, "error", err)
 		return
 	}
 
@@ -302,7 +294,7 @@ func (c *Client) handleOTAMessage(topic string, payload []byte) {
 	// Parse task description
 	task := c.parseTaskDesc(msg)
 	if task == nil {
-		c.logger.Printf("Failed to parse task description")
+		c.logger.Warn("failed to parse task description", "topic", topic)
 		return
 	}
 
@@ -325,7 +317,7 @@ func (c *Client) parseTaskDesc(msg map[string]interface{}) *TaskDesc {
 
 	// Check if data is empty (no firmware update available)
 	if len(data) == 0 {
-		c.logger.Printf("No firmware update available")
+		c.logger.Info("no firmware update available")
 		return nil
 	}
 
@@ -370,7 +362,7 @@ func (c *Client) parseTaskDesc(msg map[string]interface{}) *TaskDesc {
 
 	// Validate required fields for firmware update
 	if task.URL == "" || task.Size == 0 {
-		c.logger.Printf("Invalid firmware update data: missing URL or size")
+		c.logger.Warn("invalid firmware update data: missing URL or size")
 		return nil
 	}
 
@@ -416,12 +408,7 @@ func (c *Client) Download(ctx context.Context, task *TaskDesc, rangeStart, range
 	}
 	
 	// Log response headers for debugging
-	if c.logger != nil {
-		c.logger.Printf("HTTP Status: %s, Content-Length: %d", resp.Status, resp.ContentLength)
-		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
-			c.logger.Printf("Content-Type: %s", contentType)
-		}
-	}
+	c.logger.Debug("firmware download response", "status", resp.Status, "contentLength", resp.ContentLength, "contentType", resp.Header.Get("Content-Type"))
 
 	// For partial downloads, we don't verify digest
 	// The digest verification should be done after downloading the complete file
@@ -446,9 +433,7 @@ func (c *Client) Download(ctx context.Context, task *TaskDesc, rangeStart, range
 	// Get actual content length from response header
 	if contentLength := resp.ContentLength; contentLength > 0 {
 		totalSize = uint64(contentLength)
-		if c.logger != nil {
-			c.logger.Printf("Actual download size: %d bytes (task.Size was %d)", totalSize, task.Size)
-		}
+		c.logger.Debug("actual download size", "bytes", totalSize, "taskSize", task.Size)
 	}
 
 	// Use io.Copy with a custom writer to track progress
@@ -516,9 +501,7 @@ func (c *Client) Download(ctx context.Context, task *TaskDesc, rangeStart, range
 					// EOF reached, check if download is complete
 					if pw.downloaded < totalSize {
 						// Try to continue reading in case it's a false EOF
-						if c.logger != nil {
-							c.logger.Printf("Received EOF at %d bytes, expected %d bytes. Attempting to continue...", pw.downloaded, totalSize)
-						}
+						c.logger.Warn("early EOF, retrying read", "downloaded", pw.downloaded, "expected", totalSize)
 						// Give it a small delay and retry
 						time.Sleep(100 * time.Millisecond)
 						
@@ -536,9 +519,7 @@ func (c *Client) Download(ctx context.Context, task *TaskDesc, rangeStart, range
 						if err2 == io.EOF && pw.downloaded < totalSize {
 							// Really incomplete
 							err := fmt.Errorf("download incomplete: got %d bytes, expected %d bytes", pw.downloaded, totalSize)
-							if c.logger != nil {
-								c.logger.Printf("Download incomplete: %d/%d bytes (%.1f%%)", pw.downloaded, totalSize, float64(pw.downloaded)*100/float64(totalSize))
-							}
+							c.logger.Error("download incomplete", "downloaded", pw.downloaded, "expected", totalSize)
 							c.notifyDownloadHandler(-2, nil, err)
 							return err
 						}
@@ -11,7 +11,7 @@ import (
 
 // SimpleDownload performs a simple, direct download of the firmware
 func (c *Client) SimpleDownload(ctx context.Context, task *TaskDesc) ([]byte, error) {
-	c.logger.Printf("Starting simple download from %s", task.URL)
+	c.logger.Info("starting simple download", "url", task.URL)
 	
 	// Create HTTP client with longer timeout
 	client := &http.Client{
@@ -44,7 +44,7 @@ func (c *Client) SimpleDownload(ctx context.Context, task *TaskDesc) ([]byte, er
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 	
-	c.logger.Printf("Response: %s, Content-Length: %d", resp.Status, resp.ContentLength)
+	c.logger.Debug("firmware download response", "status", resp.Status, "contentLength", resp.ContentLength)
 	
 	// Read all data at once
 	data, err := io.ReadAll(resp.Body)
@@ -52,7 +52,7 @@ func (c *Client) SimpleDownload(ctx context.Context, task *TaskDesc) ([]byte, er
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 	
-	c.logger.Printf("Downloaded %d bytes", len(data))
+	c.logger.Info("downloaded firmware", "bytes", len(data))
 	
 	// Verify size
 	if uint32(len(data)) != task.Size {
@@ -66,6 +66,6 @@ func (c *Client) SimpleDownload(ctx context.Context, task *TaskDesc) ([]byte, er
 		return nil, fmt.Errorf("digest mismatch: expected %s, got %s", task.ExpectDigest, digest)
 	}
 	
-	c.logger.Printf("Download successful, MD5 verified")
+	c.logger.Info("download successful, MD5 verified")
 	return data, nil
 }
\ No newline at end of file
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	xproxy "golang.org/x/net/proxy"
+)
+
+const defaultDialTimeout = 30 * time.Second
+
+// Dialer opens TCP connections through an HTTP CONNECT or SOCKS5 proxy.
+type Dialer struct {
+	proxyURL *url.URL
+	forward  *net.Dialer
+}
+
+// New creates a Dialer for the proxy at rawURL. Supported schemes are http
+// (HTTP CONNECT) and socks5/socks5h. username and password, when set,
+// override credentials embedded in the URL. An empty rawURL returns nil.
+func New(rawURL, username, password string) (*Dialer, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy URL has no host: %s", rawURL)
+	}
+	if proxyURL.Port() == "" {
+		port := "1080"
+		if proxyURL.Scheme == "http" {
+			port = "80"
+		}
+		proxyURL.Host = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	if username != "" {
+		proxyURL.User = url.UserPassword(username, password)
+	}
+
+	return &Dialer{
+		proxyURL: proxyURL,
+		forward:  &net.Dialer{Timeout: defaultDialTimeout},
+	}, nil
+}
+
+// URL returns the proxy URL including credentials, suitable for
+// websocket.Dialer.Proxy.
+func (d *Dialer) URL() *url.URL {
+	u := *d.proxyURL
+	return &u
+}
+
+// String returns the proxy URL with the password redacted.
+func (d *Dialer) String() string {
+	return d.proxyURL.Redacted()
+}
+
+// Dial connects to addr through the proxy.
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to addr through the proxy.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.proxyURL.Scheme == "http" {
+		return d.dialHTTPConnect(ctx, addr)
+	}
+	return d.dialSOCKS5(ctx, network, addr)
+}
+
+func (d *Dialer) dialSOCKS5(ctx context.Context, network, addr string) (net.Conn, error) {
+	var auth *xproxy.Auth
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth = &xproxy.Auth{User: user.Username(), Password: password}
+	}
+
+	dialer, err := xproxy.SOCKS5("tcp", d.proxyURL.Host, auth, d.forward)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+	}
+
+	conn, err := dialer.(xproxy.ContextDialer).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %w", d.proxyURL.Host, err)
+	}
+	return conn, nil
+}
+
+func (d *Dialer) dialHTTPConnect(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s: %w", d.proxyURL.Host, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(defaultDialTimeout))
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT request: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s failed: %s", addr, resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn keeps bytes the broker sent right after the CONNECT reply.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,68 @@
+package rrpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// Validator is implemented by params types that check their own values.
+type Validator interface {
+	Validate() error
+}
+
+// Bind returns a Handler that decodes the request's params into a T and
+// calls handler with it. Params that do not decode, or fail Validate when
+// T or *T is a Validator, are answered with CodeBadRequest without calling
+// handler.
+func Bind[T any](handler func(ctx context.Context, request *RRPCRequest, params T) (interface{}, error)) Handler {
+	return func(ctx context.Context, request *RRPCRequest) (interface{}, error) {
+		var params T
+		if err := decodeParams(request, &params); err != nil {
+			return nil, Errorf(CodeBadRequest, "invalid params: %v", err)
+		}
+		if err := validate(&params); err != nil {
+			return nil, Errorf(CodeBadRequest, "invalid params: %v", err)
+		}
+		return handler(ctx, request, params)
+	}
+}
+
+// validate calls Validate on *params, or on params itself when only T is
+// a Validator, e.g. a pointer type whose method has a pointer receiver. A
+// nil pointer left by absent params is not validated.
+func validate[T any](params *T) error {
+	if v, ok := any(params).(Validator); ok {
+		return v.Validate()
+	}
+	if v, ok := any(*params).(Validator); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		return v.Validate()
+	}
+	return nil
+}
+
+// decodeParams decodes params from the raw payload when there is one, so
+// large integers keep their precision.
+func decodeParams(request *RRPCRequest, params interface{}) error {
+	var envelope struct {
+		Params json.RawMessage `json:"params"`
+	}
+	if len(request.Payload) > 0 {
+		if err := json.Unmarshal(request.Payload, &envelope); err != nil {
+			return err
+		}
+	} else if request.Params != nil {
+		data, err := json.Marshal(request.Params)
+		if err != nil {
+			return err
+		}
+		envelope.Params = data
+	}
+	if len(envelope.Params) == 0 || string(envelope.Params) == "null" {
+		return nil
+	}
+	return json.Unmarshal(envelope.Params, params)
+}
@@ -0,0 +1,147 @@
+package rrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Reply codes. Handlers choose others by returning an *Error.
+const (
+	CodeSuccess    = 200
+	CodeBadRequest = 400
+	CodeNotFound   = 404
+	CodeInternal   = 500
+	// CodeBusy rejects a request when the queue is full
+	CodeBusy = 503
+	// CodeTimeout answers a request whose handler overran its deadline
+	CodeTimeout = 504
+)
+
+// Error is a handler error answered with its own code instead of 500.
+// Data, if set, is sent as the reply's data.
+type Error struct {
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+// Errorf returns an *Error with code and a formatted message.
+func Errorf(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rrpc error %d: %s", e.Code, e.Message)
+}
+
+// asError maps a handler error to a reply code; errors other than *Error
+// are internal errors.
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: CodeInternal, Message: err.Error()}
+}
+
+// ResponseFormat selects the shape of RRPC replies.
+type ResponseFormat string
+
+const (
+	// FormatEnvelope echoes the request id and answers
+	// {"id","version","code","message","data"}.
+	FormatEnvelope ResponseFormat = "envelope"
+	// FormatCSDK answers like the C SDK: id "1", the result in params,
+	// {"LightSwitch":0} for an empty result and errors in params.error.
+	FormatCSDK ResponseFormat = "csdk"
+)
+
+// UnmarshalJSON accepts the id as a string or a number.
+func (r *RRPCRequest) UnmarshalJSON(data []byte) error {
+	type plain RRPCRequest
+	var request struct {
+		*plain
+		ID json.RawMessage `json:"id"`
+	}
+	request.plain = (*plain)(r)
+	if err := json.Unmarshal(data, &request); err != nil {
+		return err
+	}
+	r.ID = ""
+	if len(request.ID) > 0 && string(request.ID) != "null" {
+		if id, err := strconv.Unquote(string(request.ID)); err == nil {
+			r.ID = id
+		} else {
+			r.ID = string(request.ID)
+		}
+	}
+	return nil
+}
+
+// buildResponse answers a request with the handler's result or error in
+// the given format. id is the request's id.
+func buildResponse(format ResponseFormat, id string, result interface{}, err error) (RRPCResponse, error) {
+	if format == FormatCSDK {
+		return csdkResponse(result, err)
+	}
+
+	response := RRPCResponse{ID: id, Version: "1.0", Code: CodeSuccess, Message: "success"}
+	if err != nil {
+		e := asError(err)
+		response.Code, response.Message, result = e.Code, e.Message, e.Data
+	}
+	if result == nil {
+		response.Data = json.RawMessage("{}")
+		return response, nil
+	}
+	data, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		return response, fmt.Errorf("failed to marshal RRPC result: %w", marshalErr)
+	}
+	response.Data = data
+	return response, nil
+}
+
+func csdkResponse(result interface{}, err error) (RRPCResponse, error) {
+	response := RRPCResponse{ID: "1", Version: "1.0"}
+	if err != nil {
+		e := asError(err)
+		response.Params = map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    e.Code,
+				"message": e.Message,
+			},
+		}
+		return response, nil
+	}
+
+	switch result := result.(type) {
+	case nil:
+		response.Params = map[string]interface{}{"LightSwitch": 0}
+	case string:
+		response.Params = map[string]interface{}{"result": result}
+	default:
+		data, err := json.Marshal(result)
+		if err != nil {
+			return response, fmt.Errorf("failed to marshal RRPC result: %w", err)
+		}
+		if err := json.Unmarshal(data, &response.Params); err != nil {
+			response.Params = map[string]interface{}{"result": string(data)}
+		}
+	}
+	return response, nil
+}
+
+// rawResult converts the output of a RequestHandler to a result: nil when
+// empty, the JSON itself when valid, otherwise a string.
+func rawResult(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	return string(data)
+}
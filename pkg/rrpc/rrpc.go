@@ -4,24 +4,43 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"regexp"
 	"sync"
 	"time"
 
+	"github.com/iot-go-sdk/pkg/logging"
 	"github.com/iot-go-sdk/pkg/mqtt"
 )
 
+// Handler answers a request with a result marshaled as the reply's data,
+// or an error; return an *Error to choose the reply code. ctx is done when
+// the platform stops waiting for the reply.
+type Handler func(ctx context.Context, request *RRPCRequest) (interface{}, error)
+
+// RequestHandler receives the request id of the topic and the raw payload
+// and returns raw JSON, see RegisterHandler.
 type RequestHandler func(requestId string, payload []byte) ([]byte, error)
 
+// ResultHandler receives the request id of the topic and the raw payload
+// and returns a result like a Handler.
+type ResultHandler func(ctx context.Context, requestId string, payload []byte) (interface{}, error)
+
 type RRPCClient struct {
 	mqttClient   *mqtt.Client
 	productKey   string
 	deviceName   string
-	handlers     map[string]RequestHandler
+	handlers     map[string]Handler
+	format       ResponseFormat
+	workers      int
+	queueSize    int
+	timeout      time.Duration
 	mutex        sync.RWMutex
-	logger       *log.Logger
+	logger       logging.Logger
 	requestIdReg *regexp.Regexp
+
+	// queue feeds the workers until cancel is called by Stop
+	queue  chan *job
+	cancel context.CancelFunc
 }
 
 type RRPCRequest struct {
@@ -29,6 +48,13 @@ type RRPCRequest struct {
 	Version string                 `json:"version"`
 	Params  map[string]interface{} `json:"params"`
 	Method  string                 `json:"method,omitempty"`
+
+	// Set on received requests. RequestID is the id of the topic, which
+	// the reply is published under.
+	Topic      string    `json:"-"`
+	RequestID  string    `json:"-"`
+	Payload    []byte    `json:"-"`
+	ReceivedAt time.Time `json:"-"`
 }
 
 type RRPCResponse struct {
@@ -36,7 +62,7 @@ type RRPCResponse struct {
 	Version string                 `json:"version"`
 	Params  map[string]interface{} `json:"params,omitempty"`
 	Code    int                    `json:"code,omitempty"`
-	Data    map[string]interface{} `json:"data,omitempty"`
+	Data    json.RawMessage        `json:"data,omitempty"`
 	Message string                 `json:"message,omitempty"`
 }
 
@@ -47,14 +73,28 @@ func NewRRPCClient(mqttClient *mqtt.Client, productKey, deviceName string) *RRPC
 		mqttClient:   mqttClient,
 		productKey:   productKey,
 		deviceName:   deviceName,
-		handlers:     make(map[string]RequestHandler),
-		logger:       log.Default(),
+		handlers:     make(map[string]Handler),
+		format:       FormatEnvelope,
+		workers:      DefaultWorkers,
+		queueSize:    DefaultQueueSize,
+		timeout:      DefaultTimeout,
+		logger:       logging.Default().Named("rrpc"),
 		requestIdReg: requestIdReg,
 	}
 }
 
-func (c *RRPCClient) SetLogger(logger *log.Logger) {
-	c.logger = logger
+func (c *RRPCClient) SetLogger(logger logging.Logger) {
+	c.logger = logging.Redacting(logger)
+}
+
+// SetResponseFormat selects the reply shape, FormatEnvelope by default.
+func (c *RRPCClient) SetResponseFormat(format ResponseFormat) {
+	if format == "" {
+		format = FormatEnvelope
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.format = format
 }
 
 func (c *RRPCClient) Start() error {
@@ -63,69 +103,94 @@ func (c *RRPCClient) Start() error {
 	}
 
 	requestTopic := fmt.Sprintf("/sys/%s/%s/rrpc/request/+", c.productKey, c.deviceName)
-	c.logger.Printf("Starting RRPC client, subscribing to topic: %s", requestTopic)
-	
+	c.logger.Info("starting RRPC client", "topic", requestTopic)
+	c.startWorkers()
+
 	err := c.mqttClient.Subscribe(requestTopic, 0, c.handleRRPCRequest)
 	if err != nil {
-		c.logger.Printf("Failed to subscribe to RRPC topic: %v", err)
+		c.logger.Error("failed to subscribe to RRPC topic", "topic", requestTopic, "error", err)
+		c.stopWorkers()
 		return err
 	}
 	
-	c.logger.Printf("Successfully subscribed to RRPC topic: %s", requestTopic)
+	c.logger.Info("subscribed to RRPC topic", "topic", requestTopic)
 	return nil
 }
 
+// Stop unsubscribes and cancels the contexts of running handlers. Queued
+// requests are dropped without a reply.
 func (c *RRPCClient) Stop() error {
 	requestTopic := fmt.Sprintf("/sys/%s/%s/rrpc/request/+", c.productKey, c.deviceName)
-	return c.mqttClient.Unsubscribe(requestTopic)
+	err := c.mqttClient.Unsubscribe(requestTopic)
+	c.stopWorkers()
+	return err
 }
 
-func (c *RRPCClient) RegisterHandler(method string, handler RequestHandler) {
+// Register registers the handler of method, replacing any previous one.
+func (c *RRPCClient) Register(method string, handler Handler) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	c.handlers[method] = handler
 }
 
+// RegisterHandler registers a handler returning raw JSON. Output that is
+// not valid JSON is answered as a string.
+func (c *RRPCClient) RegisterHandler(method string, handler RequestHandler) {
+	c.Register(method, func(ctx context.Context, request *RRPCRequest) (interface{}, error) {
+		data, err := handler(request.RequestID, request.Payload)
+		return rawResult(data), err
+	})
+}
+
+// RegisterResultHandler registers a handler returning a typed result from
+// the raw payload.
+func (c *RRPCClient) RegisterResultHandler(method string, handler ResultHandler) {
+	c.Register(method, func(ctx context.Context, request *RRPCRequest) (interface{}, error) {
+		return handler(ctx, request.RequestID, request.Payload)
+	})
+}
+
 func (c *RRPCClient) UnregisterHandler(method string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	delete(c.handlers, method)
 }
 
+// handleRRPCRequest runs on the MQTT client's callback goroutine, so it
+// answers malformed requests itself and queues the rest for the workers.
 func (c *RRPCClient) handleRRPCRequest(topic string, payload []byte) {
-	c.logger.Printf("Received RRPC request on topic: %s, payload: %s", topic, string(payload))
+	received := time.Now()
+	c.logger.Debug("received RRPC request", "topic", topic, "payload", string(payload))
 
 	requestId := c.extractRequestId(topic)
 	if requestId == "" {
-		c.logger.Printf("Failed to extract request ID from topic: %s", topic)
+		c.logger.Warn("failed to extract request ID", "topic", topic)
 		return
 	}
 
 	var request RRPCRequest
 	if err := json.Unmarshal(payload, &request); err != nil {
-		c.logger.Printf("Failed to unmarshal RRPC request: %v", err)
-		c.sendErrorResponse(requestId, 400, "Invalid JSON format")
+		c.logger.Warn("failed to unmarshal RRPC request", "requestId", requestId, "error", err)
+		c.reply(requestId, requestId, nil, Errorf(CodeBadRequest, "Invalid JSON format"))
 		return
 	}
+	// The reply echoes the request's id, or the topic's when it has none
+	if request.ID == "" {
+		request.ID = requestId
+	}
+	request.Topic, request.RequestID, request.Payload, request.ReceivedAt = topic, requestId, payload, received
 
 	c.mutex.RLock()
 	handler, exists := c.handlers[request.Method]
 	c.mutex.RUnlock()
 
 	if !exists {
-		c.logger.Printf("No handler registered for method: %s", request.Method)
-		c.sendErrorResponse(requestId, 404, fmt.Sprintf("Method '%s' not found", request.Method))
+		c.logger.Warn("no handler registered for method", "requestId", requestId, "method", request.Method)
+		c.reply(requestId, request.ID, nil, Errorf(CodeNotFound, "Method '%s' not found", request.Method))
 		return
 	}
 
-	responseData, err := handler(requestId, payload)
-	if err != nil {
-		c.logger.Printf("Handler returned error: %v", err)
-		c.sendErrorResponse(requestId, 500, err.Error())
-		return
-	}
-
-	c.sendSuccessResponse(requestId, responseData)
+	c.enqueue(&job{request: &request, handler: handler})
 }
 
 func (c *RRPCClient) extractRequestId(topic string) string {
@@ -136,45 +201,18 @@ func (c *RRPCClient) extractRequestId(topic string) string {
 	return matches[1]
 }
 
-func (c *RRPCClient) sendSuccessResponse(requestId string, data []byte) {
-	// Create response exactly matching C SDK format
-	response := RRPCResponse{
-		ID:      "1", 
-		Version: "1.0",
-	}
-
-	if data != nil && len(data) > 0 {
-		var responseData map[string]interface{}
-		if err := json.Unmarshal(data, &responseData); err == nil {
-			// C SDK compatibility: add params field
-			response.Params = responseData
-		} else {
-			response.Params = map[string]interface{}{
-				"result": string(data),
-			}
-		}
-	} else {
-		// Default response matching C SDK exactly
-		response.Params = map[string]interface{}{
-			"LightSwitch": 0,
-		}
-	}
-
-	c.sendResponse(requestId, response)
-}
+// reply answers the request on the topic of requestId; id is the id of the
+// request payload.
+func (c *RRPCClient) reply(requestId, id string, result interface{}, err error) {
+	c.mutex.RLock()
+	format := c.format
+	c.mutex.RUnlock()
 
-func (c *RRPCClient) sendErrorResponse(requestId string, code int, message string) {
-	response := RRPCResponse{
-		ID:      "1",
-		Version: "1.0",
-		Params: map[string]interface{}{
-			"error": map[string]interface{}{
-				"code":    code,
-				"message": message,
-			},
-		},
+	response, buildErr := buildResponse(format, id, result, err)
+	if buildErr != nil {
+		c.logger.Error("invalid RRPC result", "requestId", requestId, "error", buildErr)
+		response, _ = buildResponse(format, id, nil, Errorf(CodeInternal, "%s", buildErr.Error()))
 	}
-
 	c.sendResponse(requestId, response)
 }
 
@@ -183,16 +221,16 @@ func (c *RRPCClient) sendResponse(requestId string, response RRPCResponse) {
 
 	responseData, err := json.Marshal(response)
 	if err != nil {
-		c.logger.Printf("Failed to marshal RRPC response: %v", err)
+		c.logger.Error("failed to marshal RRPC response", "requestId", requestId, "error", err)
 		return
 	}
 
 	if err := c.mqttClient.Publish(responseTopic, responseData, 0, false); err != nil {
-		c.logger.Printf("Failed to publish RRPC response: %v", err)
+		c.logger.Error("failed to publish RRPC response", "requestId", requestId, "error", err)
 		return
 	}
 
-	c.logger.Printf("Sent RRPC response to topic: %s, payload: %s", responseTopic, string(responseData))
+	c.logger.Debug("sent RRPC response", "topic", responseTopic, "payload", string(responseData))
 }
 
 func (c *RRPCClient) Call(ctx context.Context, method string, params map[string]interface{}) (*RRPCResponse, error) {
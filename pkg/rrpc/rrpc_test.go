@@ -0,0 +1,276 @@
+package rrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/mqtt"
+	"github.com/iot-go-sdk/pkg/testplatform"
+)
+
+func startClient(t *testing.T, configure ...func(*RRPCClient)) (*testplatform.Platform, *RRPCClient) {
+	t.Helper()
+	p, err := testplatform.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	p.AddDevice("pk", "dn", "secret")
+
+	mqttClient := mqtt.NewClient(p.Config("pk", "dn"))
+	if err := mqttClient.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mqttClient.Disconnect)
+	client := NewRRPCClient(mqttClient, "pk", "dn")
+	for _, f := range configure {
+		f(client)
+	}
+	if err := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return p, client
+}
+
+func call(t *testing.T, p *testplatform.Platform, request string) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	response, err := p.RRPC(ctx, "pk", "dn", []byte(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(response)
+}
+
+func TestEnvelopeResponses(t *testing.T) {
+	p, client := startClient(t)
+	type status struct {
+		Online bool `json:"online"`
+	}
+	client.RegisterResultHandler("Status", func(ctx context.Context, requestId string, payload []byte) (interface{}, error) {
+		return status{Online: true}, nil
+	})
+	client.RegisterResultHandler("Locked", func(ctx context.Context, requestId string, payload []byte) (interface{}, error) {
+		return nil, Errorf(403, "door is locked")
+	})
+	client.RegisterHandler("Legacy", func(requestId string, payload []byte) ([]byte, error) {
+		return nil, errors.New("broken")
+	})
+
+	tests := []struct {
+		request, want string
+	}{
+		{`{"id":"42","version":"1.0","method":"Status","params":{}}`,
+			`{"id":"42","version":"1.0","code":200,"data":{"online":true},"message":"success"}`},
+		{`{"id":7,"version":"1.0","method":"Status"}`,
+			`{"id":"7","version":"1.0","code":200,"data":{"online":true},"message":"success"}`},
+		{`{"id":"43","version":"1.0","method":"Locked"}`,
+			`{"id":"43","version":"1.0","code":403,"data":{},"message":"door is locked"}`},
+		{`{"id":"44","version":"1.0","method":"Legacy"}`,
+			`{"id":"44","version":"1.0","code":500,"data":{},"message":"broken"}`},
+		{`{"id":"45","version":"1.0","method":"Missing"}`,
+			`{"id":"45","version":"1.0","code":404,"data":{},"message":"Method 'Missing' not found"}`},
+	}
+	for _, test := range tests {
+		if got := call(t, p, test.request); got != test.want {
+			t.Errorf("%s\n got %s\nwant %s", test.request, got, test.want)
+		}
+	}
+
+	// Without a usable id the reply carries the topic's request id
+	var response RRPCResponse
+	if err := json.Unmarshal([]byte(call(t, p, `not json`)), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != CodeBadRequest || response.ID == "" {
+		t.Fatalf("response = %+v", response)
+	}
+}
+
+func TestCSDKFormat(t *testing.T) {
+	p, client := startClient(t)
+	client.SetResponseFormat(FormatCSDK)
+	client.RegisterHandler("Empty", func(requestId string, payload []byte) ([]byte, error) {
+		return nil, nil
+	})
+	client.RegisterHandler("Text", func(requestId string, payload []byte) ([]byte, error) {
+		return []byte("ok"), nil
+	})
+	client.RegisterResultHandler("Fail", func(ctx context.Context, requestId string, payload []byte) (interface{}, error) {
+		return nil, Errorf(CodeBadRequest, "bad input")
+	})
+
+	tests := []struct {
+		request, want string
+	}{
+		{`{"id":"42","version":"1.0","method":"Empty"}`, `{"id":"1","version":"1.0","params":{"LightSwitch":0}}`},
+		{`{"id":"42","version":"1.0","method":"Text"}`, `{"id":"1","version":"1.0","params":{"result":"ok"}}`},
+		{`{"id":"42","version":"1.0","method":"Fail"}`, `{"id":"1","version":"1.0","params":{"error":{"code":400,"message":"bad input"}}}`},
+	}
+	for _, test := range tests {
+		if got := call(t, p, test.request); got != test.want {
+			t.Errorf("%s\n got %s\nwant %s", test.request, got, test.want)
+		}
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	p, client := startClient(t, func(c *RRPCClient) { c.SetTimeout(200 * time.Millisecond) })
+	client.RegisterResultHandler("Slow", func(ctx context.Context, requestId string, payload []byte) (interface{}, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("no deadline")
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	start := time.Now()
+	want := `{"id":"1","version":"1.0","code":504,"data":{},"message":"Request timed out"}`
+	if got := call(t, p, `{"id":"1","version":"1.0","method":"Slow"}`); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Fatalf("timeout reply after %s, later than the platform timeout", elapsed)
+	}
+}
+
+func TestBusyRejection(t *testing.T) {
+	p, client := startClient(t, func(c *RRPCClient) { c.SetWorkers(1, 1) })
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	client.RegisterResultHandler("Block", func(ctx context.Context, requestId string, payload []byte) (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return requestId, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	send := func(id string) {
+		p.Publish("/sys/pk/dn/rrpc/request/"+id, []byte(`{"id":"`+id+`","version":"1.0","method":"Block"}`))
+	}
+	response := func(id string) RRPCResponse {
+		m, err := p.WaitForMessage(ctx, "/sys/pk/dn/rrpc/response/"+id)
+		if err != nil {
+			t.Fatalf("no response to %s: %v", id, err)
+		}
+		var r RRPCResponse
+		json.Unmarshal(m.Payload, &r)
+		return r
+	}
+
+	// a runs on the only worker, b waits in the queue and c is rejected
+	// while a still blocks
+	send("a")
+	<-started
+	send("b")
+	send("c")
+	if r := response("c"); r.Code != CodeBusy {
+		t.Fatalf("queue overflow answered %+v", r)
+	}
+	select {
+	case <-started:
+		t.Fatal("queued request ran concurrently")
+	default:
+	}
+
+	close(release)
+	for _, id := range []string{"a", "b"} {
+		if r := response(id); r.Code != CodeSuccess || string(r.Data) != `"`+id+`"` {
+			t.Fatalf("response to %s = %+v", id, r)
+		}
+	}
+}
+
+type setTemperature struct {
+	Temperature int `json:"temperature"`
+}
+
+func (p setTemperature) Validate() error {
+	if p.Temperature < 0 || p.Temperature > 300 {
+		return fmt.Errorf("temperature %d out of range", p.Temperature)
+	}
+	return nil
+}
+
+type setMode struct {
+	Mode string `json:"mode"`
+}
+
+func (p *setMode) Validate() error {
+	if p.Mode != "eco" && p.Mode != "boost" {
+		return fmt.Errorf("unknown mode %q", p.Mode)
+	}
+	return nil
+}
+
+func TestTypedHandlers(t *testing.T) {
+	p, client := startClient(t)
+	requests := make(chan *RRPCRequest, 1)
+	client.Register("Inspect", func(ctx context.Context, request *RRPCRequest) (interface{}, error) {
+		requests <- request
+		return request.Params, nil
+	})
+	client.Register("SetTemperature", Bind(func(ctx context.Context, request *RRPCRequest, params setTemperature) (interface{}, error) {
+		return params, nil
+	}))
+	client.Register("SetMode", Bind(func(ctx context.Context, request *RRPCRequest, params *setMode) (interface{}, error) {
+		if params == nil {
+			return "default", nil
+		}
+		return params, nil
+	}))
+
+	before := time.Now()
+	if got, want := call(t, p, `{"id":"9","version":"1.0","method":"Inspect","params":{"a":1}}`),
+		`{"id":"9","version":"1.0","code":200,"data":{"a":1},"message":"success"}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	request := <-requests
+	if request.Method != "Inspect" || request.ID != "9" || request.Params["a"] != 1.0 ||
+		!strings.HasPrefix(request.Topic, "/sys/pk/dn/rrpc/request/") || request.RequestID == "" ||
+		request.ReceivedAt.Before(before) {
+		t.Fatalf("request = %+v", request)
+	}
+
+	tests := []struct {
+		params string
+		code   int
+		data   string
+	}{
+		{`{"temperature":180}`, CodeSuccess, `{"temperature":180}`},
+		{`{"temperature":500}`, CodeBadRequest, `{}`},
+		{`{"temperature":"hot"}`, CodeBadRequest, `{}`},
+	}
+	for _, test := range tests {
+		var response RRPCResponse
+		json.Unmarshal([]byte(call(t, p, `{"id":"1","version":"1.0","method":"SetTemperature","params":`+test.params+`}`)), &response)
+		if response.Code != test.code || string(response.Data) != test.data {
+			t.Errorf("params %s: response = %+v", test.params, response)
+		}
+	}
+
+	// Pointer params are validated through their pointer receiver, and
+	// absent params leave a nil pointer that is not validated
+	tests = []struct {
+		params string
+		code   int
+		data   string
+	}{
+		{`{"mode":"eco"}`, CodeSuccess, `{"mode":"eco"}`},
+		{`{"mode":"turbo"}`, CodeBadRequest, `{}`},
+		{`null`, CodeSuccess, `"default"`},
+	}
+	for _, test := range tests {
+		var response RRPCResponse
+		json.Unmarshal([]byte(call(t, p, `{"id":"1","version":"1.0","method":"SetMode","params":`+test.params+`}`)), &response)
+		if response.Code != test.code || string(response.Data) != test.data {
+			t.Errorf("params %s: response = %+v", test.params, response)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package rrpc
+
+import (
+	"context"
+	"time"
+)
+
+// Defaults of SetWorkers and SetTimeout. The platform waits 5 seconds for
+// an RRPC reply unless configured otherwise.
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 16
+	DefaultTimeout   = 5 * time.Second
+)
+
+// job is a request waiting for or running on a worker.
+type job struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	request *RRPCRequest
+	handler Handler
+}
+
+// SetWorkers runs up to workers handlers at a time and queues up to
+// queueSize more requests; requests beyond that are rejected with
+// CodeBusy. Non-positive values select the defaults. It takes effect on
+// Start.
+func (c *RRPCClient) SetWorkers(workers, queueSize int) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.workers, c.queueSize = workers, queueSize
+}
+
+// SetTimeout sets how long the platform waits for a reply. Handler
+// contexts expire a tenth earlier, leaving time for the timeout reply to
+// reach the platform.
+func (c *RRPCClient) SetTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.timeout = timeout
+}
+
+func (c *RRPCClient) startWorkers() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.queue, c.cancel = make(chan *job, c.queueSize), cancel
+	for i := 0; i < c.workers; i++ {
+		go c.work(ctx, c.queue)
+	}
+}
+
+func (c *RRPCClient) stopWorkers() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.queue, c.cancel = nil, nil
+}
+
+// enqueue hands j to a worker, or rejects it with CodeBusy when the queue
+// is full.
+func (c *RRPCClient) enqueue(j *job) {
+	c.mutex.RLock()
+	queue, timeout := c.queue, c.timeout
+	c.mutex.RUnlock()
+	if queue == nil {
+		c.logger.Warn("RRPC client stopped, dropping request", "requestId", j.request.RequestID)
+		return
+	}
+
+	j.ctx, j.cancel = context.WithDeadline(context.Background(), j.request.ReceivedAt.Add(timeout-timeout/10))
+	select {
+	case queue <- j:
+	default:
+		j.cancel()
+		c.logger.Warn("RRPC queue full, rejecting request", "requestId", j.request.RequestID, "method", j.request.Method)
+		c.reply(j.request.RequestID, j.request.ID, nil, Errorf(CodeBusy, "Device busy"))
+	}
+}
+
+func (c *RRPCClient) work(ctx context.Context, queue <-chan *job) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-queue:
+			// Stop cancels the handler of the job in progress
+			stop := context.AfterFunc(ctx, j.cancel)
+			c.run(j)
+			stop()
+		}
+	}
+}
+
+// run calls the handler of j and replies with its result, or with
+// CodeTimeout once the deadline passes. The worker stays busy until the
+// handler returns so that overrunning handlers still count against the
+// concurrency limit.
+func (c *RRPCClient) run(j *job) {
+	defer j.cancel()
+	if j.ctx.Err() != nil {
+		c.expire(j)
+		return
+	}
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := j.handler(j.ctx, j.request)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			c.logger.Warn("RRPC handler returned error", "requestId", j.request.RequestID, "method", j.request.Method, "error", o.err)
+		}
+		c.reply(j.request.RequestID, j.request.ID, o.result, o.err)
+	case <-j.ctx.Done():
+		c.expire(j)
+		<-done
+		c.logger.Warn("RRPC handler returned after its deadline", "requestId", j.request.RequestID, "method", j.request.Method,
+			"elapsed", time.Since(j.request.ReceivedAt))
+	}
+}
+
+// expire answers a job whose deadline passed. Jobs canceled by Stop get no
+// reply.
+func (c *RRPCClient) expire(j *job) {
+	if j.ctx.Err() != context.DeadlineExceeded {
+		return
+	}
+	c.logger.Warn("RRPC request timed out", "requestId", j.request.RequestID, "method", j.request.Method)
+	c.reply(j.request.RequestID, j.request.ID, nil, Errorf(CodeTimeout, "Request timed out"))
+}
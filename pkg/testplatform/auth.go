@@ -0,0 +1,166 @@
+package testplatform
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/dynreg"
+)
+
+type login struct {
+	productKey string
+	deviceName string
+	// registration is the result pushed to a device registering over MQTT
+	registration []byte
+}
+
+// parseClientID splits "id|k=v,k=v|" into the id and its parameters.
+// Parameters without "=", like the nonce of the default layout, are
+// skipped.
+func parseClientID(clientID string) (string, map[string]string) {
+	id, rest, _ := strings.Cut(clientID, "|")
+	rest = strings.TrimSuffix(rest, "|")
+	params := make(map[string]string)
+	for _, pair := range strings.Split(rest, ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			params[key] = value
+		}
+	}
+	return id, params
+}
+
+// authenticate checks CONNECT credentials like the cloud: a password
+// signed with the device secret in the layout the client ID uses,
+// credentials issued by registration, or a dynamic registration request
+// signed with the product secret.
+func (p *Platform) authenticate(connect *packets.ConnectPacket) (login, byte) {
+	id, params := parseClientID(connect.ClientIdentifier)
+	password := string(connect.Password)
+
+	switch params["authType"] {
+	case "register", "regnwl":
+		// Registration client IDs are deviceName.productKey
+		deviceName, productKey, _ := strings.Cut(id, ".")
+		return p.authenticateRegistration(productKey, deviceName, params, password)
+	}
+
+	productKey, deviceName, _ := strings.Cut(id, ".")
+	l := login{productKey: productKey, deviceName: deviceName}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	d, ok := p.devices[productKey+"/"+deviceName]
+	if !ok {
+		return l, packets.ErrRefusedNotAuthorised
+	}
+	if d.clientID != "" && connect.ClientIdentifier == d.clientID && connect.Username == d.username && password == d.password {
+		d.activated = true
+		return l, packets.Accepted
+	}
+	if d.secret == "" || connect.Username != deviceName+"&"+productKey {
+		return l, packets.ErrRefusedBadUsernameOrPassword
+	}
+
+	layoutName := auth.LayoutAliyun
+	if _, ok := params["_v"]; ok {
+		layoutName = auth.LayoutDefault
+	}
+	layout, _ := auth.LookupLayout(layoutName)
+	signer, err := auth.LookupSigner(params["signmethod"])
+	if err != nil {
+		return l, packets.ErrRefusedBadUsernameOrPassword
+	}
+	content := layout.SignContent(auth.SignParams{
+		ProductKey: productKey,
+		DeviceName: deviceName,
+		Timestamp:  params["timestamp"],
+	})
+	expected, err := signer.Sign(content, []byte(d.secret))
+	if err != nil || !strings.EqualFold(expected, password) {
+		return l, packets.ErrRefusedBadUsernameOrPassword
+	}
+
+	if p.tolerance > 0 {
+		millis, err := strconv.ParseInt(params["timestamp"], 10, 64)
+		if err != nil {
+			return l, packets.ErrRefusedBadUsernameOrPassword
+		}
+		skew := time.Now().Add(p.offset).Sub(time.UnixMilli(millis))
+		if skew > p.tolerance || skew < -p.tolerance {
+			return l, packets.ErrRefusedBadUsernameOrPassword
+		}
+	}
+
+	d.activated = true
+	return l, packets.Accepted
+}
+
+// authenticateRegistration verifies a dynamic registration over MQTT and
+// prepares its result. Refused registrations are refused connections.
+func (p *Platform) authenticateRegistration(productKey, deviceName string, params map[string]string, password string) (login, byte) {
+	l := login{productKey: productKey, deviceName: deviceName}
+	if params["signmethod"] != auth.SignMethodHMACSHA256 {
+		return l, packets.ErrRefusedBadUsernameOrPassword
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	productSecret, ok := p.products[productKey]
+	if !ok {
+		return l, packets.ErrRefusedNotAuthorised
+	}
+	expected := auth.GenerateDynRegSignature(productKey, deviceName, productSecret, params["random"])
+	if !strings.EqualFold(expected, password) {
+		return l, packets.ErrRefusedBadUsernameOrPassword
+	}
+	if p.registrationResponse != nil {
+		l.registration = p.registrationResponse
+		return l, packets.Accepted
+	}
+
+	var result interface{}
+	if params["authType"] == "regnwl" {
+		d, ok := p.devices[productKey+"/"+deviceName]
+		if !ok {
+			d = &device{}
+			p.devices[productKey+"/"+deviceName] = d
+		}
+		if d.activated {
+			return l, packets.ErrRefusedNotAuthorised
+		}
+		d.clientID = productKey + "." + deviceName + "|authType=connwl,securemode=2|"
+		d.username = deviceName + "&" + productKey
+		d.password = randomHex(16)
+		result = map[string]string{"clientId": d.clientID, "username": d.username, "password": d.password}
+	} else {
+		secret, code := p.registerLocked(productKey, deviceName)
+		if code != dynreg.CodeSuccess {
+			return l, packets.ErrRefusedNotAuthorised
+		}
+		result = map[string]string{"deviceSecret": secret}
+	}
+
+	l.registration, _ = json.Marshal(result)
+	return l, packets.Accepted
+}
+
+// registerLocked activates a pre-registered device and returns its
+// secret, or the platform code refusing the registration.
+func (p *Platform) registerLocked(productKey, deviceName string) (string, int) {
+	d, ok := p.devices[productKey+"/"+deviceName]
+	if !ok {
+		return "", dynreg.CodeNotPreRegistered
+	}
+	if d.activated {
+		return "", dynreg.CodeAlreadyRegistered
+	}
+	if d.secret == "" {
+		d.secret = randomHex(16)
+	}
+	d.activated = true
+	return d.secret, dynreg.CodeSuccess
+}
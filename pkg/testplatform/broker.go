@@ -0,0 +1,212 @@
+package testplatform
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/iot-go-sdk/pkg/mqtt"
+)
+
+// session is one device connection.
+type session struct {
+	conn       net.Conn
+	clientID   string
+	productKey string
+	deviceName string
+	// filters are guarded by the platform mutex
+	filters map[string]bool
+
+	writeMutex sync.Mutex
+}
+
+// subscribed reports whether a filter of s matches topic. The platform
+// mutex must be held.
+func (s *session) subscribed(topic string) bool {
+	for filter := range s.filters {
+		if mqtt.TopicMatches(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *session) write(packet packets.ControlPacket) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return packet.Write(s.conn)
+}
+
+func (s *session) publish(topic string, payload []byte) error {
+	publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.TopicName = topic
+	publish.Payload = payload
+	return s.write(publish)
+}
+
+func (p *Platform) accept() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.serve(conn)
+		}()
+	}
+}
+
+// serve runs the MQTT protocol on one connection until it closes.
+func (p *Platform) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	packet, err := packets.ReadPacket(reader)
+	connect, ok := packet.(*packets.ConnectPacket)
+	if !ok {
+		p.logger.Debug("connection did not start with CONNECT", "remote", conn.RemoteAddr(), "error", err)
+		return
+	}
+
+	s := &session{conn: conn, clientID: connect.ClientIdentifier, filters: make(map[string]bool)}
+	connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+	if connect.ProtocolVersion != 3 && connect.ProtocolVersion != 4 {
+		connack.ReturnCode = packets.ErrRefusedBadProtocolVersion
+		s.write(connack)
+		return
+	}
+	if err != nil {
+		p.logger.Debug("malformed CONNECT", "remote", conn.RemoteAddr(), "error", err)
+		return
+	}
+
+	login, code := p.authenticate(connect)
+	connack.ReturnCode = code
+	if code != packets.Accepted {
+		p.logger.Info("connection refused", "clientId", connect.ClientIdentifier, "code", code)
+		s.write(connack)
+		return
+	}
+	s.productKey, s.deviceName = login.productKey, login.deviceName
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.sessions[s] = true
+	p.mutex.Unlock()
+	defer func() {
+		p.mutex.Lock()
+		delete(p.sessions, s)
+		p.notifyLocked()
+		p.mutex.Unlock()
+	}()
+
+	if err := s.write(connack); err != nil {
+		return
+	}
+	p.logger.Debug("device connected", "clientId", s.clientID)
+	if login.registration != nil {
+		// The registration result is pushed without a subscription; the
+		// device disconnects once it has it
+		s.publish(fmt.Sprintf("/ext/register/%s/%s", s.productKey, s.deviceName), login.registration)
+	}
+
+	// Allow one and a half keepalive intervals between packets
+	var idle time.Duration
+	if connect.Keepalive > 0 {
+		idle = time.Duration(connect.Keepalive) * 1500 * time.Millisecond
+	}
+	for {
+		if idle > 0 {
+			conn.SetReadDeadline(time.Now().Add(idle))
+		} else {
+			conn.SetReadDeadline(time.Time{})
+		}
+		packet, err := packets.ReadPacket(reader)
+		if err != nil {
+			p.logger.Debug("device disconnected", "clientId", s.clientID, "error", err)
+			return
+		}
+
+		switch packet := packet.(type) {
+		case *packets.PublishPacket:
+			if packet.Qos > 1 {
+				// QoS 2 is not supported
+				return
+			}
+			if packet.Qos == 1 {
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = packet.MessageID
+				if err := s.write(puback); err != nil {
+					return
+				}
+			}
+			p.handlePublish(s, packet.TopicName, packet.Payload)
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = packet.MessageID
+			p.mutex.Lock()
+			for i, filter := range packet.Topics {
+				if err := mqtt.ValidateTopicFilter(filter); err != nil || p.rejected[filter] {
+					suback.ReturnCodes = append(suback.ReturnCodes, 0x80)
+					continue
+				}
+				s.filters[filter] = true
+				suback.ReturnCodes = append(suback.ReturnCodes, min(packet.Qoss[i], 1))
+			}
+			p.notifyLocked()
+			p.mutex.Unlock()
+			if err := s.write(suback); err != nil {
+				return
+			}
+		case *packets.UnsubscribePacket:
+			p.mutex.Lock()
+			for _, filter := range packet.Topics {
+				delete(s.filters, filter)
+			}
+			p.mutex.Unlock()
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = packet.MessageID
+			if err := s.write(unsuback); err != nil {
+				return
+			}
+		case *packets.PingreqPacket:
+			if err := s.write(packets.NewControlPacket(packets.Pingresp)); err != nil {
+				return
+			}
+		case *packets.DisconnectPacket:
+			p.logger.Debug("device disconnected", "clientId", s.clientID)
+			return
+		case *packets.PubackPacket:
+			// Deliveries are QoS 0; nothing to acknowledge
+		default:
+			p.logger.Debug("unexpected packet", "clientId", s.clientID, "packet", packet.String())
+			return
+		}
+	}
+}
+
+// handlePublish records a device message, forwards it to subscribers and
+// lets the platform answer it.
+func (p *Platform) handlePublish(s *session, topic string, payload []byte) {
+	p.record(Message{
+		ClientID:   s.clientID,
+		ProductKey: s.productKey,
+		DeviceName: s.deviceName,
+		Topic:      topic,
+		Payload:    payload,
+		Time:       time.Now(),
+	})
+	p.Publish(topic, payload)
+	p.reply(s, topic, payload)
+}
@@ -0,0 +1,199 @@
+package testplatform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/mqtt"
+)
+
+// Reply is a device's answer to a command.
+type Reply struct {
+	Topic   string
+	ID      string
+	Code    int
+	Data    json.RawMessage
+	Message string
+}
+
+// Firmware describes an OTA upgrade.
+type Firmware struct {
+	Version string
+	URL     string
+	Size    int
+	// Sign is the hex digest of the image; SignMethod is "Md5" or
+	// "SHA256" (default)
+	Sign       string
+	SignMethod string
+	Module     string
+	ExtData    string
+}
+
+func (f Firmware) data() map[string]interface{} {
+	method := f.SignMethod
+	if method == "" {
+		method = "SHA256"
+	}
+	data := map[string]interface{}{
+		"version":    f.Version,
+		"url":        f.URL,
+		"size":       f.Size,
+		"sign":       f.Sign,
+		"signMethod": method,
+	}
+	if f.Module != "" {
+		data["module"] = f.Module
+	}
+	if f.ExtData != "" {
+		data["extData"] = f.ExtData
+	}
+	return data
+}
+
+// reply answers the device messages the cloud answers by itself.
+func (p *Platform) reply(s *session, topic string, payload []byte) {
+	var request struct {
+		ID             interface{} `json:"id"`
+		DeviceSendTime json.Number `json:"deviceSendTime"`
+	}
+	received := p.now()
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return
+	}
+	levels := strings.Split(topic, "/")
+
+	var replyTopic string
+	var response interface{}
+	switch {
+	case mqtt.TopicMatches("$SYS/+/+/property/post", topic), mqtt.TopicMatches("$SYS/+/+/event/post", topic):
+		replyTopic = topic + "/reply"
+		response = map[string]interface{}{"id": request.ID, "code": 200, "data": map[string]interface{}{}}
+	case mqtt.TopicMatches("/sys/+/+/thing/ota/firmware/get", topic):
+		p.mutex.Lock()
+		firmware, ok := p.firmware[levels[2]+"/"+levels[3]]
+		p.mutex.Unlock()
+		data := map[string]interface{}{}
+		if ok {
+			data = firmware.data()
+		}
+		replyTopic = topic + "_reply"
+		response = map[string]interface{}{"id": request.ID, "code": 200, "data": data}
+	case mqtt.TopicMatches("/ext/ntp/+/+/request", topic):
+		replyTopic = strings.TrimSuffix(topic, "request") + "response"
+		response = map[string]interface{}{
+			"deviceSendTime": request.DeviceSendTime,
+			"serverRecvTime": received.UnixMilli(),
+			"serverSendTime": p.now().UnixMilli(),
+		}
+	default:
+		return
+	}
+
+	data, _ := json.Marshal(response)
+	if err := s.publish(replyTopic, data); err != nil {
+		p.logger.Debug("failed to reply", "clientId", s.clientID, "topic", replyTopic, "error", err)
+	}
+}
+
+func (p *Platform) newID() string {
+	return strconv.FormatInt(p.nextID.Add(1), 10)
+}
+
+// command publishes a request and waits for the reply with the same id on
+// a topic matching one of replyFilters.
+func (p *Platform) command(ctx context.Context, topic string, request map[string]interface{}, replyFilters ...string) (*Reply, error) {
+	id := p.newID()
+	request["id"] = id
+	request["version"] = "1.0"
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	if p.Publish(topic, payload) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrNoSubscriber, topic)
+	}
+
+	var reply *Reply
+	_, err = p.waitFor(ctx, func(m Message) bool {
+		matched := false
+		for _, filter := range replyFilters {
+			matched = matched || mqtt.TopicMatches(filter, m.Topic)
+		}
+		if !matched {
+			return false
+		}
+		var body struct {
+			ID      interface{}     `json:"id"`
+			Code    int             `json:"code"`
+			Data    json.RawMessage `json:"data"`
+			Message string          `json:"message"`
+		}
+		if json.Unmarshal(m.Payload, &body) != nil || fmt.Sprint(body.ID) != id {
+			return false
+		}
+		reply = &Reply{Topic: m.Topic, ID: id, Code: body.Code, Data: body.Data, Message: body.Message}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no reply to %s: %w", topic, err)
+	}
+	return reply, nil
+}
+
+// SetProperties sends a property set command and waits for the device's
+// reply.
+func (p *Platform) SetProperties(ctx context.Context, productKey, deviceName string, params map[string]interface{}) (*Reply, error) {
+	topic := fmt.Sprintf("$SYS/%s/%s/property/set", productKey, deviceName)
+	return p.command(ctx, topic, map[string]interface{}{"params": params}, topic+"/reply")
+}
+
+// InvokeService calls a device service and waits for the device's reply.
+func (p *Platform) InvokeService(ctx context.Context, productKey, deviceName, service string, params map[string]interface{}) (*Reply, error) {
+	topic := fmt.Sprintf("$SYS/%s/%s/service/%s/invoke", productKey, deviceName, service)
+	return p.command(ctx, topic, map[string]interface{}{"params": params},
+		topic+"/reply",
+		// Reply topic of the framework's MQTT plugin
+		fmt.Sprintf("/sys/%s/%s/thing/service/+", productKey, deviceName))
+}
+
+// RRPC sends a synchronous RRPC request and returns the device's response.
+func (p *Platform) RRPC(ctx context.Context, productKey, deviceName string, payload []byte) ([]byte, error) {
+	id := p.newID()
+	topic := fmt.Sprintf("/sys/%s/%s/rrpc/request/%s", productKey, deviceName, id)
+	responseTopic := fmt.Sprintf("/sys/%s/%s/rrpc/response/%s", productKey, deviceName, id)
+	if p.Publish(topic, payload) == 0 {
+		return nil, fmt.Errorf("%w for %s", ErrNoSubscriber, topic)
+	}
+	m, err := p.WaitForMessage(ctx, responseTopic)
+	if err != nil {
+		return nil, fmt.Errorf("no RRPC response to %s: %w", topic, err)
+	}
+	return m.Payload, nil
+}
+
+// PushUpgrade notifies a device of new firmware. The firmware also answers
+// the device's firmware queries from then on.
+func (p *Platform) PushUpgrade(productKey, deviceName string, firmware Firmware) error {
+	p.mutex.Lock()
+	p.firmware[productKey+"/"+deviceName] = firmware
+	p.mutex.Unlock()
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"code":    "1000",
+		"id":      time.Now().UnixMilli(),
+		"message": "success",
+		"data":    firmware.data(),
+	})
+	if err != nil {
+		return err
+	}
+	topic := fmt.Sprintf("/ota/device/upgrade/%s/%s", productKey, deviceName)
+	if p.Publish(topic, payload) == 0 {
+		return fmt.Errorf("%w for %s", ErrNoSubscriber, topic)
+	}
+	return nil
+}
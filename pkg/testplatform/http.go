@@ -0,0 +1,76 @@
+package testplatform
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/dynreg"
+)
+
+const registrationPath = "/auth/register/device"
+
+type registrationResponse struct {
+	Code      int               `json:"code"`
+	Data      map[string]string `json:"data,omitempty"`
+	Message   string            `json:"message"`
+	RequestID string            `json:"requestId"`
+}
+
+func (p *Platform) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(registrationPath, p.handleRegistration)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses are dated with platform time, see SetClockOffset
+		w.Header().Set("Date", p.now().UTC().Format(http.TimeFormat))
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// handleRegistration implements HTTPS dynamic registration. Like the cloud
+// it answers refusals with HTTP 200 and a platform code.
+func (p *Platform) handleRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	productKey := r.PostForm.Get("productKey")
+	deviceName := r.PostForm.Get("deviceName")
+	response := registrationResponse{RequestID: randomHex(8)}
+
+	p.mutex.Lock()
+	productSecret, ok := p.products[productKey]
+	switch {
+	case !ok || r.PostForm.Get("signMethod") != auth.SignMethodHMACSHA256 ||
+		!strings.EqualFold(r.PostForm.Get("sign"), auth.GenerateDynRegSignature(productKey, deviceName, productSecret, r.PostForm.Get("random"))):
+		response.Code, response.Message = dynreg.CodeSignatureInvalid, "signature check failed"
+	case p.registrationResponse != nil:
+		payload := p.registrationResponse
+		p.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(payload)
+		return
+	default:
+		var secret string
+		secret, response.Code = p.registerLocked(productKey, deviceName)
+		switch response.Code {
+		case dynreg.CodeSuccess:
+			response.Message = "success"
+			response.Data = map[string]string{"productKey": productKey, "deviceName": deviceName, "deviceSecret": secret}
+		case dynreg.CodeNotPreRegistered:
+			response.Message = "device not found"
+		case dynreg.CodeAlreadyRegistered:
+			response.Message = "device already active"
+		}
+	}
+	p.mutex.Unlock()
+
+	p.logger.Debug("registration request", "productKey", productKey, "deviceName", deviceName, "code", response.Code)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,396 @@
+// Package testplatform runs a fake IoT platform in-process for integration
+// tests: an MQTT 3.1.1 broker that authenticates devices the way the cloud
+// does, and an HTTPS endpoint for dynamic registration.
+//
+//	p, err := testplatform.Start()
+//	defer p.Close()
+//	p.AddDevice("pk", "dn", "secret")
+//	client := mqtt.NewClient(p.Config("pk", "dn"))
+//
+// The platform answers property and event posts, firmware queries and NTP
+// requests on its own. Tests push commands with SetProperties,
+// InvokeService, RRPC and PushUpgrade, and assert on what devices sent with
+// Messages and WaitForMessage.
+//
+// The broker supports QoS 0 and 1 from devices and delivers everything at
+// QoS 0. There are no retained messages, wills or persistent sessions.
+package testplatform
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/logging"
+	"github.com/iot-go-sdk/pkg/mqtt"
+)
+
+// ErrNoSubscriber is returned by commands when no connected device
+// subscribes to the command topic.
+var ErrNoSubscriber = errors.New("no subscriber")
+
+// Message is a message published by a device.
+type Message struct {
+	ClientID   string
+	ProductKey string
+	DeviceName string
+	Topic      string
+	Payload    []byte
+	Time       time.Time
+}
+
+type device struct {
+	secret string
+	// activated is set once the device registered or connected
+	activated bool
+	// Credentials issued by registration without pre-registration
+	clientID, username, password string
+}
+
+// Platform is a running fake platform.
+type Platform struct {
+	listener net.Listener
+	http     *httptest.Server
+	certs    *certificates
+	logger   logging.Logger
+
+	mutex     sync.Mutex
+	products  map[string]string
+	devices   map[string]*device
+	firmware  map[string]Firmware
+	sessions  map[*session]bool
+	messages  []Message
+	changed   chan struct{}
+	offset    time.Duration
+	tolerance time.Duration
+	// registrationResponse replaces the result of accepted registrations
+	registrationResponse []byte
+	// rejected are filters whose subscriptions are refused
+	rejected map[string]bool
+	closed   bool
+
+	nextID atomic.Int64
+	wg     sync.WaitGroup
+}
+
+// Start starts a platform listening on loopback ports.
+func Start() (*Platform, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	certs, err := newCertificates()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	p := &Platform{
+		listener: listener,
+		certs:    certs,
+		logger:   logging.Default().Named("testplatform"),
+		products: make(map[string]string),
+		devices:  make(map[string]*device),
+		firmware: make(map[string]Firmware),
+		sessions: make(map[*session]bool),
+		rejected: make(map[string]bool),
+		changed:  make(chan struct{}),
+	}
+	// The server certificate follows platform time, see SetClockOffset
+	p.http = httptest.NewUnstartedServer(p.httpHandler())
+	p.http.Listener = tls.NewListener(p.http.Listener, &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return p.certs.serverCertificate(p.now())
+		},
+	})
+	p.http.Start()
+
+	p.wg.Add(1)
+	go p.accept()
+	return p, nil
+}
+
+// Close disconnects all devices and stops the platform.
+func (p *Platform) Close() {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	for s := range p.sessions {
+		s.conn.Close()
+	}
+	p.mutex.Unlock()
+
+	p.listener.Close()
+	p.http.Close()
+	p.wg.Wait()
+}
+
+// SetLogger sets the logger for the platform.
+func (p *Platform) SetLogger(logger logging.Logger) {
+	p.logger = logging.Redacting(logger)
+}
+
+// BrokerAddr returns the host:port of the MQTT broker.
+func (p *Platform) BrokerAddr() string {
+	return p.listener.Addr().String()
+}
+
+// RegistrationURL returns the dynamic registration endpoint.
+func (p *Platform) RegistrationURL() string {
+	return "https://" + p.http.Listener.Addr().String() + registrationPath
+}
+
+// CACert returns the PEM certificate of the CA that issues the HTTPS
+// server certificate.
+func (p *Platform) CACert() string {
+	return p.certs.caPEM()
+}
+
+// AddProduct makes a product known, enabling dynamic registration with
+// productSecret.
+func (p *Platform) AddProduct(productKey, productSecret string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.products[productKey] = productSecret
+}
+
+// AddDevice creates a device. A device added without secret is
+// pre-registered: it receives a secret on dynamic registration.
+func (p *Platform) AddDevice(productKey, deviceName, deviceSecret string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.devices[productKey+"/"+deviceName] = &device{secret: deviceSecret}
+}
+
+// DeviceSecret returns the secret of a device, including one issued by
+// dynamic registration.
+func (p *Platform) DeviceSecret(productKey, deviceName string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if d, ok := p.devices[productKey+"/"+deviceName]; ok {
+		return d.secret
+	}
+	return ""
+}
+
+// SetClockOffset makes the platform clock run ahead of the local clock by
+// offset, e.g. to simulate a device with a wrong clock. It applies to NTP
+// responses, the Date header of HTTPS responses and the validity period of
+// the HTTPS server certificate.
+func (p *Platform) SetClockOffset(offset time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.offset = offset
+}
+
+// SetTimestampTolerance rejects connections whose signed timestamp differs
+// from platform time by more than tolerance. Zero, the default, accepts
+// any timestamp.
+func (p *Platform) SetTimestampTolerance(tolerance time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.tolerance = tolerance
+}
+
+// SetRegistrationResponse makes registrations with a valid signature,
+// over HTTPS or MQTT, answer with payload as is and without activating the
+// device, e.g. to test malformed platform responses. nil restores normal
+// answers.
+func (p *Platform) SetRegistrationResponse(payload []byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.registrationResponse = payload
+}
+
+// RejectSubscriptions refuses later subscriptions to exactly these
+// filters with a SUBACK failure, as the platform does for topics a device
+// is not authorized for. Existing subscriptions are kept.
+func (p *Platform) RejectSubscriptions(filters ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, filter := range filters {
+		p.rejected[filter] = true
+	}
+}
+
+func (p *Platform) now() time.Time {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return time.Now().Add(p.offset)
+}
+
+// Config returns a device config pointing at the platform, with the
+// device and product secrets the platform knows.
+func (p *Platform) Config(productKey, deviceName string) *config.Config {
+	host, port, _ := net.SplitHostPort(p.BrokerAddr())
+	httpHost, httpPort, _ := net.SplitHostPort(p.http.Listener.Addr().String())
+
+	cfg := config.NewConfig()
+	cfg.Device.ProductKey = productKey
+	cfg.Device.DeviceName = deviceName
+	p.mutex.Lock()
+	cfg.Device.ProductSecret = p.products[productKey]
+	if d, ok := p.devices[productKey+"/"+deviceName]; ok {
+		cfg.Device.DeviceSecret = d.secret
+	}
+	p.mutex.Unlock()
+
+	cfg.MQTT.Host = host
+	cfg.MQTT.Port, _ = strconv.Atoi(port)
+	cfg.MQTT.UseTLS = false
+	cfg.MQTT.SecureMode = config.SecureModeTCP
+	cfg.DynReg.Scheme = "https"
+	cfg.DynReg.Host = httpHost
+	cfg.DynReg.Port, _ = strconv.Atoi(httpPort)
+	cfg.TLS.CACert = p.CACert()
+	return cfg
+}
+
+// Connected reports whether a device has an open connection.
+func (p *Platform) Connected(productKey, deviceName string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for s := range p.sessions {
+		if s.productKey == productKey && s.deviceName == deviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// DisconnectDevice drops the connections of a device, as the platform does
+// when it kicks a device off.
+func (p *Platform) DisconnectDevice(productKey, deviceName string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for s := range p.sessions {
+		if s.productKey == productKey && s.deviceName == deviceName {
+			s.conn.Close()
+		}
+	}
+}
+
+// Messages returns the messages devices published to topics matching
+// filter, oldest first.
+func (p *Platform) Messages(filter string) []Message {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var messages []Message
+	for _, m := range p.messages {
+		if mqtt.TopicMatches(filter, m.Topic) {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// WaitForMessage returns the first message published to a topic matching
+// filter, waiting for one until ctx is done.
+func (p *Platform) WaitForMessage(ctx context.Context, filter string) (Message, error) {
+	return p.waitFor(ctx, func(m Message) bool {
+		return mqtt.TopicMatches(filter, m.Topic)
+	})
+}
+
+func (p *Platform) waitFor(ctx context.Context, match func(Message) bool) (Message, error) {
+	seen := 0
+	for {
+		p.mutex.Lock()
+		for ; seen < len(p.messages); seen++ {
+			if match(p.messages[seen]) {
+				m := p.messages[seen]
+				p.mutex.Unlock()
+				return m, nil
+			}
+		}
+		changed := p.changed
+		p.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+// WaitForSubscriber waits until a connected device subscribes to a filter
+// matching topic.
+func (p *Platform) WaitForSubscriber(ctx context.Context, topic string) error {
+	for {
+		p.mutex.Lock()
+		subscribed := len(p.subscribersLocked(topic)) > 0
+		changed := p.changed
+		p.mutex.Unlock()
+		if subscribed {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// notifyLocked wakes up waiters after a message or subscription arrived.
+func (p *Platform) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Publish sends a message to the devices subscribed to topic and returns
+// how many received it.
+func (p *Platform) Publish(topic string, payload []byte) int {
+	p.mutex.Lock()
+	subscribers := p.subscribersLocked(topic)
+	p.mutex.Unlock()
+
+	delivered := 0
+	for _, s := range subscribers {
+		if err := s.publish(topic, payload); err != nil {
+			p.logger.Debug("failed to deliver message", "clientId", s.clientID, "topic", topic, "error", err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+func (p *Platform) subscribersLocked(topic string) []*session {
+	var subscribers []*session
+	for s := range p.sessions {
+		if s.subscribed(topic) {
+			subscribers = append(subscribers, s)
+		}
+	}
+	return subscribers
+}
+
+func (p *Platform) record(m Message) {
+	p.mutex.Lock()
+	p.messages = append(p.messages, m)
+	p.notifyLocked()
+	p.mutex.Unlock()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,177 @@
+package testplatform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/config"
+	"github.com/iot-go-sdk/pkg/dynreg"
+	"github.com/iot-go-sdk/pkg/mqtt"
+	"github.com/iot-go-sdk/pkg/ota"
+	"github.com/iot-go-sdk/pkg/rrpc"
+)
+
+func startPlatform(t *testing.T) *Platform {
+	t.Helper()
+	p, err := Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func connect(t *testing.T, cfg *config.Config) *mqtt.Client {
+	t.Helper()
+	client := mqtt.NewClient(cfg)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Disconnect)
+	return client
+}
+
+func TestDeviceFlows(t *testing.T) {
+	p := startPlatform(t)
+	p.AddDevice("pk", "dn", "deviceSecret")
+	client := connect(t, p.Config("pk", "dn"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Property post is answered by the platform
+	replies := make(chan []byte, 1)
+	client.Subscribe("$SYS/pk/dn/property/post/reply", 0, func(topic string, payload []byte) { replies <- payload })
+	client.Publish("$SYS/pk/dn/property/post", []byte(`{"id":"7","params":{"temperature":21}}`), 1, false)
+	select {
+	case payload := <-replies:
+		if !strings.Contains(string(payload), `"code":200`) || !strings.Contains(string(payload), `"id":"7"`) {
+			t.Fatalf("property post reply = %s", payload)
+		}
+	case <-ctx.Done():
+		t.Fatal("no property post reply")
+	}
+	if m := p.Messages("$SYS/pk/dn/property/post"); len(m) != 1 || m[0].DeviceName != "dn" {
+		t.Fatalf("recorded messages = %+v", m)
+	}
+
+	// Property set is answered by the device
+	client.Subscribe("$SYS/pk/dn/property/set", 0, func(topic string, payload []byte) {
+		var request struct{ ID string }
+		json.Unmarshal(payload, &request)
+		client.Publish(topic+"/reply", []byte(fmt.Sprintf(`{"id":%q,"code":200,"data":{}}`, request.ID)), 0, false)
+	})
+	reply, err := p.SetProperties(ctx, "pk", "dn", map[string]interface{}{"switch": 1})
+	if err != nil || reply.Code != 200 {
+		t.Fatalf("reply = %+v, err = %v", reply, err)
+	}
+
+	// RRPC
+	rrpcClient := rrpc.NewRRPCClient(client, "pk", "dn")
+	rrpcClient.RegisterHandler("Echo", func(requestId string, payload []byte) ([]byte, error) {
+		return []byte(`{"echo":true}`), nil
+	})
+	if err := rrpcClient.Start(); err != nil {
+		t.Fatal(err)
+	}
+	response, err := p.RRPC(ctx, "pk", "dn", []byte(`{"id":"1","version":"1.0","method":"Echo","params":{}}`))
+	if err != nil || !strings.Contains(string(response), `"echo":true`) {
+		t.Fatalf("RRPC response = %s, err = %v", response, err)
+	}
+
+	// OTA upgrade push and progress
+	tasks := make(chan *ota.TaskDesc, 1)
+	otaClient := ota.NewClient(client, "pk", "dn")
+	otaClient.SetRecvHandler(func(c *ota.Client, recvType ota.RecvType, task *ota.TaskDesc) { tasks <- task })
+	if err := otaClient.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PushUpgrade("pk", "dn", Firmware{Version: "2.0.0", URL: "http://127.0.0.1/fw.bin", Size: 1024, Sign: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case task := <-tasks:
+		if task.Version != "2.0.0" || task.Size != 1024 {
+			t.Fatalf("task = %+v", task)
+		}
+	case <-ctx.Done():
+		t.Fatal("no upgrade task")
+	}
+	otaClient.ReportProgress("50", "downloading", 50, "")
+	if m, err := p.WaitForMessage(ctx, "/ota/device/progress/pk/dn"); err != nil || !strings.Contains(string(m.Payload), `"step":"50"`) {
+		t.Fatalf("progress = %s, err = %v", m.Payload, err)
+	}
+
+	if _, err := p.InvokeService(ctx, "pk", "other", "reboot", nil); !errors.Is(err, ErrNoSubscriber) {
+		t.Fatalf("err = %v, want ErrNoSubscriber", err)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	p := startPlatform(t)
+	p.AddDevice("pk", "dn", "deviceSecret")
+
+	cfg := p.Config("pk", "dn")
+	cfg.MQTT.CredentialLayout = auth.LayoutAliyun
+	cfg.MQTT.SignMethod = auth.SignMethodHMACSHA1
+	connect(t, cfg)
+	if !p.Connected("pk", "dn") {
+		t.Fatal("device not connected")
+	}
+
+	cfg = p.Config("pk", "dn")
+	cfg.Device.DeviceSecret = "wrong"
+	if err := mqtt.NewClient(cfg).Connect(); err == nil {
+		t.Fatal("connected with a wrong device secret")
+	}
+
+	p.SetTimestampTolerance(time.Minute)
+	p.SetClockOffset(time.Hour)
+	if err := mqtt.NewClient(p.Config("pk", "dn")).Connect(); err == nil {
+		t.Fatal("connected with a stale timestamp")
+	}
+}
+
+func TestDynamicRegistration(t *testing.T) {
+	p := startPlatform(t)
+	p.AddProduct("pk", "productSecret")
+	p.AddDevice("pk", "preregistered", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cfg, err := dynreg.Provision(ctx, p.Config("pk", "preregistered"), dynreg.ProvisionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Device.DeviceSecret == "" || cfg.Device.DeviceSecret != p.DeviceSecret("pk", "preregistered") {
+		t.Fatalf("device secret = %q", cfg.Device.DeviceSecret)
+	}
+	connect(t, cfg)
+
+	_, err = dynreg.NewHTTPDynRegClient(p.Config("pk", "preregistered")).RegisterContext(ctx)
+	if !errors.Is(err, dynreg.ErrAlreadyRegistered) {
+		t.Fatalf("err = %v, want ErrAlreadyRegistered", err)
+	}
+	_, err = dynreg.NewHTTPDynRegClient(p.Config("pk", "unknown")).RegisterContext(ctx)
+	if !errors.Is(err, dynreg.ErrNotPreRegistered) {
+		t.Fatalf("err = %v, want ErrNotPreRegistered", err)
+	}
+
+	// Registration without pre-registration issues connect credentials
+	cfg, err = dynreg.Provision(ctx, p.Config("pk", "new"), dynreg.ProvisionOptions{SkipPreRegist: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MQTT.Password == "" || cfg.Device.DeviceSecret != "" {
+		t.Fatalf("provisioned = %+v", cfg.MQTT)
+	}
+	connect(t, cfg)
+	if !p.Connected("pk", "new") {
+		t.Fatal("registered device not connected")
+	}
+}
@@ -0,0 +1,339 @@
+// Package timesync measures the offset of the local clock against server
+// time over MQTT and maintains a corrected clock.
+package timesync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/clock"
+	"github.com/iot-go-sdk/pkg/logging"
+	"github.com/iot-go-sdk/pkg/mqtt"
+)
+
+const (
+	DefaultInterval      = time.Hour
+	DefaultSkewThreshold = 5 * time.Second
+
+	syncTimeout = 10 * time.Second
+)
+
+// Skew is the result of one time synchronization.
+type Skew struct {
+	// Offset is added to the local time to get server time; it is positive
+	// when the local clock is behind
+	Offset    time.Duration
+	RoundTrip time.Duration
+	// Threshold is the skew above which SkewHandler is called
+	Threshold  time.Duration
+	ServerTime time.Time
+}
+
+// Exceeded reports whether the offset is larger than the threshold.
+func (s Skew) Exceeded() bool {
+	offset := s.Offset
+	if offset < 0 {
+		offset = -offset
+	}
+	return s.Threshold > 0 && offset > s.Threshold
+}
+
+// SkewHandler is called after a synchronization found the local clock off
+// by more than the threshold.
+type SkewHandler func(skew Skew)
+
+// mqttClient is the part of mqtt.Client used by the service.
+type mqttClient interface {
+	Subscribe(topic string, qos byte, handler mqtt.MessageHandler) error
+	Unsubscribe(topic string) error
+	Publish(topic string, payload []byte, qos byte, retained bool) error
+}
+
+type ntpRequest struct {
+	DeviceSendTime string `json:"deviceSendTime"`
+}
+
+// ntpResponse fields arrive as JSON strings or numbers.
+type ntpResponse struct {
+	DeviceSendTime json.Number `json:"deviceSendTime"`
+	ServerRecvTime json.Number `json:"serverRecvTime"`
+	ServerSendTime json.Number `json:"serverSendTime"`
+}
+
+type pendingSync struct {
+	deviceSendTime string
+	sent           time.Time
+	result         chan Skew
+}
+
+// Service exchanges /ext/ntp/{pk}/{dn}/request and response messages with
+// the platform and keeps Clock corrected by the measured offset.
+type Service struct {
+	client        mqttClient
+	requestTopic  string
+	responseTopic string
+	clock         *clock.Corrected
+	logger        logging.Logger
+
+	mutex       sync.Mutex
+	interval    time.Duration
+	threshold   time.Duration
+	skewHandler SkewHandler
+	pending     *pendingSync
+
+	syncMutex sync.Mutex
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+func NewService(client *mqtt.Client, productKey, deviceName string) *Service {
+	return newService(client, productKey, deviceName)
+}
+
+func newService(client mqttClient, productKey, deviceName string) *Service {
+	return &Service{
+		client:        client,
+		requestTopic:  fmt.Sprintf("/ext/ntp/%s/%s/request", productKey, deviceName),
+		responseTopic: fmt.Sprintf("/ext/ntp/%s/%s/response", productKey, deviceName),
+		clock:         clock.NewCorrected(),
+		logger:        logging.Default().Named("timesync"),
+		interval:      DefaultInterval,
+		threshold:     DefaultSkewThreshold,
+	}
+}
+
+func (s *Service) SetLogger(logger logging.Logger) {
+	s.logger = logging.Redacting(logger)
+}
+
+// SetInterval sets how often Start resynchronizes; 0 syncs only once.
+func (s *Service) SetInterval(interval time.Duration) {
+	s.mutex.Lock()
+	s.interval = interval
+	s.mutex.Unlock()
+}
+
+// SetSkewThreshold sets the offset above which the skew handler is
+// called; 0 disables it.
+func (s *Service) SetSkewThreshold(threshold time.Duration) {
+	s.mutex.Lock()
+	s.threshold = threshold
+	s.mutex.Unlock()
+}
+
+// OnSkew registers the handler called when the skew exceeds the threshold.
+func (s *Service) OnSkew(handler SkewHandler) {
+	s.mutex.Lock()
+	s.skewHandler = handler
+	s.mutex.Unlock()
+}
+
+// SetClock makes the service correct c instead of a clock of its own, e.g.
+// one the MQTT client already uses. Call it before Start.
+func (s *Service) SetClock(c *clock.Corrected) {
+	s.clock = c
+}
+
+// Clock returns the corrected clock. Install it with clock.SetDefault so
+// credentials and reported timestamps use server time.
+func (s *Service) Clock() *clock.Corrected {
+	return s.clock
+}
+
+// Start subscribes to the response topic and synchronizes in the
+// background, once right away and then every interval.
+func (s *Service) Start() error {
+	if err := s.client.Subscribe(s.responseTopic, 0, s.handleResponse); err != nil {
+		return fmt.Errorf("failed to subscribe to NTP response topic: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go s.run(ctx)
+	return nil
+}
+
+// Stop ends background synchronization and unsubscribes.
+func (s *Service) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+		s.cancel = nil
+	}
+	return s.client.Unsubscribe(s.responseTopic)
+}
+
+func (s *Service) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		syncCtx, cancel := context.WithTimeout(ctx, syncTimeout)
+		if _, err := s.Sync(syncCtx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("time sync failed", "error", err)
+		}
+		cancel()
+
+		s.mutex.Lock()
+		interval := s.interval
+		s.mutex.Unlock()
+		if interval <= 0 {
+			return
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Sync requests server time, updates the clock and returns the measured
+// skew. Start must have subscribed to the response topic.
+func (s *Service) Sync(ctx context.Context) (Skew, error) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	// The exchange is timed with the uncorrected local clock
+	now := time.Now()
+	p := &pendingSync{
+		deviceSendTime: strconv.FormatInt(now.UnixMilli(), 10),
+		sent:           now,
+		result:         make(chan Skew, 1),
+	}
+	payload, err := json.Marshal(ntpRequest{DeviceSendTime: p.deviceSendTime})
+	if err != nil {
+		return Skew{}, fmt.Errorf("failed to marshal NTP request: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.pending = p
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.pending = nil
+		s.mutex.Unlock()
+	}()
+
+	if err := s.client.Publish(s.requestTopic, payload, 0, false); err != nil {
+		return Skew{}, fmt.Errorf("failed to publish NTP request: %w", err)
+	}
+
+	select {
+	case skew := <-p.result:
+		return skew, nil
+	case <-ctx.Done():
+		return Skew{}, fmt.Errorf("time sync timeout: %w", ctx.Err())
+	}
+}
+
+func (s *Service) handleResponse(topic string, payload []byte) {
+	received := time.Now()
+
+	var response ntpResponse
+	if err := json.Unmarshal(payload, &response); err != nil {
+		s.logger.Warn("failed to unmarshal NTP response", "error", err)
+		return
+	}
+
+	serverRecv, err1 := response.ServerRecvTime.Int64()
+	serverSend, err2 := response.ServerSendTime.Int64()
+	if err1 != nil || err2 != nil {
+		s.logger.Warn("NTP response without server times", "payload", string(payload))
+		return
+	}
+
+	s.mutex.Lock()
+	p := s.pending
+	if p != nil && response.DeviceSendTime.String() == p.deviceSendTime {
+		// Claim the request so a duplicate response is ignored
+		s.pending = nil
+	} else {
+		p = nil
+	}
+	threshold := s.threshold
+	handler := s.skewHandler
+	s.mutex.Unlock()
+	if p == nil {
+		s.logger.Debug("ignoring unexpected NTP response", "payload", string(payload))
+		return
+	}
+
+	// received.Sub(p.sent) uses the monotonic clock
+	deviceSend := p.sent.UnixMilli()
+	deviceRecv := deviceSend + received.Sub(p.sent).Milliseconds()
+	skew := computeSkew(deviceSend, serverRecv, serverSend, deviceRecv)
+	skew.Threshold = threshold
+
+	s.clock.SetOffset(skew.Offset)
+	s.logger.Debug("time synchronized", "offset", skew.Offset, "roundTrip", skew.RoundTrip)
+	if skew.Exceeded() {
+		s.logger.Warn("local clock skew exceeds threshold", "offset", skew.Offset, "threshold", threshold)
+		if handler != nil {
+			handler(skew)
+		}
+	}
+	p.result <- skew
+}
+
+// MeasureHTTP estimates the offset of the local clock from the Date header
+// of a HEAD request to url, e.g. the dynamic registration endpoint. Unlike
+// Sync it needs no MQTT connection, so it also works when the clock is too
+// far off for the platform to accept the signed connect. The header has a
+// resolution of one second. Only responses received over TLS are trusted;
+// client should verify the server without checking the certificate
+// validity period, see tls.ConfigOptions.IgnoreValidity, as that depends
+// on the very clock being measured.
+func MeasureHTTP(ctx context.Context, client *http.Client, url string) (Skew, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return Skew{}, fmt.Errorf("invalid time sync URL: %w", err)
+	}
+	sent := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return Skew{}, fmt.Errorf("time sync request failed: %w", err)
+	}
+	received := time.Now()
+	response.Body.Close()
+	if response.TLS == nil {
+		return Skew{}, fmt.Errorf("time sync response from %s was not received over TLS", url)
+	}
+
+	date, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return Skew{}, fmt.Errorf("time sync response has no valid Date header: %w", err)
+	}
+	// The server truncated its time to the second; assume it answered in
+	// the middle of that second and of the round trip
+	roundTrip := received.Sub(sent)
+	serverTime := date.Add(500 * time.Millisecond)
+	return Skew{
+		Offset:     serverTime.Sub(sent.Add(roundTrip / 2)).Round(time.Millisecond),
+		RoundTrip:  roundTrip,
+		ServerTime: serverTime.Add(roundTrip / 2),
+	}, nil
+}
+
+// computeSkew applies the NTP formulas to millisecond timestamps.
+func computeSkew(deviceSend, serverRecv, serverSend, deviceRecv int64) Skew {
+	offset := ((serverRecv - deviceSend) + (serverSend - deviceRecv)) / 2
+	roundTrip := (deviceRecv - deviceSend) - (serverSend - serverRecv)
+	if roundTrip < 0 {
+		roundTrip = 0
+	}
+	return Skew{
+		Offset:    time.Duration(offset) * time.Millisecond,
+		RoundTrip: time.Duration(roundTrip) * time.Millisecond,
+		// Server time when the response was received
+		ServerTime: time.UnixMilli(deviceRecv + offset),
+	}
+}
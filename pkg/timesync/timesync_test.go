@@ -0,0 +1,144 @@
+package timesync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/auth"
+	"github.com/iot-go-sdk/pkg/clock"
+	"github.com/iot-go-sdk/pkg/mqtt"
+)
+
+// fakeServer answers NTP requests with a server clock that is ahead of the
+// local clock by ahead.
+type fakeServer struct {
+	ahead    time.Duration
+	handlers map[string]mqtt.MessageHandler
+	numeric  bool
+}
+
+func (f *fakeServer) Subscribe(topic string, qos byte, handler mqtt.MessageHandler) error {
+	f.handlers[topic] = handler
+	return nil
+}
+
+func (f *fakeServer) Unsubscribe(topic string) error {
+	delete(f.handlers, topic)
+	return nil
+}
+
+func (f *fakeServer) Publish(topic string, payload []byte, qos byte, retained bool) error {
+	var request ntpRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return err
+	}
+	serverTime := strconv.FormatInt(time.Now().Add(f.ahead).UnixMilli(), 10)
+	response := fmt.Sprintf(`{"deviceSendTime":"%s","serverRecvTime":"%s","serverSendTime":"%s"}`,
+		request.DeviceSendTime, serverTime, serverTime)
+	if f.numeric {
+		response = fmt.Sprintf(`{"deviceSendTime":%s,"serverRecvTime":%s,"serverSendTime":%s}`,
+			request.DeviceSendTime, serverTime, serverTime)
+	}
+	go f.handlers[strings.Replace(topic, "/request", "/response", 1)](topic, []byte(response))
+	return nil
+}
+
+func TestSyncCorrectsClockAndReportsSkew(t *testing.T) {
+	for _, numeric := range []bool{false, true} {
+		server := &fakeServer{ahead: time.Hour, handlers: make(map[string]mqtt.MessageHandler), numeric: numeric}
+		s := newService(server, "pk", "dn")
+		var reported []Skew
+		s.OnSkew(func(skew Skew) { reported = append(reported, skew) })
+		if err := s.client.Subscribe(s.responseTopic, 0, s.handleResponse); err != nil {
+			t.Fatal(err)
+		}
+
+		skew, err := s.Sync(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := skew.Offset - time.Hour; diff < -time.Second || diff > time.Second {
+			t.Fatalf("offset = %v, want about 1h", skew.Offset)
+		}
+		if !s.Clock().Synced() || s.Clock().Now().Sub(time.Now()) < 59*time.Minute {
+			t.Fatalf("clock not corrected: offset %v", s.Clock().Offset())
+		}
+		if len(reported) != 1 || !reported[0].Exceeded() {
+			t.Fatalf("skew handler calls = %+v", reported)
+		}
+	}
+}
+
+func TestSyncWithinThreshold(t *testing.T) {
+	server := &fakeServer{handlers: make(map[string]mqtt.MessageHandler)}
+	s := newService(server, "pk", "dn")
+	s.OnSkew(func(skew Skew) { t.Errorf("unexpected skew report: %+v", skew) })
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if _, err := s.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComputeSkew(t *testing.T) {
+	// Local clock 500ms behind, 100ms each way, 20ms server processing
+	skew := computeSkew(1000, 1600, 1620, 1220)
+	if skew.Offset != 500*time.Millisecond || skew.RoundTrip != 200*time.Millisecond {
+		t.Fatalf("skew = %+v", skew)
+	}
+	if !skew.ServerTime.Equal(time.UnixMilli(1720)) {
+		t.Fatalf("server time = %v", skew.ServerTime)
+	}
+}
+
+func TestCredentialsUseDefaultClock(t *testing.T) {
+	corrected := clock.NewCorrected()
+	corrected.SetOffset(-24 * time.Hour)
+	clock.SetDefault(corrected)
+	defer clock.SetDefault(nil)
+
+	credentials := auth.GenerateMQTTCredentials("pk", "dn", "secret", "3")
+	_, rest, _ := strings.Cut(credentials.ClientID, "timestamp=")
+	timestamp, err := strconv.ParseInt(strings.SplitN(rest, ",", 2)[0], 10, 64)
+	if err != nil {
+		t.Fatalf("client ID %s has no timestamp", credentials.ClientID)
+	}
+	if diff := time.Now().Add(-24 * time.Hour).Sub(time.UnixMilli(timestamp)); diff < -time.Second || diff > time.Second {
+		t.Fatalf("client ID %s not timestamped with the corrected clock", credentials.ClientID)
+	}
+}
+
+func TestMeasureHTTPTrustsOnlyTLS(t *testing.T) {
+	ahead := time.Hour
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(ahead).UTC().Format(http.TimeFormat))
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+	skew, err := MeasureHTTP(ctx, secure.Client(), secure.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skew.Offset < ahead-2*time.Second || skew.Offset > ahead+2*time.Second {
+		t.Fatalf("offset = %v, want about %v", skew.Offset, ahead)
+	}
+
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	if _, err := MeasureHTTP(ctx, plain.Client(), plain.URL); err == nil {
+		t.Fatal("time taken from a plain HTTP response")
+	}
+}
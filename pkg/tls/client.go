@@ -0,0 +1,184 @@
+package tls
+
+import (
+	gotls "crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/iot-go-sdk/pkg/clock"
+	"github.com/iot-go-sdk/pkg/config"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ConfigOptions adjusts how NewConfigWithOptions verifies the server.
+type ConfigOptions struct {
+	// Clock is the time the server certificate must be valid at; nil uses
+	// clock.Default()
+	Clock clock.Clock
+	// IgnoreValidity skips the validity period check, see Verifier
+	IgnoreValidity bool
+}
+
+// NewConfig builds the client TLS configuration described by cfg: the CA
+// pool and pins used to verify the broker and, when configured, the device
+// certificate presented for mutual TLS. host is the dialled broker address;
+// the certificate is checked against cfg.ServerName when set, else host.
+func NewConfig(cfg *config.TLSConfig, host string) (*gotls.Config, error) {
+	return NewConfigWithOptions(cfg, host, ConfigOptions{})
+}
+
+// NewConfigWithOptions is like NewConfig but checks the server certificate
+// as opts says.
+func NewConfigWithOptions(cfg *config.TLSConfig, host string, opts ConfigOptions) (*gotls.Config, error) {
+	certPool, err := LoadCAPool(cfg.CACert, cfg.CAFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA certificate: %w", err)
+	}
+
+	pins, err := ParsePins(cfg.PinnedSPKI, cfg.PinnedCertSHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	serverName := cfg.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	verifier := &Verifier{
+		Roots:          certPool,
+		ServerName:     serverName,
+		Pins:           pins,
+		SkipChain:      cfg.SkipVerify,
+		Clock:          opts.Clock,
+		IgnoreValidity: opts.IgnoreValidity,
+	}
+
+	tlsConfig := &gotls.Config{
+		ServerName: cfg.ServerName,
+		RootCAs:    certPool,
+		// Not insecure: Verifier checks chain, name and pins itself so the
+		// name checked can differ from the dialled address
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifier.VerifyPeerCertificate,
+	}
+	if cfg.SkipVerify && pins == nil {
+		tlsConfig.VerifyPeerCertificate = nil
+	}
+
+	if cfg.HasClientCert() {
+		reloader, err := NewCertificateReloader(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	return tlsConfig, nil
+}
+
+// LoadCAPool returns a pool holding caCert (inline PEM or a PEM file path)
+// and every PEM bundle in files. The embedded CustomCACert is used when
+// neither is given.
+func LoadCAPool(caCert string, files []string) (*x509.CertPool, error) {
+	if len(files) == 0 {
+		if caCert == "" {
+			return LoadCACert("")
+		}
+		certPEM, err := readPEM(caCert)
+		if err != nil {
+			return nil, err
+		}
+		return LoadCACert(string(certPEM))
+	}
+
+	certPool := x509.NewCertPool()
+	if caCert != "" {
+		files = append([]string{caCert}, files...)
+	}
+	for _, file := range files {
+		certPEM, err := readPEM(file)
+		if err != nil {
+			return nil, err
+		}
+		if !certPool.AppendCertsFromPEM(certPEM) {
+			return nil, fmt.Errorf("no CA certificates found in %s", describePEM(file))
+		}
+	}
+	return certPool, nil
+}
+
+// LoadClientCertificate loads the device certificate and private key from
+// a PKCS#12 bundle when ClientPKCS12 is set, otherwise from ClientCert and
+// ClientKey.
+func LoadClientCertificate(cfg *config.TLSConfig) (gotls.Certificate, error) {
+	if cfg.ClientPKCS12 != "" {
+		return LoadPKCS12(cfg.ClientPKCS12, cfg.ClientPKCS12Password)
+	}
+	return LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+}
+
+// LoadX509KeyPair loads a certificate and private key. Each argument is
+// either inline PEM or the path of a PEM file.
+func LoadX509KeyPair(cert, key string) (gotls.Certificate, error) {
+	certPEM, err := readPEM(cert)
+	if err != nil {
+		return gotls.Certificate{}, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	keyPEM, err := readPEM(key)
+	if err != nil {
+		return gotls.Certificate{}, fmt.Errorf("failed to read client key: %w", err)
+	}
+
+	pair, err := gotls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return gotls.Certificate{}, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return gotls.Certificate{}, fmt.Errorf("failed to parse client certificate: %w", err)
+	}
+	return pair, nil
+}
+
+// LoadPKCS12 loads a certificate, its private key and any intermediate
+// certificates from a PKCS#12 (.p12/.pfx) file.
+func LoadPKCS12(path, password string) (gotls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return gotls.Certificate{}, fmt.Errorf("failed to read PKCS#12 bundle: %w", err)
+	}
+
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return gotls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 bundle: %w", err)
+	}
+
+	cert := gotls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range chain {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+	return cert, nil
+}
+
+// readPEM returns value itself when it holds inline PEM, otherwise the
+// contents of the file it names.
+func readPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+// describePEM names a readPEM value in errors without echoing inline PEM.
+func describePEM(value string) string {
+	if strings.Contains(value, "-----BEGIN") {
+		return "inline PEM"
+	}
+	return value
+}
@@ -0,0 +1,264 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	gotls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type testPKI struct {
+	ca         *x509.Certificate
+	caKey      *ecdsa.PrivateKey
+	caPEM      []byte
+	caPool     *x509.CertPool
+	server     gotls.Certificate
+	device     *x509.Certificate
+	deviceKey  *ecdsa.PrivateKey
+	devicePEM  []byte
+	deviceKPEM []byte
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test IoT CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	pki := &testPKI{
+		ca:     ca,
+		caKey:  caKey,
+		caPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		caPool: x509.NewCertPool(),
+	}
+	pki.caPool.AddCert(ca)
+
+	serverCert, serverKey := pki.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "broker.test"},
+		DNSNames:     []string{"broker.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	pki.server = gotls.Certificate{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}
+
+	pki.device, pki.deviceKey = pki.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "testProduct.testDevice"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	keyDER, err := x509.MarshalECPrivateKey(pki.deviceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki.devicePEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.device.Raw})
+	pki.deviceKPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return pki
+}
+
+// issue signs a certificate for template with the test CA.
+func (p *testPKI) issue(t *testing.T, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestLoadX509KeyPairFromFilesAndInlinePEM(t *testing.T) {
+	pki := newTestPKI(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "device.crt")
+	keyFile := filepath.Join(dir, "device.key")
+	if err := os.WriteFile(certFile, pki.devicePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pki.deviceKPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFiles, err := LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inline, err := LoadX509KeyPair(string(pki.devicePEM), string(pki.deviceKPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cert := range []gotls.Certificate{fromFiles, inline} {
+		if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "testProduct.testDevice" {
+			t.Fatalf("unexpected leaf: %+v", cert.Leaf)
+		}
+	}
+
+	if _, err := LoadX509KeyPair(certFile, filepath.Join(dir, "missing.key")); err == nil {
+		t.Fatal("expected error for missing key file")
+	}
+}
+
+func TestLoadPKCS12(t *testing.T) {
+	pki := newTestPKI(t)
+	data, err := pkcs12.Modern.Encode(pki.deviceKey, pki.device, nil, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "device.p12")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := LoadClientCertificate(&config.TLSConfig{ClientPKCS12: path, ClientPKCS12Password: "changeit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.Subject.CommonName != "testProduct.testDevice" {
+		t.Fatalf("common name = %q", cert.Leaf.Subject.CommonName)
+	}
+
+	if _, err := LoadPKCS12(path, "wrong"); err == nil {
+		t.Fatal("expected error for wrong password")
+	}
+}
+
+func TestNewConfigPresentsClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+
+	listener, err := gotls.Listen("tcp", "127.0.0.1:0", &gotls.Config{
+		Certificates: []gotls.Certificate{pki.server},
+		ClientAuth:   gotls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.caPool,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	peer := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			peer <- ""
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*gotls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			peer <- ""
+			return
+		}
+		peer <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}()
+
+	tlsConfig, err := NewConfig(&config.TLSConfig{
+		CACert:     string(pki.caPEM),
+		ClientCert: string(pki.devicePEM),
+		ClientKey:  string(pki.deviceKPEM),
+	}, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := gotls.Dial("tcp", listener.Addr().String(), tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cn := <-peer; cn != "testProduct.testDevice" {
+		t.Fatalf("server saw client certificate %q", cn)
+	}
+}
+
+func TestCertificateReloaderPicksUpRotatedFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "device.crt")
+	keyFile := filepath.Join(dir, "device.key")
+	if err := os.WriteFile(certFile, pki.devicePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pki.deviceKPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloader, err := NewCertificateReloader(&config.TLSConfig{ClientCert: certFile, ClientKey: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, rotatedKey := pki.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "testProduct.testDevice.rotated"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	keyDER, err := x509.MarshalECPrivateKey(rotatedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A half-written rotation keeps serving the old pair
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rotated.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := reloader.GetClientCertificate(nil)
+	if err != nil || cert.Leaf.Subject.CommonName != "testProduct.testDevice" {
+		t.Fatalf("during rotation got %v, %v", cert, err)
+	}
+	if reloader.LastError() == nil {
+		t.Fatal("mismatched pair not reported")
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err = reloader.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Leaf.Subject.CommonName != "testProduct.testDevice.rotated" {
+		t.Fatalf("common name = %q", cert.Leaf.Subject.CommonName)
+	}
+}
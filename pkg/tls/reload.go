@@ -0,0 +1,86 @@
+package tls
+
+import (
+	gotls "crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/iot-go-sdk/pkg/config"
+)
+
+// CertificateReloader serves the client certificate for TLS handshakes and
+// reloads it when the certificate, key or PKCS#12 file changes, so a
+// rotated certificate is presented from the next (re)connect on.
+type CertificateReloader struct {
+	cfg config.TLSConfig
+
+	mutex sync.Mutex
+	cert  *gotls.Certificate
+	stamp string
+	err   error
+}
+
+// NewCertificateReloader loads the client certificate described by cfg.
+func NewCertificateReloader(cfg *config.TLSConfig) (*CertificateReloader, error) {
+	r := &CertificateReloader{cfg: *cfg}
+	if _, err := r.certificate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetClientCertificate has the signature of tls.Config.GetClientCertificate.
+func (r *CertificateReloader) GetClientCertificate(*gotls.CertificateRequestInfo) (*gotls.Certificate, error) {
+	return r.certificate()
+}
+
+// LastError returns the error of the most recent failed reload, or nil. A
+// failed reload keeps serving the previous certificate.
+func (r *CertificateReloader) LastError() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+func (r *CertificateReloader) certificate() (*gotls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stamp := r.fileStamp()
+	if r.cert != nil && stamp == r.stamp {
+		return r.cert, nil
+	}
+
+	cert, err := LoadClientCertificate(&r.cfg)
+	if err != nil {
+		// Files are often replaced one at a time; keep the old pair until
+		// the new one loads
+		r.err = err
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+
+	r.cert = &cert
+	r.stamp = stamp
+	r.err = nil
+	return r.cert, nil
+}
+
+// fileStamp summarises size and modification time of the configured files.
+// Inline PEM values never change.
+func (r *CertificateReloader) fileStamp() string {
+	var stamp strings.Builder
+	for _, path := range []string{r.cfg.ClientPKCS12, r.cfg.ClientCert, r.cfg.ClientKey} {
+		if path == "" || strings.Contains(path, "-----BEGIN") {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&stamp, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return stamp.String()
+}
@@ -0,0 +1,206 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iot-go-sdk/pkg/clock"
+)
+
+// Failure kinds reported by VerificationError; test for them with errors.Is.
+var (
+	ErrNoPeerCertificate    = errors.New("server presented no certificate")
+	ErrUntrustedCertificate = errors.New("server certificate is not signed by a trusted CA")
+	ErrServerNameMismatch   = errors.New("server certificate does not match the server name")
+	ErrPinMismatch          = errors.New("server certificate does not match any pin")
+)
+
+// VerificationError reports why the broker certificate was rejected. Err
+// holds the underlying x509 error, if any.
+type VerificationError struct {
+	Kind       error
+	ServerName string
+	Err        error
+}
+
+func (e *VerificationError) Error() string {
+	msg := fmt.Sprintf("tls: %v", e.Kind)
+	if e.ServerName != "" {
+		msg += fmt.Sprintf(" (server name %q)", e.ServerName)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *VerificationError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Pins holds SHA-256 pins of server certificates. A connection passes pin
+// checking when any certificate of the presented chain matches any pin.
+type Pins struct {
+	spki  [][]byte
+	certs [][]byte
+}
+
+// ParsePins parses SPKI pins (base64 SHA-256 of the SubjectPublicKeyInfo,
+// optionally prefixed with "sha256/") and certificate pins (hex SHA-256 of
+// the DER certificate, colons allowed). It returns nil when both are empty.
+func ParsePins(spki, certs []string) (*Pins, error) {
+	if len(spki) == 0 && len(certs) == 0 {
+		return nil, nil
+	}
+
+	pins := &Pins{}
+	for _, pin := range spki {
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(pin), "sha256/"))
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %q: want base64 SHA-256", pin)
+		}
+		pins.spki = append(pins.spki, sum)
+	}
+	for _, pin := range certs {
+		sum, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate pin %q: want hex SHA-256", pin)
+		}
+		pins.certs = append(pins.certs, sum)
+	}
+	return pins, nil
+}
+
+// SPKIPin returns the SPKI pin of cert in the form accepted by ParsePins.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// CertificatePin returns the certificate pin of cert in the form accepted
+// by ParsePins.
+func CertificatePin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Matches reports whether any certificate in chain matches a pin.
+func (p *Pins) Matches(chain []*x509.Certificate) bool {
+	for _, cert := range chain {
+		spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		raw := sha256.Sum256(cert.Raw)
+		for _, pin := range p.spki {
+			if bytes.Equal(pin, spki[:]) {
+				return true
+			}
+		}
+		for _, pin := range p.certs {
+			if bytes.Equal(pin, raw[:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Verifier checks a broker certificate chain against a CA pool, a server
+// name and optional pins. It replaces crypto/tls verification so that the
+// name checked can differ from the dialled address, e.g. when the broker is
+// reached by IP but its certificate names a host.
+type Verifier struct {
+	Roots      *x509.CertPool
+	ServerName string
+	Pins       *Pins
+	// SkipChain disables CA and name checks, leaving only pin checks.
+	SkipChain bool
+	// Clock is the time the chain must be valid at; nil uses
+	// clock.Default(), so a corrected clock also applies here.
+	Clock clock.Clock
+	// IgnoreValidity accepts certificates outside their validity period
+	// while still checking CA, name and pins. It is only meant for reading
+	// the time from a server when the local clock cannot be trusted.
+	IgnoreValidity bool
+}
+
+// VerifyPeerCertificate has the signature of tls.Config.VerifyPeerCertificate.
+func (v *Verifier) VerifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return &VerificationError{Kind: ErrNoPeerCertificate, ServerName: v.ServerName}
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return &VerificationError{Kind: ErrUntrustedCertificate, ServerName: v.ServerName, Err: err}
+		}
+		certs = append(certs, cert)
+	}
+
+	chain := certs
+	if !v.SkipChain {
+		verified, err := v.verifyChain(certs)
+		if err != nil {
+			return err
+		}
+		chain = verified
+	}
+
+	if v.Pins != nil && !v.Pins.Matches(chain) {
+		return &VerificationError{Kind: ErrPinMismatch, ServerName: v.ServerName}
+	}
+	return nil
+}
+
+// verifyChain validates certs against Roots and returns the verified chain.
+func (v *Verifier) verifyChain(certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	leaf := certs[0]
+	now := clock.Now()
+	if v.Clock != nil {
+		now = v.Clock.Now()
+	}
+	if v.IgnoreValidity {
+		// The issuing CAs were valid when the leaf was issued
+		now = leaf.NotBefore
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         v.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return nil, &VerificationError{Kind: ErrUntrustedCertificate, ServerName: v.ServerName, Err: err}
+	}
+
+	if v.ServerName != "" {
+		if err := leaf.VerifyHostname(v.ServerName); err != nil && !matchesLegacyCommonName(leaf, v.ServerName) {
+			return nil, &VerificationError{Kind: ErrServerNameMismatch, ServerName: v.ServerName, Err: err}
+		}
+	}
+	return chains[0], nil
+}
+
+// matchesLegacyCommonName accepts certificates without any SAN whose common
+// name equals name. Some platform brokers still use such certificates, which
+// crypto/x509 no longer matches by common name.
+func matchesLegacyCommonName(cert *x509.Certificate, name string) bool {
+	if len(cert.DNSNames) > 0 || len(cert.IPAddresses) > 0 || len(cert.URIs) > 0 || len(cert.EmailAddresses) > 0 {
+		return false
+	}
+	return cert.Subject.CommonName != "" && strings.EqualFold(cert.Subject.CommonName, name)
+}
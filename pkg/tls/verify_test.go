@@ -0,0 +1,191 @@
+package tls
+
+import (
+	gotls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iot-go-sdk/pkg/config"
+)
+
+// handshake dials a TLS server presenting cert with a client built from cfg.
+func handshake(t *testing.T, cert gotls.Certificate, cfg *config.TLSConfig) error {
+	t.Helper()
+
+	listener, err := gotls.Listen("tcp", "127.0.0.1:0", &gotls.Config{Certificates: []gotls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*gotls.Conn).Handshake()
+	}()
+
+	tlsConfig, err := NewConfig(cfg, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := gotls.Dial("tcp", listener.Addr().String(), tlsConfig)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestNewConfigVerifiesServerName(t *testing.T) {
+	pki := newTestPKI(t)
+
+	// Dialled by IP, verified against the pinned server name
+	if err := handshake(t, pki.server, &config.TLSConfig{CACert: string(pki.caPEM), ServerName: "broker.test"}); err != nil {
+		t.Fatalf("server name verification failed: %v", err)
+	}
+
+	err := handshake(t, pki.server, &config.TLSConfig{CACert: string(pki.caPEM), ServerName: "other.test"})
+	if !errors.Is(err, ErrServerNameMismatch) {
+		t.Fatalf("err = %v, want ErrServerNameMismatch", err)
+	}
+	var hostnameErr x509.HostnameError
+	if !errors.As(err, &hostnameErr) {
+		t.Fatalf("err = %v, want wrapped x509.HostnameError", err)
+	}
+}
+
+func TestNewConfigRejectsUntrustedServer(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+
+	err := handshake(t, pki.server, &config.TLSConfig{CACert: string(other.caPEM), ServerName: "broker.test"})
+	if !errors.Is(err, ErrUntrustedCertificate) {
+		t.Fatalf("err = %v, want ErrUntrustedCertificate", err)
+	}
+
+	// SkipVerify without pins accepts anything
+	if err := handshake(t, pki.server, &config.TLSConfig{CACert: string(other.caPEM), SkipVerify: true}); err != nil {
+		t.Fatalf("skip verify: %v", err)
+	}
+}
+
+func TestNewConfigLoadsMultipleCAFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "other.pem"), filepath.Join(dir, "platform.pem")}
+	if err := os.WriteFile(files[0], other.caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(files[1], pki.caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := handshake(t, pki.server, &config.TLSConfig{CAFiles: files}); err != nil {
+		t.Fatalf("CA bundle verification failed: %v", err)
+	}
+
+	if _, err := LoadCAPool("", []string{filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Fatal("expected error for missing CA file")
+	}
+}
+
+func TestNewConfigPinning(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+	leaf, _ := x509.ParseCertificate(pki.server.Certificate[0])
+
+	cases := []struct {
+		name string
+		cfg  config.TLSConfig
+		err  error
+	}{
+		{"spki pin of leaf", config.TLSConfig{PinnedSPKI: []string{"sha256/" + SPKIPin(leaf)}}, nil},
+		{"certificate pin of CA", config.TLSConfig{PinnedCertSHA256: []string{CertificatePin(pki.ca)}}, nil},
+		{"wrong pin", config.TLSConfig{PinnedSPKI: []string{SPKIPin(other.ca)}}, ErrPinMismatch},
+		{"pin only with skip verify", config.TLSConfig{SkipVerify: true, PinnedSPKI: []string{SPKIPin(leaf)}}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.CACert = string(pki.caPEM)
+			err := handshake(t, pki.server, &tc.cfg)
+			if tc.err == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+		})
+	}
+
+	if _, err := ParsePins([]string{"not-base64"}, nil); err == nil {
+		t.Fatal("expected error for malformed SPKI pin")
+	}
+	if _, err := ParsePins(nil, []string{"ab:cd"}); err == nil {
+		t.Fatal("expected error for short certificate pin")
+	}
+}
+
+func TestVerifierAcceptsLegacyCommonName(t *testing.T) {
+	pki := newTestPKI(t)
+	legacy, _ := pki.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(10),
+		Subject:      pkix.Name{CommonName: "IoT"},
+	})
+
+	verifier := &Verifier{Roots: pki.caPool, ServerName: "IoT"}
+	if err := verifier.VerifyPeerCertificate([][]byte{legacy.Raw}, nil); err != nil {
+		t.Fatalf("legacy common name rejected: %v", err)
+	}
+
+	verifier.ServerName = "broker.test"
+	if err := verifier.VerifyPeerCertificate([][]byte{legacy.Raw}, nil); !errors.Is(err, ErrServerNameMismatch) {
+		t.Fatalf("err = %v, want ErrServerNameMismatch", err)
+	}
+
+	if err := verifier.VerifyPeerCertificate(nil, nil); !errors.Is(err, ErrNoPeerCertificate) {
+		t.Fatalf("err = %v, want ErrNoPeerCertificate", err)
+	}
+}
+
+// fixedClock always tells the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestVerifierChecksValidityWithClock(t *testing.T) {
+	pki := newTestPKI(t)
+	chain := [][]byte{pki.server.Certificate[0]}
+
+	// A device clock years off makes the certificate look expired
+	verifier := &Verifier{Roots: pki.caPool, ServerName: "broker.test", Clock: fixedClock(time.Now().AddDate(5, 0, 0))}
+	err := verifier.VerifyPeerCertificate(chain, nil)
+	var invalid x509.CertificateInvalidError
+	if !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+		t.Fatalf("err = %v, want expired certificate", err)
+	}
+
+	// Ignoring the validity period still checks the name and pins
+	verifier.IgnoreValidity = true
+	if err := verifier.VerifyPeerCertificate(chain, nil); err != nil {
+		t.Fatalf("validity not ignored: %v", err)
+	}
+	verifier.ServerName = "other.test"
+	if err := verifier.VerifyPeerCertificate(chain, nil); !errors.Is(err, ErrServerNameMismatch) {
+		t.Fatalf("err = %v, want ErrServerNameMismatch", err)
+	}
+	verifier.ServerName = "broker.test"
+	verifier.Pins, _ = ParsePins([]string{SPKIPin(newTestPKI(t).ca)}, nil)
+	if err := verifier.VerifyPeerCertificate(chain, nil); !errors.Is(err, ErrPinMismatch) {
+		t.Fatalf("err = %v, want ErrPinMismatch", err)
+	}
+}